@@ -0,0 +1,65 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcore
+
+type identityKey struct{}
+
+// IdentityKey is the Ctx key under which the *Identity of the authenticated
+// caller is stored by the auth middleware.
+var IdentityKey = identityKey{}
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	// Subject is the id of caller, such as the user name or the `sub` of jwt.
+	Subject string `json:"subject"`
+	// Method is the name of authenticator, such as jwt, apikey and basic.
+	Method string `json:"method"`
+	// Scopes is the scopes granted to caller.
+	Scopes []string `json:"scopes"`
+	// Roles is the roles of caller.
+	Roles []string `json:"roles"`
+	// Claims is the extra attributes of caller, such as the claims of jwt.
+	Claims map[string]interface{} `json:"claims"`
+}
+
+// HasScope returns true if the identity is granted all the scopes.
+func (i *Identity) HasScope(scopes ...string) bool {
+	for _, s := range scopes {
+		found := false
+		for _, v := range i.Scopes {
+			if v == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// HasRole returns true if the identity has any of the roles.
+func (i *Identity) HasRole(roles ...string) bool {
+	for _, r := range roles {
+		for _, v := range i.Roles {
+			if v == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type permissionCheckerKey struct{}
+
+// PermissionCheckerKey is the Ctx key under which the PermissionChecker of
+// request is stored by the rbac middleware.
+var PermissionCheckerKey = permissionCheckerKey{}
+
+// PermissionChecker reports whether the caller of a request is granted the
+// permission.
+type PermissionChecker func(permission string) bool
@@ -37,3 +37,30 @@ type Cache interface {
 	// Decr decreases N cached int-type value by given key as a counter.
 	DecrN(key string, n int64) (int64, error)
 }
+
+// CacheStats is a snapshot of the counters of a cache.
+// Items and Size are -1 if the backend can not report them.
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	Sets   int64
+	Dels   int64
+	// Evictions is the count of items removed by expiration or size quota.
+	Evictions int64
+	Items     int64
+	// Size is the memory or disk usage in bytes.
+	Size int64
+}
+
+// HitRatio returns hits/(hits+misses), 0 if there is no lookup.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CacheStatsReader is implemented by the caches which count their operations.
+type CacheStatsReader interface {
+	Stats() CacheStats
+}
@@ -59,6 +59,8 @@ type Ctx interface {
 	IsOPTIONS() bool
 	IsAJAX() bool
 	IsWebsocket() bool
+	WebSocket(upgrader ...WebSocketUpgrader) (conn WebSocketConn, err error)
+	SSE(heartbeat ...time.Duration) (sse SSE, err error)
 	Stop(msg ...interface{})
 	StopJSON(code int, msg interface{})
 	ClientIP() (ip string)
@@ -93,4 +95,12 @@ type Ctx interface {
 	ControllerMethod() string
 	SetControllerMethod(controllerMethod string)
 	IsTLSRequest()bool
+	Bind(obj interface{}) error
+	BindJSON(obj interface{}) error
+	BindQuery(obj interface{}) error
+	BindForm(obj interface{}) error
+	BindMultipart(obj interface{}) error
+	BindParams(obj interface{}) error
+	NegotiateFormat(formats ...string) string
+	Negotiate(code int, data interface{}, formats ...string) (err error)
 }
\ No newline at end of file
@@ -0,0 +1,27 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcore
+
+import "io"
+
+// Encoder writes data in a format, such as JSON, it's picked by
+// Ctx.Negotiate with the Accept header of request.
+type Encoder interface {
+	// ContentType is the Content-Type header of response.
+	ContentType() string
+	// MediaTypes are the media types of the format matched with Accept.
+	MediaTypes() []string
+	Encode(w io.Writer, data interface{}) error
+}
+
+// HTMLData is the data of Ctx.Negotiate, the html format renders Template
+// with Data, other formats encode Data only. If Data is a
+// map[string]interface{}, its keys are the variables of template, otherwise
+// the variable is named data.
+type HTMLData struct {
+	Template string
+	Data     interface{}
+}
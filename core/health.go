@@ -0,0 +1,23 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcore
+
+import "context"
+
+// HealthChecker is implemented optionally by services and dependencies, such
+// as database and cache, which can report their health.
+type HealthChecker interface {
+	// HealthCheck returns nil if healthy, it should return when ctx done.
+	HealthCheck(ctx context.Context) error
+}
+
+// HealthCheckerFunc is an adapter to allow the use of ordinary functions as
+// HealthChecker.
+type HealthCheckerFunc func(ctx context.Context) error
+
+func (f HealthCheckerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
@@ -31,6 +31,26 @@ type paramsKey struct{}
 // ParamsKey is the request context key under which URL params are stored.
 var ParamsKey = paramsKey{}
 
+type csrfTokenKey struct{}
+
+// CSRFTokenKey is the Ctx key under which the *CSRFToken of request is
+// stored by the csrf middleware.
+var CSRFTokenKey = csrfTokenKey{}
+
+// CSRFToken is the csrf token of a request, the form field or the header
+// named by FieldName or HeaderName must carry Token in unsafe requests.
+type CSRFToken struct {
+	Token      string
+	FieldName  string
+	HeaderName string
+}
+
+type cspNonceKey struct{}
+
+// CSPNonceKey is the Ctx key under which the Content-Security-Policy nonce
+// string of request is stored by the secure middleware.
+var CSPNonceKey = cspNonceKey{}
+
 // Params is a Param-slice, as returned by the router.
 // The slice is ordered, the first URL parameter is also the first slice value.
 // It is therefore safe to read values by the index.
@@ -143,7 +163,10 @@ type HTTPRouter interface {
 	PATCH(path string, handle Handle)
 	DELETE(path string, handle Handle)
 	ServeFiles(path string, root http.FileSystem)
+	WS(path string, handler WebSocketHandler, upgrader ...WebSocketUpgrader)
 	Lookup(method, path string) (Handle, Params, bool)
+	Allowed(path string) string
+	RouteController(route string) (controller, method string)
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
 }
 type APIServer interface {
@@ -163,6 +186,7 @@ type APIServer interface {
 	ShowErrorStack(isShow bool)
 	Ext(ext string)
 	API(path string, handle func(ctx Ctx), ext ...string)
+	WS(path string, handler WebSocketHandler, upgrader ...WebSocketUpgrader)
 	Group(path string) APIServer
 	PrintRouteTable(w io.Writer)
 	ActiveConnCount() int64
@@ -28,6 +28,7 @@ type AppProvider func(isDefault bool) App
 type CtxProvider func() Ctx
 type ErrorProvider func() Error
 type I18nProvider func(ctx Ctx) (I18n, error)
+type EncoderProvider func(ctx Ctx) Encoder
 
 type ProviderFactory struct {
 	session        map[string]SessionProvider
@@ -48,6 +49,8 @@ type ProviderFactory struct {
 	ctx            map[string]CtxProvider
 	error          map[string]ErrorProvider
 	i18n           map[string]I18nProvider
+	encoder        map[string]EncoderProvider
+	encoderKeys    []string
 }
 
 func (p *ProviderFactory) RegisterSession(key string, session SessionProvider) {
@@ -194,6 +197,24 @@ func (p *ProviderFactory) I18n(key string) I18nProvider {
 	return p.i18n[key]
 }
 
+// RegisterEncoder adds or replaces the encoder of format key, such as json,
+// the keys are the formats of Ctx.Negotiate.
+func (p *ProviderFactory) RegisterEncoder(key string, encoder EncoderProvider) {
+	if _, ok := p.encoder[key]; !ok {
+		p.encoderKeys = append(p.encoderKeys, key)
+	}
+	p.encoder[key] = encoder
+}
+
+func (p *ProviderFactory) Encoder(key string) EncoderProvider {
+	return p.encoder[key]
+}
+
+// Encoders returns the keys of encoders in the order of registration.
+func (p *ProviderFactory) Encoders() []string {
+	return append([]string{}, p.encoderKeys...)
+}
+
 func NewProvider() *ProviderFactory {
 	return &ProviderFactory{
 		session:        map[string]SessionProvider{},
@@ -214,5 +235,6 @@ func NewProvider() *ProviderFactory {
 		ctx:            map[string]CtxProvider{},
 		error:          map[string]ErrorProvider{},
 		i18n:           map[string]I18nProvider{},
+		encoder:        map[string]EncoderProvider{},
 	}
 }
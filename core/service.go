@@ -28,4 +28,8 @@ type ServiceItem struct {
 	AfterInit  func(srv *ServiceItem) (err error)
 	Service    Service
 	ConfigID   string
+	// SocketName is the FileDescriptorName= of systemd socket units, the
+	// sockets passed by systemd socket activation with the name are injected
+	// into Service as its listeners.
+	SocketName string
 }
@@ -0,0 +1,40 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcore
+
+import "time"
+
+// SSEEvent is an event of server-sent events, Data is split into lines,
+// empty ID, Event and zero Retry are omitted.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// SSE is a server-sent events stream, each event is flushed to the client
+// once it's sent. Sending is safe in concurrent goroutines.
+type SSE interface {
+	// LastEventID returns the id of the last event the client received,
+	// it's sent by the client when reconnecting.
+	LastEventID() string
+	Send(event SSEEvent) (err error)
+	Event(event, data string) (err error)
+	JSON(event string, v interface{}) (err error)
+	Comment(text string) (err error)
+	Retry(d time.Duration) (err error)
+	// Done is closed when the client disconnected or Close is called.
+	Done() <-chan struct{}
+	// Close stops the stream, no data is written after it returns.
+	Close()
+}
+
+type sseKey struct{}
+
+// SSEKey is the Ctx key under which the SSE stream started by Ctx.SSE is
+// stored, the server closes it when the handler returns.
+var SSEKey = sseKey{}
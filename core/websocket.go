@@ -0,0 +1,46 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcore
+
+import (
+	"net"
+	"time"
+)
+
+// WebSocketHandler handles an upgraded websocket connection, the connection
+// is closed after the handler returned.
+type WebSocketHandler func(ctx Ctx, conn WebSocketConn)
+
+// WebSocketUpgrader upgrades the request of ctx to a websocket connection,
+// a http error response is written when the upgrade failed.
+type WebSocketUpgrader interface {
+	Upgrade(ctx Ctx) (WebSocketConn, error)
+}
+
+// WebSocketConn is a websocket connection. Only one goroutine can read at a
+// time, writing is safe in concurrent goroutines.
+type WebSocketConn interface {
+	Ctx() Ctx
+	Session() Session
+	Subprotocol() string
+	RemoteAddr() net.Addr
+	LocalAddr() net.Addr
+	SetReadLimit(limit int64)
+	SetReadDeadline(t time.Time) error
+	ReadMessage() (messageType int, data []byte, err error)
+	ReadText() (text string, err error)
+	ReadJSON(v interface{}) (err error)
+	WriteMessage(messageType int, data []byte) (err error)
+	WriteText(text string) (err error)
+	WriteBinary(data []byte) (err error)
+	WriteJSON(v interface{}) (err error)
+	Ping(data []byte) (err error)
+	OnPong(fn func(data []byte))
+	OnClose(fn func())
+	Close() error
+	CloseWithCode(code int, reason string) error
+	IsClosed() bool
+}
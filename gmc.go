@@ -9,6 +9,7 @@ import (
 	gcore "github.com/snail007/gmc/core"
 	gcontroller "github.com/snail007/gmc/http/controller"
 	gcookie "github.com/snail007/gmc/http/cookie"
+	gencoder "github.com/snail007/gmc/http/encoder"
 	grouter "github.com/snail007/gmc/http/router"
 	ghttpserver "github.com/snail007/gmc/http/server"
 	gsession "github.com/snail007/gmc/http/session"
@@ -101,6 +102,9 @@ func init() {
 		var err error
 		gsync.OnceDo("gmc-cache-init", func() {
 			err = gcache.Init(ctx.Config())
+			if err == nil && ctx.App() != nil {
+				ctx.App().OnShutdown(gcache.SaveSnapshots)
+			}
 		})
 		if err != nil {
 			return nil, err
@@ -141,5 +145,30 @@ func init() {
 		return ghttpserver.NewAPIServerForProvider(ctx, address)
 	})
 
+	// the order of encoders is the preference of Ctx.Negotiate.
+	providers.RegisterEncoder(gencoder.JSON, func(ctx gcore.Ctx) gcore.Encoder {
+		return gencoder.NewJSON()
+	})
+
+	providers.RegisterEncoder(gencoder.XML, func(ctx gcore.Ctx) gcore.Encoder {
+		return gencoder.NewXML()
+	})
+
+	providers.RegisterEncoder(gencoder.YAML, func(ctx gcore.Ctx) gcore.Encoder {
+		return gencoder.NewYAML()
+	})
+
+	providers.RegisterEncoder(gencoder.MsgPack, func(ctx gcore.Ctx) gcore.Encoder {
+		return gencoder.NewMsgPack()
+	})
+
+	providers.RegisterEncoder(gencoder.HTML, func(ctx gcore.Ctx) gcore.Encoder {
+		return gencoder.NewHTML(ctx)
+	})
+
+	providers.RegisterEncoder(gencoder.Text, func(ctx gcore.Ctx) gcore.Encoder {
+		return gencoder.NewText()
+	})
+
 	initHelper()
 }
@@ -3,6 +3,7 @@ module github.com/snail007/gmc
 go 1.12
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/dsnet/compress v0.0.1
 	github.com/fsnotify/fsnotify v1.4.9 // indirect
 	github.com/go-sql-driver/mysql v1.5.0
@@ -19,4 +20,5 @@ require (
 	golang.org/x/net v0.0.0-20201224014010-6772e930b67b
 	golang.org/x/text v0.3.3
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -48,6 +48,15 @@ func (this *Controller) MethodCallPre(ctx gcore.Ctx) {
 	this.Cookie = gcore.Providers.Cookies("")(ctx)
 	// 2.init stuff below
 	this.View.SetLayoutDir(this.Config.GetString("template.layout"))
+	if token, ok := ctx.Get(gcore.CSRFTokenKey); ok {
+		this.View.Set("CSRF", token)
+	}
+	if nonce, ok := ctx.Get(gcore.CSPNonceKey); ok {
+		this.View.Set("CSPNonce", nonce)
+	}
+	if can, ok := ctx.Get(gcore.PermissionCheckerKey); ok {
+		this.View.Set("Can", can)
+	}
 
 	//init lang
 	this.initLang()
@@ -0,0 +1,138 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gencoder
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	gcore "github.com/snail007/gmc/core"
+	"gopkg.in/yaml.v2"
+)
+
+// The formats of builtin encoders, they are the keys of
+// gcore.Providers.RegisterEncoder.
+const (
+	JSON    = "json"
+	XML     = "xml"
+	YAML    = "yaml"
+	MsgPack = "msgpack"
+	HTML    = "html"
+	Text    = "text"
+)
+
+// NewJSON returns the encoder of JSON.
+func NewJSON() gcore.Encoder { return jsonEncoder{} }
+
+// NewXML returns the encoder of XML, the data is encoded by encoding/xml, so
+// maps are not supported.
+func NewXML() gcore.Encoder { return xmlEncoder{} }
+
+// NewYAML returns the encoder of YAML, the fields of struct are named by the
+// yaml tag.
+func NewYAML() gcore.Encoder { return yamlEncoder{} }
+
+// NewMsgPack returns the encoder of MessagePack, see EncodeMsgPack.
+func NewMsgPack() gcore.Encoder { return msgpackEncoder{} }
+
+// NewHTML returns the encoder renders gcore.HTMLData by the View of
+// gcore.Providers with the template of ctx.
+func NewHTML(ctx gcore.Ctx) gcore.Encoder { return &htmlEncoder{ctx: ctx} }
+
+// NewText returns the encoder of plain text, the data is formatted by
+// fmt.Fprint.
+func NewText() gcore.Encoder { return textEncoder{} }
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) MediaTypes() []string { return []string{"application/json"} }
+
+func (jsonEncoder) Encode(w io.Writer, data interface{}) error {
+	return json.NewEncoder(w).Encode(data)
+}
+
+type xmlEncoder struct{}
+
+func (xmlEncoder) ContentType() string { return "application/xml; charset=utf-8" }
+
+func (xmlEncoder) MediaTypes() []string { return []string{"application/xml", "text/xml"} }
+
+func (xmlEncoder) Encode(w io.Writer, data interface{}) (err error) {
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return
+	}
+	return xml.NewEncoder(w).Encode(data)
+}
+
+type yamlEncoder struct{}
+
+func (yamlEncoder) ContentType() string { return "application/x-yaml; charset=utf-8" }
+
+func (yamlEncoder) MediaTypes() []string {
+	return []string{"application/x-yaml", "application/yaml", "text/yaml", "text/x-yaml"}
+}
+
+func (yamlEncoder) Encode(w io.Writer, data interface{}) error {
+	return yaml.NewEncoder(w).Encode(data)
+}
+
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string { return "application/msgpack" }
+
+func (msgpackEncoder) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (msgpackEncoder) Encode(w io.Writer, data interface{}) error {
+	return EncodeMsgPack(w, data)
+}
+
+type htmlEncoder struct {
+	ctx gcore.Ctx
+}
+
+func (*htmlEncoder) ContentType() string { return "text/html; charset=utf-8" }
+
+func (*htmlEncoder) MediaTypes() []string { return []string{"text/html", "application/xhtml+xml"} }
+
+func (e *htmlEncoder) Encode(w io.Writer, data interface{}) error {
+	var d gcore.HTMLData
+	switch v := data.(type) {
+	case gcore.HTMLData:
+		d = v
+	case *gcore.HTMLData:
+		d = *v
+	default:
+		return fmt.Errorf("html encoder: gcore.HTMLData expected, got %T", data)
+	}
+	view := gcore.Providers.View("")(w, e.ctx.Template())
+	if m, ok := d.Data.(map[string]interface{}); ok {
+		view.SetMap(m)
+	} else if d.Data != nil {
+		view.Set("data", d.Data)
+	}
+	return view.Render(d.Template).Err()
+}
+
+type textEncoder struct{}
+
+func (textEncoder) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (textEncoder) MediaTypes() []string { return []string{"text/plain"} }
+
+func (textEncoder) Encode(w io.Writer, data interface{}) (err error) {
+	if b, ok := data.([]byte); ok {
+		_, err = w.Write(b)
+		return
+	}
+	_, err = fmt.Fprint(w, data)
+	return
+}
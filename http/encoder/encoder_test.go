@@ -0,0 +1,129 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gencoder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Name  string `json:"name" xml:"name" yaml:"name"`
+	Count int    `json:"count" xml:"count" yaml:"count"`
+}
+
+func encode(e gcore.Encoder, data interface{}) (string, error) {
+	buf := &bytes.Buffer{}
+	err := e.Encode(buf, data)
+	return buf.String(), err
+}
+
+func TestEncoders(t *testing.T) {
+	assert := assert.New(t)
+	v := item{Name: "a", Count: 1}
+	s, err := encode(NewJSON(), v)
+	assert.Nil(err)
+	assert.Equal(`{"name":"a","count":1}`+"\n", s)
+	s, err = encode(NewXML(), v)
+	assert.Nil(err)
+	assert.Equal(`<?xml version="1.0" encoding="UTF-8"?>`+"\n<item><name>a</name><count>1</count></item>", s)
+	s, err = encode(NewYAML(), v)
+	assert.Nil(err)
+	assert.Equal("name: a\ncount: 1\n", s)
+	s, err = encode(NewText(), fmt.Errorf("fail"))
+	assert.Nil(err)
+	assert.Equal("fail", s)
+	s, err = encode(NewText(), []byte("abc"))
+	assert.Nil(err)
+	assert.Equal("abc", s)
+	assert.True(strings.HasPrefix(NewText().ContentType(), NewText().MediaTypes()[0]))
+}
+
+func TestEncodeMsgPack(t *testing.T) {
+	assert := assert.New(t)
+	for _, v := range []struct {
+		data   interface{}
+		expect string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{false, "c2"},
+		{1, "01"},
+		{-1, "ff"},
+		{200, "ccc8"},
+		{-200, "d1ff38"},
+		{70000, "ce00011170"},
+		{uint64(1) << 63, "cf8000000000000000"},
+		{1.5, "cb3ff8000000000000"},
+		{"a", "a161"},
+		{strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{[]int{1, 2}, "920102"},
+		{item{Name: "a", Count: 1}, "82a5636f756e7401a46e616d65a161"},
+	} {
+		buf := &bytes.Buffer{}
+		assert.Nil(EncodeMsgPack(buf, v.data))
+		assert.Equal(v.expect, hex.EncodeToString(buf.Bytes()), fmt.Sprint(v.data))
+	}
+	assert.NotNil(EncodeMsgPack(&bytes.Buffer{}, func() {}))
+}
+
+type fakeCtx struct {
+	gcore.Ctx
+}
+
+func (fakeCtx) Template() gcore.Template {
+	return nil
+}
+
+type fakeView struct {
+	gcore.View
+	w    io.Writer
+	data map[string]interface{}
+}
+
+func (v *fakeView) Set(key string, val interface{}) gcore.View {
+	v.data[key] = val
+	return v
+}
+
+func (v *fakeView) SetMap(d map[string]interface{}) gcore.View {
+	for k, val := range d {
+		v.data[k] = val
+	}
+	return v
+}
+
+func (v *fakeView) Render(tpl string, data ...map[string]interface{}) gcore.View {
+	fmt.Fprintf(v.w, "%s:%v", tpl, v.data)
+	return v
+}
+
+func (v *fakeView) Err() error {
+	return nil
+}
+
+func TestHTML(t *testing.T) {
+	assert := assert.New(t)
+	gcore.Providers.RegisterView("", func(w io.Writer, tpl gcore.Template) gcore.View {
+		return &fakeView{w: w, data: map[string]interface{}{}}
+	})
+	e := NewHTML(fakeCtx{})
+	s, err := encode(e, gcore.HTMLData{Template: "user/list", Data: map[string]interface{}{"a": 1}})
+	assert.Nil(err)
+	assert.Equal("user/list:map[a:1]", s)
+	s, err = encode(e, &gcore.HTMLData{Template: "user/list", Data: 2})
+	assert.Nil(err)
+	assert.Equal("user/list:map[data:2]", s)
+	_, err = encode(e, 1)
+	assert.NotNil(err)
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gencoder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// EncodeMsgPack writes data to w in MessagePack format. data is converted
+// through encoding/json first, so the json tags and json.Marshaler are
+// respected, structs become maps, []byte becomes a base64 string, and
+// numbers are integers if they have no fraction.
+func EncodeMsgPack(w io.Writer, data interface{}) (err error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err = d.Decode(&v); err != nil {
+		return
+	}
+	bw := bufio.NewWriter(w)
+	e := &msgpackWriter{w: bw}
+	e.encode(v)
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+type msgpackWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *msgpackWriter) write(b ...byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+// writeN writes the code and n in size bytes, big endian.
+func (e *msgpackWriter) writeN(code byte, n uint64, size int) {
+	b := make([]byte, 9)
+	b[0] = code
+	binary.BigEndian.PutUint64(b[1:], n)
+	e.write(append(b[:1], b[9-size:]...)...)
+}
+
+func (e *msgpackWriter) encode(v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		e.write(0xc0)
+	case bool:
+		if v {
+			e.write(0xc3)
+		} else {
+			e.write(0xc2)
+		}
+	case json.Number:
+		e.number(v)
+	case string:
+		e.str(v)
+	case []interface{}:
+		e.length(len(v), 0x90, 0xdc, 0xdd)
+		for _, item := range v {
+			e.encode(item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.length(len(v), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			e.str(k)
+			e.encode(v[k])
+		}
+	default:
+		e.err = fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+}
+
+// length writes the length of array or map, fix is the code of fixarray or
+// fixmap.
+func (e *msgpackWriter) length(n int, fix, code16, code32 byte) {
+	switch {
+	case n < 16:
+		e.write(fix | byte(n))
+	case n <= math.MaxUint16:
+		e.writeN(code16, uint64(n), 2)
+	default:
+		e.writeN(code32, uint64(n), 4)
+	}
+}
+
+func (e *msgpackWriter) str(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.write(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.writeN(0xd9, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.writeN(0xda, uint64(n), 2)
+	default:
+		e.writeN(0xdb, uint64(n), 4)
+	}
+	e.write([]byte(s)...)
+}
+
+func (e *msgpackWriter) number(n json.Number) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		e.int(i)
+		return
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		e.writeN(0xcf, u, 8)
+		return
+	}
+	f, err := n.Float64()
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.writeN(0xcb, math.Float64bits(f), 8)
+}
+
+func (e *msgpackWriter) int(i int64) {
+	switch {
+	case i >= 0 && i < 128:
+		e.write(byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		e.writeN(0xcc, uint64(i), 1)
+	case i >= 0 && i <= math.MaxUint16:
+		e.writeN(0xcd, uint64(i), 2)
+	case i >= 0 && i <= math.MaxUint32:
+		e.writeN(0xce, uint64(i), 4)
+	case i >= 0:
+		e.writeN(0xcf, uint64(i), 8)
+	case i >= -32:
+		e.write(byte(i))
+	case i >= math.MinInt8:
+		e.writeN(0xd0, uint64(i), 1)
+	case i >= math.MinInt16:
+		e.writeN(0xd1, uint64(i), 2)
+	case i >= math.MinInt32:
+		e.writeN(0xd2, uint64(i), 4)
+	default:
+		e.writeN(0xd3, uint64(i), 8)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"bytes"
 	"fmt"
 	gcore "github.com/snail007/gmc/core"
+	gwebsocket "github.com/snail007/gmc/http/websocket"
 	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gtrace "github.com/snail007/gmc/module/trace"
 	"io"
 	"net/http"
 	"os"
@@ -54,6 +56,13 @@ type HTTPRouter struct {
 	ns  string
 	ext string
 	ctx gcore.Ctx
+	// controllers of route paths, shared by groups
+	controllers map[string]routeController
+}
+
+type routeController struct {
+	controller string
+	method     string
 }
 
 func NewHTTPRouter(ctx gcore.Ctx) *HTTPRouter {
@@ -65,8 +74,9 @@ func NewHTTPRouter(ctx gcore.Ctx) *HTTPRouter {
 			HandleOPTIONS:          true,
 			SaveMatchedRoutePath:   true, //if true this.Args in controller is always not be nil, if false it maybe nil.
 		},
-		ns:  "/",
-		ctx: ctx,
+		ns:          "/",
+		ctx:         ctx,
+		controllers: map[string]routeController{},
 	}
 	return hr
 }
@@ -77,10 +87,11 @@ func (s *HTTPRouter) Group(ns string) gcore.HTTPRouter {
 		ns += "/"
 	}
 	return &HTTPRouter{
-		Router: s.Router,
-		hr:     s,
-		ns:     ns,
-		ctx:    s.ctx,
+		Router:      s.Router,
+		hr:          s,
+		ns:          ns,
+		ctx:         s.ctx,
+		controllers: s.controllers,
 	}
 }
 func (s *HTTPRouter) Namespace() string {
@@ -158,6 +169,14 @@ func (s *HTTPRouter) visit(n *node, prefix, m string, p *map[string][]string) {
 	}
 }
 
+// RouteController returns the names of controller and method bound to the
+// route path, such as `Demo` and `Index`, empty if route is not bound to a
+// controller.
+func (s *HTTPRouter) RouteController(route string) (controller, method string) {
+	c := s.controllers[route]
+	return c.controller, c.method
+}
+
 // ControllerMethod binds a controller's method to router
 func (s *HTTPRouter) ControllerMethod(urlPath string, obj gcore.Controller, method string) {
 	s.controller(urlPath, obj, method)
@@ -181,6 +200,10 @@ func (s *HTTPRouter) controller(urlPath string, obj interface{}, method string,
 
 	beforeIsFound := allMethods["Before"]
 	afterIsFound := allMethods["After"]
+	objType := reflect.TypeOf(obj)
+	if objType.Kind() == reflect.Ptr {
+		objType = objType.Elem()
+	}
 
 	for _, objMethod := range bindMethods {
 		path := ""
@@ -200,6 +223,7 @@ func (s *HTTPRouter) controller(urlPath string, obj interface{}, method string,
 			path = p + strings.ToLower(objMethod) + ext1
 		}
 		objMethod0 := objMethod
+		s.controllers[s.path(path)] = routeController{controller: objType.Name(), method: objMethod}
 		s.HandleAny(path, func(w http.ResponseWriter, _ *http.Request, ps gcore.Params) {
 			reqCtx := w.(*ghttputil.ResponseWriter).Data("ctx").(gcore.Ctx)
 			// fix param not contains matched route path
@@ -212,6 +236,16 @@ func (s *HTTPRouter) controller(urlPath string, obj interface{}, method string,
 			} else {
 				val = obj0
 			}
+			// trace the controller method if the request is traced.
+			r := reqCtx.Request()
+			if c, span := gtrace.StartChild(r.Context(), val.Type().Name()+"."+objMethod0, gtrace.KindInternal); span != nil {
+				span.SetAttribute("code.function", objMethod0)
+				reqCtx.SetRequest(r.WithContext(c))
+				defer func() {
+					reqCtx.SetRequest(r)
+					span.End()
+				}()
+			}
 			vp := reflect.New(val.Type())
 			vp.Elem().Set(val)
 			objv := vp.Interface()
@@ -301,3 +335,18 @@ func (s *HTTPRouter) HandlerFuncAny(path string, handler http.HandlerFunc) {
 		s.HandlerFunc(method, path, handler)
 	}
 }
+
+// WS registers a websocket handler with the given path, the request is
+// upgraded by upgrader, default is gwebsocket.NewUpgrader(), and the
+// connection is closed after the handler returned.
+func (s *HTTPRouter) WS(path string, handler gcore.WebSocketHandler, upgrader ...gcore.WebSocketUpgrader) {
+	var u gcore.WebSocketUpgrader
+	if len(upgrader) > 0 {
+		u = upgrader[0]
+	}
+	s.Handle(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request, ps gcore.Params) {
+		reqCtx := w.(*ghttputil.ResponseWriter).Data("ctx").(gcore.Ctx)
+		reqCtx.SetParam(ps)
+		gwebsocket.Serve(reqCtx, u, handler)
+	})
+}
@@ -195,6 +195,9 @@ func (r *Router) saveMatchedRoutePath(path string, handle gcore.Handle) gcore.Ha
 			ps[0] = gcore.Param{Key: gcore.MatchedRoutePathParam, Value: path}
 			handle(w, req, ps)
 			r.putParams(psp)
+		} else if len(ps) > 0 && ps[len(ps)-1].Key == gcore.MatchedRoutePathParam {
+			// the params of Lookup contain it already.
+			handle(w, req, ps)
 		} else {
 			ps = append(ps, gcore.Param{Key: gcore.MatchedRoutePathParam, Value: path})
 			handle(w, req, ps)
@@ -347,13 +350,21 @@ func (r *Router) recv(w http.ResponseWriter, req *http.Request) {
 // If the path was found, it returns the handle function and the path parameter
 // values. Otherwise the third return value indicates whether a redirection to
 // the same path with an extra / without the trailing slash should be performed.
+// If SaveMatchedRoutePath is set, the path parameter values contain the
+// matched route path too, so it's known before the handle is called.
 func (r *Router) Lookup(method, path string) (gcore.Handle, gcore.Params, bool) {
 	if root := r.trees[method]; root != nil {
-		handle, ps, tsr := root.getValue(path, r.getParams)
+		handle, ps, tsr, fullPath := root.getRoute(path, r.getParams)
 		if handle == nil {
 			r.putParams(ps)
 			return nil, nil, tsr
 		}
+		if r.SaveMatchedRoutePath {
+			if ps == nil {
+				ps = r.getParams()
+			}
+			*ps = append(*ps, gcore.Param{Key: gcore.MatchedRoutePathParam, Value: fullPath})
+		}
 		if ps == nil {
 			return handle, nil, tsr
 		}
@@ -362,6 +373,16 @@ func (r *Router) Lookup(method, path string) (gcore.Handle, gcore.Params, bool)
 	return nil, nil, false
 }
 
+// Allowed returns the methods of path for the automatic reply of OPTIONS
+// request, such as "GET, OPTIONS, POST", empty if HandleOPTIONS is false or
+// path has no handler.
+func (r *Router) Allowed(path string) string {
+	if !r.HandleOPTIONS {
+		return ""
+	}
+	return r.allowed(path, http.MethodOptions)
+}
+
 func (r *Router) allowed(path, reqMethod string) (allow string) {
 	allowed := make([]string, 0, 9)
 
@@ -673,6 +673,24 @@ func TestRouterMatchedRoutePath(t *testing.T) {
 	if !routed3 {
 		t.Fatal("Routing failed!")
 	}
+
+	// the params of Lookup contain the matched route before calling handle.
+	for path, want := range map[string]string{"/": route3, "/user/gopher/details": route2} {
+		handle, ps, _ := router.Lookup(http.MethodGet, path)
+		if route := ps.MatchedRoutePath(); route != want {
+			t.Fatalf("Wrong matched route of Lookup: want %s, got %s", want, route)
+		}
+		// the handle checks the matched route of params too.
+		handle(w, r, ps)
+	}
+
+	// empty params not from Lookup get the matched route too.
+	routed3 = false
+	handle, _, _ := router.Lookup(http.MethodGet, "/")
+	handle(w, r, gcore.Params{})
+	if !routed3 {
+		t.Fatal("Routing failed!")
+	}
 }
 
 type mockFileSystem struct {
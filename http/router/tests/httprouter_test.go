@@ -47,6 +47,23 @@ func TestControllerMethod(t *testing.T) {
 	h, _, _ := r.Lookup("GET", "/method/hello")
 	assert.NotNil(h)
 }
+func TestRouteController(t *testing.T) {
+	assert := assert.New(t)
+	r := grouter.NewHTTPRouter(gctx.NewCtx())
+	r.ControllerMethod("/method/:name", new(Controller), "TestMethod")
+	r.Group("/v1").Controller("/user", new(Controller))
+	_, ps, _ := r.Lookup("GET", "/v1/user/method1")
+	assert.Equal("/v1/user/method1", ps.MatchedRoutePath())
+	for route, want := range map[string][]string{
+		"/method/:name":       {"Controller", "TestMethod"},
+		"/v1/user/method1":    {"Controller", "Method1"},
+		"/v1/user/testmethod": {"Controller", "TestMethod"},
+		"/user/method1":       {"", ""},
+	} {
+		c, m := r.RouteController(route)
+		assert.Equal(want, []string{c, m}, route)
+	}
+}
 func TestGroup_1(t *testing.T) {
 	assert := assert.New(t)
 	r := grouter.NewHTTPRouter(gctx.NewCtx())
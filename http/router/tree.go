@@ -85,6 +85,8 @@ type node struct {
 	priority  uint32
 	children  []*node
 	handle    gcore.Handle
+	// fullPath is the route path of handle.
+	fullPath string
 }
 
 // Increments priority of the given child and reorders if necessary
@@ -139,6 +141,7 @@ walk:
 				indices:   n.indices,
 				children:  n.children,
 				handle:    n.handle,
+				fullPath:  n.fullPath,
 				priority:  n.priority - 1,
 			}
 
@@ -147,6 +150,7 @@ walk:
 			n.indices = string([]byte{n.path[i]})
 			n.path = path[:i]
 			n.handle = nil
+			n.fullPath = ""
 			n.wildChild = false
 		}
 
@@ -216,6 +220,7 @@ walk:
 			panic("a handle is already registered for path '" + fullPath + "'")
 		}
 		n.handle = handle
+		n.fullPath = fullPath
 		return
 	}
 }
@@ -277,6 +282,7 @@ func (n *node) insertChild(path, fullPath string, handle gcore.Handle) {
 
 			// Otherwise we're done. Insert the handle in the new leaf
 			n.handle = handle
+			n.fullPath = fullPath
 			return
 		}
 
@@ -312,6 +318,7 @@ func (n *node) insertChild(path, fullPath string, handle gcore.Handle) {
 			path:     path[i:],
 			nType:    catchAll,
 			handle:   handle,
+			fullPath: fullPath,
 			priority: 1,
 		}
 		n.children = []*node{child}
@@ -322,6 +329,7 @@ func (n *node) insertChild(path, fullPath string, handle gcore.Handle) {
 	// If no wildcard was found, simply insert the path and handle
 	n.path = path
 	n.handle = handle
+	n.fullPath = fullPath
 }
 
 // Returns the handle registered with the given path (key). The values of
@@ -330,6 +338,12 @@ func (n *node) insertChild(path, fullPath string, handle gcore.Handle) {
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
 func (n *node) getValue(path string, params func() *gcore.Params) (handle gcore.Handle, ps *gcore.Params, tsr bool) {
+	handle, ps, tsr, _ = n.getRoute(path, params)
+	return
+}
+
+// getRoute is getValue, and returns the route path of handle too.
+func (n *node) getRoute(path string, params func() *gcore.Params) (handle gcore.Handle, ps *gcore.Params, tsr bool, fullPath string) {
 walk: // Outer loop for walking the tree
 	for {
 		prefix := n.path
@@ -394,6 +408,7 @@ walk: // Outer loop for walking the tree
 					}
 
 					if handle = n.handle; handle != nil {
+						fullPath = n.fullPath
 						return
 					} else if len(n.children) == 1 {
 						// No handle found. Check if a handle for this path + a
@@ -420,6 +435,7 @@ walk: // Outer loop for walking the tree
 					}
 
 					handle = n.handle
+					fullPath = n.fullPath
 					return
 
 				default:
@@ -430,6 +446,7 @@ walk: // Outer loop for walking the tree
 			// We should have reached the node containing the handle.
 			// Check if this node has a handle registered.
 			if handle = n.handle; handle != nil {
+				fullPath = n.fullPath
 				return
 			}
 
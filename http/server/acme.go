@@ -0,0 +1,162 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACMEChallengePath is the url path prefix of ACME HTTP-01 challenges.
+const ACMEChallengePath = "/.well-known/acme-challenge/"
+
+// ACMEConfig is the options of obtaining certificates from an ACME CA,
+// such as Let's Encrypt.
+type ACMEConfig struct {
+	// Domains is the list of host names certificates can be obtained for.
+	Domains []string
+	// Email is the contact of the ACME account, optional.
+	Email string
+	// CacheDir is the directory certificates and the account key stored in.
+	CacheDir string
+	// DirectoryURL is the ACME directory endpoint, default is Let's Encrypt
+	// production endpoint.
+	DirectoryURL string
+	// RenewBefore is how early certificates are renewed before they expire,
+	// 0 means 30 days.
+	RenewBefore time.Duration
+	// HTTPListen is the address of a plain http server answers HTTP-01
+	// challenges, other requests on it are redirected to https. Empty means
+	// only TLS-ALPN-01 challenges are used, unless challenges are proxied to
+	// the router.
+	HTTPListen string
+	// HTTPClient is used to talk with the ACME CA, optional.
+	HTTPClient *http.Client
+}
+
+// NewACMEConfigFromConfig parses the `acme` sub section of section in
+// app.toml, such as [httpserver.acme].
+func NewACMEConfigFromConfig(cfg gcore.Config, section string) *ACMEConfig {
+	prefix := section + ".acme."
+	c := &ACMEConfig{
+		Domains:      cfg.GetStringSlice(prefix + "domains"),
+		Email:        cfg.GetString(prefix + "email"),
+		CacheDir:     cfg.GetString(prefix + "cachedir"),
+		DirectoryURL: cfg.GetString(prefix + "directoryurl"),
+		RenewBefore:  time.Duration(cfg.GetInt(prefix+"renewbefore")) * time.Hour * 24,
+		HTTPListen:   cfg.GetString(prefix + "httplisten"),
+	}
+	if cfg.GetBool(prefix + "insecureskipverify") {
+		c.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+	}
+	return c
+}
+
+// newACMEManager creates the autocert.Manager of c.
+func newACMEManager(c *ACMEConfig) (m *autocert.Manager, err error) {
+	if len(c.Domains) == 0 {
+		err = gcore.Providers.Error("")().New("acme domains is required")
+		return
+	}
+	if c.CacheDir == "" {
+		err = gcore.Providers.Error("")().New("acme cache dir is required")
+		return
+	}
+	m = &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(c.CacheDir),
+		HostPolicy:  autocert.HostWhitelist(c.Domains...),
+		RenewBefore: c.RenewBefore,
+		Email:       c.Email,
+	}
+	if c.DirectoryURL != "" || c.HTTPClient != nil {
+		m.Client = &acme.Client{
+			DirectoryURL: c.DirectoryURL,
+			HTTPClient:   c.HTTPClient,
+		}
+	}
+	return
+}
+
+// initACME creates the tls.Config serves the certificates from the ACME CA,
+// and the plain http server answers HTTP-01 challenges if configured.
+func (s *HTTPServer) initACME(c *ACMEConfig) (err error) {
+	m, err := newACMEManager(c)
+	if err != nil {
+		return
+	}
+	tlsCfg := m.TLSConfig()
+	if err = applyTLSOptions(tlsCfg, s.config, "httpserver"); err != nil {
+		return
+	}
+	s.acme = m
+	s.acmeHTTPListen = c.HTTPListen
+	s.server.TLSConfig = tlsCfg
+	return
+}
+
+// initACMEChallenge registers the HTTP-01 challenges handler to router, so
+// the challenges can be answered when the http requests are proxied to the
+// server, must be called after router inited.
+func (s *HTTPServer) initACMEChallenge() {
+	if s.acme == nil {
+		return
+	}
+	h := s.acme.HTTPHandler(nil)
+	s.router.HandlerFunc("GET", ACMEChallengePath+":token", h.ServeHTTP)
+}
+
+// ACMEManager returns the ACME certificates manager, it's nil if
+// [httpserver.acme] is not enabled.
+func (s *HTTPServer) ACMEManager() *autocert.Manager {
+	return s.acme
+}
+
+// listenACMEHTTP starts the plain http server of challenges, the challenge
+// requests are served by router, others are redirected to https.
+func (s *HTTPServer) listenACMEHTTP() (err error) {
+	if s.acme == nil || s.acmeHTTPListen == "" {
+		return
+	}
+	l, err := net.Listen("tcp", s.acmeHTTPListen)
+	if err != nil {
+		return
+	}
+	s.acmeServer = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, ACMEChallengePath) {
+				s.router.ServeHTTP(w, r)
+				return
+			}
+			if r.Method != "GET" && r.Method != "HEAD" {
+				http.Error(w, "Use HTTPS", http.StatusBadRequest)
+				return
+			}
+			host := r.Host
+			if h, _, e := net.SplitHostPort(host); e == nil {
+				host = h
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusFound)
+		}),
+		Addr:     l.Addr().String(),
+		ErrorLog: s.server.ErrorLog,
+	}
+	go s.acmeServer.Serve(l)
+	s.logger.Infof("acme challenge server listen on http://%s", l.Addr())
+	return
+}
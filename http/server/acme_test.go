@@ -0,0 +1,94 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/acme"
+)
+
+func TestNewACMEConfigFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := mockConfig()
+	c := NewACMEConfigFromConfig(cfg, "httpserver")
+	assert.Empty(c.Domains)
+	assert.Equal(time.Hour*24*30, c.RenewBefore)
+	assert.Nil(c.HTTPClient)
+	_, err := newACMEManager(c)
+	assert.NotNil(err)
+
+	cfg.Set("httpserver.acme.domains", []string{"example.com"})
+	cfg.Set("httpserver.acme.directoryurl", "https://127.0.0.1:14000/dir")
+	cfg.Set("httpserver.acme.insecureskipverify", true)
+	c = NewACMEConfigFromConfig(cfg, "httpserver")
+	assert.Equal([]string{"example.com"}, c.Domains)
+	assert.NotNil(c.HTTPClient)
+	m, err := newACMEManager(c)
+	assert.Nil(err)
+	assert.Equal("https://127.0.0.1:14000/dir", m.Client.DirectoryURL)
+
+	c.CacheDir = ""
+	_, err = newACMEManager(c)
+	assert.NotNil(err)
+}
+
+func TestHTTPServer_ACME(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc-acme")
+	defer os.RemoveAll(dir)
+	s := mockHTTPServer()
+	cfg := mockConfig()
+	cfg.Set("httpserver.tlsenable", true)
+	cfg.Set("httpserver.acme.enable", true)
+	cfg.Set("httpserver.acme.domains", []string{"example.com"})
+	cfg.Set("httpserver.acme.cachedir", dir)
+	cfg.Set("httpserver.acme.httplisten", "127.0.0.1:0")
+	s.SetConfig(cfg)
+	assert.Nil(s.initTLSConfig())
+	assert.NotNil(s.ACMEManager())
+	assert.Contains(s.server.TLSConfig.NextProtos, acme.ALPNProto)
+	assert.Equal(uint16(tls.VersionTLS12), s.server.TLSConfig.MinVersion)
+
+	// the host policy rejects other domains without talking to the CA.
+	_, err := s.server.TLSConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.com"})
+	assert.NotNil(err)
+
+	s.initACMEChallenge()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://other.com"+ACMEChallengePath+"token", nil)
+	s.ServeHTTP(w, r)
+	assert.Equal(http.StatusForbidden, w.Code)
+	w, r = mockRequest(ACMEChallengePath + "token")
+	s.ServeHTTP(w, r)
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	assert.Nil(s.listenACMEHTTP())
+	defer s.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get("http://" + s.acmeServer.Addr + "/foo?a=1")
+	if assert.Nil(err) {
+		resp.Body.Close()
+		assert.Equal(http.StatusFound, resp.StatusCode)
+		assert.Equal("https://127.0.0.1/foo?a=1", resp.Header.Get("Location"))
+	}
+	r, _ = http.NewRequest("GET", "http://"+s.acmeServer.Addr+ACMEChallengePath+"token", nil)
+	r.Host = "example.com"
+	resp, err = client.Do(r)
+	if assert.Nil(err) {
+		resp.Body.Close()
+		assert.Equal(http.StatusNotFound, resp.StatusCode)
+	}
+}
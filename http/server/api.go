@@ -8,11 +8,9 @@ package ghttpserver
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	gcore "github.com/snail007/gmc/core"
 	"io"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
@@ -27,6 +25,7 @@ import (
 
 type APIServer struct {
 	listener          net.Listener
+	listeners         []net.Listener
 	server            *http.Server
 	address           string
 	router            gcore.HTTPRouter
@@ -35,6 +34,7 @@ type APIServer struct {
 	handle500         func(ctx gcore.Ctx, err interface{})
 	isShowErrorStack  bool
 	certFile, keyFile string
+	certManager       *CertManager
 	middleware0       []gcore.Middleware
 	middleware1       []gcore.Middleware
 	middleware2       []gcore.Middleware
@@ -86,31 +86,42 @@ func NewAPIServer(ctx gcore.Ctx, address string) *APIServer {
 }
 
 func NewDefaultAPIServer(ctx gcore.Ctx, config gcore.Config) (api *APIServer, err error) {
-	api = NewAPIServer(ctx, config.GetString("apiserver.listen"))
+	api = NewAPIServer(ctx, strings.Join(config.GetStringSlice("apiserver.listen"), ","))
 	if config.GetBool("apiserver.tlsenable") {
-		tlsCfg := &tls.Config{}
-		if config.GetBool("apiserver.tlsclientauth") {
-			tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
-		}
-		clientCertPool := x509.NewCertPool()
-		caBytes, e := ioutil.ReadFile(config.GetString("apiserver.tlsclientsca"))
+		tlsCfg, m, e := newTLSConfig(config, "apiserver", api.logger)
 		if e != nil {
 			return nil, e
 		}
-		ok := clientCertPool.AppendCertsFromPEM(caBytes)
-		if !ok {
-			api = nil
-			err = gcore.Providers.Error("")().New(("failed to parse tls clients root certificate"))
-			return
-		}
-		tlsCfg.ClientCAs = clientCertPool
+		api.certManager = m
 		api.server.TLSConfig = tlsCfg
 	}
 	api.config = config
+	bindHealth(config, "apiserver", api.router)
+	bindTrace(config, api)
+	bindMetrics(config, "apiserver", api.router, api)
+	bindSecure(config, "apiserver", api)
+	if err = bindCORS(config, "apiserver", api); err != nil {
+		return nil, err
+	}
+	if err = bindCompress(config, "apiserver", api); err != nil {
+		return nil, err
+	}
 	api.ShowErrorStack(config.GetBool("apiserver.showerrorstack"))
+	api.server.SetKeepAlivesEnabled(config.GetBool("apiserver.keepalive"))
+	api.server.IdleTimeout = time.Duration(config.GetInt("apiserver.idletimeout")) * time.Second
+	err = api.ConfigureHTTP2(NewHTTP2ConfigFromConfig(config, "apiserver"))
+	if err != nil {
+		api = nil
+	}
 	return
 }
 
+// ConfigureHTTP2 applies the HTTP/2 options, it must be called once before
+// Run, NewDefaultAPIServer calls it with [apiserver.http2] of app.toml.
+func (this *APIServer) ConfigureHTTP2(c *HTTP2Config) (err error) {
+	return configureHTTP2(this.server, c)
+}
+
 func (this *APIServer) Ctx() gcore.Ctx {
 	return this.ctx
 }
@@ -131,6 +142,7 @@ func (this *APIServer) initRequestCtx(w http.ResponseWriter, r *http.Request) gc
 func (this *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	reqCtx := this.initRequestCtx(w, r)
 	defer func() {
+		closeSSE(reqCtx)
 		// middleware3
 		this.callMiddleware(reqCtx, this.middleware3)
 	}()
@@ -142,6 +154,9 @@ func (this *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h, params, _ := this.router.Lookup(r.Method, r.URL.Path)
 	if h != nil {
 		reqCtx.SetParam(params)
+		if _, method := this.router.RouteController(params.MatchedRoutePath()); method != "" {
+			reqCtx.SetControllerMethod(method)
+		}
 		// middleware1
 		if this.callMiddleware(reqCtx, this.middleware1) {
 			return
@@ -151,6 +166,7 @@ func (this *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		status := ""
 		err := this.call(func() { h(reqCtx.Response(), reqCtx.Request(), reqCtx.Param()) })
 		reqCtx.SetTimeUsed(time.Now().Sub(start))
+		closeSSE(reqCtx)
 		if err != nil {
 			status = fmt.Sprintf("%s", err)
 			switch status {
@@ -166,7 +182,7 @@ func (this *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-	} else {
+	} else if !serveOPTIONS(this.router, reqCtx) {
 		this.handler404(reqCtx)
 	}
 }
@@ -179,6 +195,12 @@ func (this *APIServer) Server() *http.Server {
 func (this *APIServer) Router() gcore.HTTPRouter {
 	return this.router
 }
+
+// CertManager returns the certificates manager created from [apiserver] of
+// app.toml, it's nil if tls is not enabled there.
+func (this *APIServer) CertManager() *CertManager {
+	return this.certManager
+}
 func (this *APIServer) SetTLSFile(certFile, keyFile string) {
 	this.certFile, this.keyFile = certFile, keyFile
 }
@@ -221,11 +243,19 @@ func (this *APIServer) API(path string, handle func(ctx gcore.Ctx), ext ...strin
 		// cover
 		ext1 = ext[0]
 	}
-	this.router.HandleAny(path+ext1, func(w http.ResponseWriter, _ *http.Request, _ gcore.Params) {
-		handle(w.(*ghttputil.ResponseWriter).Data("ctx").(gcore.Ctx))
+	this.router.HandleAny(path+ext1, func(w http.ResponseWriter, _ *http.Request, ps gcore.Params) {
+		reqCtx := w.(*ghttputil.ResponseWriter).Data("ctx").(gcore.Ctx)
+		// fix param not contains matched route path
+		reqCtx.SetParam(ps)
+		handle(reqCtx)
 	})
 }
 
+// WS registers a websocket handler, see HTTPRouter.WS.
+func (this *APIServer) WS(path string, handler gcore.WebSocketHandler, upgrader ...gcore.WebSocketUpgrader) {
+	this.router.WS(path, handler, upgrader...)
+}
+
 func (this *APIServer) Group(path string) gcore.APIServer {
 	newAPI := *this
 	newAPI.router = this.router.Group(path)
@@ -237,39 +267,67 @@ func (this *APIServer) PrintRouteTable(w io.Writer) {
 	this.router.PrintRouteTable(w)
 }
 func (this *APIServer) Run() (err error) {
-	if this.listener == nil {
-		this.listener, err = net.Listen("tcp", this.address)
-		if err != nil {
-			return
+	if len(this.listeners) == 0 {
+		var opt *UnixSocketOptions
+		if this.config != nil {
+			opt, err = NewUnixSocketOptionsFromConfig(this.config, "apiserver")
+			if err != nil {
+				return
+			}
+		}
+		addrs := ListenAddrs(this.address)
+		if len(addrs) == 0 {
+			addrs = []string{""}
+		}
+		for _, addr := range addrs {
+			l, e := Listen(addr, opt)
+			if e != nil {
+				for _, v := range this.listeners {
+					v.Close()
+				}
+				this.listeners = nil
+				return e
+			}
+			this.listeners = append(this.listeners, l)
 		}
 	}
+	this.listener = this.listeners[0]
 	if this.config != nil && this.config.GetBool("apiserver.printroute") {
 		this.router.PrintRouteTable(os.Stdout)
 	}
-	this.address = this.listener.Addr().String()
-	go func() {
-		var err error
-		if this.certFile != "" && this.keyFile != "" {
-			this.logger.Infof("api server on https://%s", this.address)
-			err = this.server.ServeTLS(this.listener, this.certFile, this.keyFile)
-		} else {
-			this.logger.Infof("api server on http://%s", this.address)
-			err = this.server.Serve(this.listener)
-		}
-		if err != nil {
-			if strings.Contains(err.Error(), "closed") {
-				if this.isShutdown {
-					this.logger.Infof("api server graceful shutdown on %s", this.address)
-				} else {
-					this.logger.Infof("api server closed on %s", this.address)
-					this.server.Close()
-				}
+	this.address = listenersAddr(this.listeners)
+	for _, l := range this.listeners {
+		go this.serve(l)
+	}
+	return
+}
+
+func (this *APIServer) serve(l net.Listener) {
+	var err error
+	addr := listenerAddr(l)
+	if this.certFile != "" && this.keyFile != "" {
+		this.logger.Infof("api server on https://%s", addr)
+		err = this.server.ServeTLS(l, this.certFile, this.keyFile)
+	} else if this.certManager != nil {
+		// certificates are served by tls.Config.GetCertificate
+		this.logger.Infof("api server on https://%s", addr)
+		err = this.server.ServeTLS(l, "", "")
+	} else {
+		this.logger.Infof("api server on http://%s", addr)
+		err = this.server.Serve(l)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "closed") {
+			if this.isShutdown {
+				this.logger.Infof("api server graceful shutdown on %s", addr)
 			} else {
-				this.logger.Warnf("api server exited unexpectedly on %s, error : %s", this.address, err)
+				this.logger.Infof("api server closed on %s", addr)
+				this.server.Close()
 			}
+		} else {
+			this.logger.Warnf("api server exited unexpectedly on %s, error : %s", addr, err)
 		}
-	}()
-	return
+	}
 }
 func (s *APIServer) ActiveConnCount() int64 {
 	return atomic.LoadInt64(s.connCnt)
@@ -280,12 +338,17 @@ func (s *APIServer) connState(c net.Conn, st http.ConnState) {
 	switch st {
 	case http.StateNew:
 		atomic.AddInt64(s.connCnt, 1)
+		if c.RemoteAddr().String() == "" {
+			// unix socket connections have no remote address to be distinguished.
+			return
+		}
 		s.remoteAddrDataMap.Store(c.RemoteAddr().String(), remoteAddrItem{
 			remoteAddr: c.RemoteAddr().String(),
 			localAddr:  c.LocalAddr().String(),
 			conn:       c,
 		})
-	case http.StateClosed:
+	case http.StateClosed, http.StateHijacked:
+		// hijacked connections, such as websocket and h2c, are not tracked by http.Server any more.
 		atomic.AddInt64(s.connCnt, -1)
 		s.remoteAddrDataMap.Delete(c.RemoteAddr().String())
 	}
@@ -352,6 +415,9 @@ func (this *APIServer) Start() (err error) {
 
 //Stop implements service.Service Stop
 func (this *APIServer) Stop() {
+	if this.certManager != nil {
+		this.certManager.Stop()
+	}
 	this.server.Close()
 }
 
@@ -361,6 +427,10 @@ func (this *APIServer) GracefulStop() {
 		return
 	}
 	this.isShutdown = true
+	drain(this.config, "apiserver")
+	if this.certManager != nil {
+		this.certManager.Stop()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 	this.server.Shutdown(ctx)
@@ -374,12 +444,13 @@ func (this *APIServer) SetLog(l gcore.Logger) {
 
 //InjectListeners implements service.Service InjectListeners
 func (this *APIServer) InjectListeners(l []net.Listener) {
+	this.listeners = l
 	this.listener = l[0]
 }
 
 //Listener implements service.Service Listener
 func (this *APIServer) Listeners() []net.Listener {
-	return []net.Listener{this.listener}
+	return this.listeners
 }
 
 func (this *APIServer) Listener() net.Listener {
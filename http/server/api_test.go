@@ -6,10 +6,20 @@
 package ghttpserver
 
 import (
+	"bufio"
+	"context"
 	gcore "github.com/snail007/gmc/core"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	ghttputil "github.com/snail007/gmc/internal/util/http"
+	ghealth "github.com/snail007/gmc/module/health"
+	gmetrics "github.com/snail007/gmc/module/metrics"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -131,3 +141,165 @@ func TestHandle500_1(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal("Internal Server Error", data)
 }
+
+func TestAPI_WS(t *testing.T) {
+	assert := assert.New(t)
+	api := NewAPIServer(gcore.Providers.Ctx("")(), ":")
+	api.WS("/ws/:name", func(ctx gcore.Ctx, conn gcore.WebSocketConn) {
+		text, err := conn.ReadText()
+		if err != nil {
+			return
+		}
+		conn.WriteText(ctx.GetParam("name") + ":" + text)
+	})
+	s := httptest.NewServer(api)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/ws/foo")
+	assert.Nil(err)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	c, err := net.Dial("tcp", strings.TrimPrefix(s.URL, "http://"))
+	assert.Nil(err)
+	defer c.Close()
+	c.Write([]byte("GET /ws/foo HTTP/1.1\r\nHost: " + c.RemoteAddr().String() + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+	br := bufio.NewReader(c)
+	resp, err = http.ReadResponse(br, nil)
+	assert.Nil(err)
+	assert.Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal("s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	// a masked text frame "hi" with a zero mask key.
+	c.Write([]byte{0x81, 0x82, 0, 0, 0, 0, 'h', 'i'})
+	h := make([]byte, 2)
+	_, err = br.Read(h)
+	assert.Nil(err)
+	assert.Equal(byte(0x81), h[0])
+	data := make([]byte, int(h[1]))
+	_, err = br.Read(data)
+	assert.Nil(err)
+	assert.Equal("foo:hi", string(data))
+	// close frame is sent after the handler returned.
+	_, err = br.Read(h)
+	assert.Nil(err)
+	assert.Equal(byte(0x88), h[0])
+}
+
+func TestAPI_SSE(t *testing.T) {
+	assert := assert.New(t)
+	api := NewAPIServer(gcore.Providers.Ctx("")(), ":")
+	api.API("/events", func(ctx gcore.Ctx) {
+		sse, err := ctx.SSE()
+		if err != nil {
+			return
+		}
+		defer sse.Close()
+		sse.Send(gcore.SSEEvent{ID: "1", Data: "hello"})
+		<-sse.Done()
+	})
+	s := httptest.NewServer(api)
+	defer s.Close()
+	resp, err := http.Get(s.URL + "/events")
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal("text/event-stream; charset=utf-8", resp.Header.Get("Content-Type"))
+	br := bufio.NewReader(resp.Body)
+	line, _ := br.ReadString('\n')
+	assert.Equal("id: 1\n", line)
+	line, _ = br.ReadString('\n')
+	assert.Equal("data: hello\n", line)
+}
+
+func TestAPI_SSENotClosed(t *testing.T) {
+	assert := assert.New(t)
+	api := NewAPIServer(gcore.Providers.Ctx("")(), ":")
+	stream := make(chan gcore.SSE, 1)
+	api.API("/events", func(ctx gcore.Ctx) {
+		sse, err := ctx.SSE(time.Millisecond)
+		if err != nil {
+			return
+		}
+		stream <- sse
+		sse.Event("", "hello")
+		time.Sleep(time.Millisecond * 20)
+	})
+	api.AddMiddleware3(func(ctx gcore.Ctx) bool {
+		// the heartbeat must be stopped before middleware3.
+		time.Sleep(time.Millisecond * 20)
+		ctx.Response().Write([]byte(": end\n\n"))
+		return false
+	})
+	s := httptest.NewServer(api)
+	defer s.Close()
+	resp, err := http.Get(s.URL + "/events")
+	assert.Nil(err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(string(body), "data: hello\n\n"))
+	assert.True(strings.HasSuffix(string(body), "\n\n: end\n\n"))
+	assert.Contains(string(body), ": heartbeat\n\n")
+	select {
+	case <-(<-stream).Done():
+	default:
+		t.Fatal("sse is not closed after the handler returned")
+	}
+}
+
+func TestAPI_Health(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gcore.Providers.Config("")()
+	cfg.Set("apiserver.listen", "127.0.0.1:")
+	cfg.Set("apiserver.health.enable", true)
+	ghealth.SetReady(true)
+	api, err := NewDefaultAPIServer(gcore.Providers.Ctx("")(), cfg)
+	assert.Nil(err)
+	ghealth.Register("apiserver", api)
+	defer ghealth.Unregister("apiserver")
+	assert.Nil(api.Run())
+	url := "http://" + api.Address()
+	resp, err := http.Get(url + "/readyz")
+	if assert.Nil(err) {
+		resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode)
+	}
+	api.GracefulStop()
+	defer ghealth.SetReady(true)
+	assert.False(ghealth.IsReady())
+	assert.NotNil(api.HealthCheck(context.Background()))
+	w, r := mockRequest("/readyz")
+	api.ServeHTTP(w, r)
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+	w, r = mockRequest("/healthz")
+	api.ServeHTTP(w, r)
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+}
+
+func TestAPI_Metrics(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gcore.Providers.Config("")()
+	cfg.Set("apiserver.listen", "127.0.0.1:")
+	cfg.Set("apiserver.metrics.enable", true)
+	api, err := NewDefaultAPIServer(gcore.Providers.Ctx("")(), cfg)
+	assert.Nil(err)
+	defer gmetrics.Unregister("apiserver.requests")
+	defer gmetrics.Unregister("apiserver.connections")
+	api.API("/user/:id", func(c gcore.Ctx) {
+		c.Write("ok")
+	})
+	for _, path := range []string{"/user/1", "/user/2", "/none"} {
+		w, r := mockRequest(path)
+		api.ServeHTTP(w, r)
+	}
+	w, r := mockRequest("/metrics")
+	api.ServeHTTP(w, r)
+	assert.Equal(gmetrics.ContentType, w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(body, `gmc_http_requests_total{server="apiserver",method="GET",route="/user/:id",code="200"} 2`)
+	assert.Contains(body, `gmc_http_requests_total{server="apiserver",method="GET",route="",code="404"} 1`)
+	assert.Contains(body, `gmc_http_request_duration_seconds_count{server="apiserver",method="GET",route="/user/:id"} 2`)
+	assert.Contains(body, `gmc_http_active_connections{server="apiserver"} 0`)
+	assert.Contains(body, "# TYPE go_goroutines gauge")
+}
@@ -0,0 +1,285 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+var (
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+	tlsCipherSuites = map[string]uint16{
+		"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+		"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	}
+)
+
+type certFile struct {
+	certFile, keyFile string
+	certStat, keyStat string
+	cert              *tls.Certificate
+	names             []string
+}
+
+// CertManager serves certificates selected by SNI, and reloads them when the
+// files changed, it's used as tls.Config.GetCertificate. The first added
+// certificate is the default one, it's used when no name matched.
+type CertManager struct {
+	mu     sync.RWMutex
+	certs  []*certFile
+	names  map[string]*tls.Certificate
+	logger gcore.Logger
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewCertManager() *CertManager {
+	return &CertManager{
+		names: map[string]*tls.Certificate{},
+		done:  make(chan struct{}),
+	}
+}
+
+func (m *CertManager) SetLogger(l gcore.Logger) {
+	m.logger = l
+}
+
+// Add loads a certificate and key pair, the names are read from the
+// certificate, DNS names or the common name if no DNS name found.
+func (m *CertManager) Add(certFile, keyFile string) (err error) {
+	c, err := loadCertFile(certFile, keyFile)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.certs = append(m.certs, c)
+	m.index()
+	return
+}
+
+// Count returns the count of certificates.
+func (m *CertManager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.certs)
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (m *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		if c, ok := m.names[name]; ok {
+			return c, nil
+		}
+		// a wildcard only matches one label.
+		if i := strings.Index(name, "."); i > 0 {
+			if c, ok := m.names["*"+name[i:]]; ok {
+				return c, nil
+			}
+		}
+	}
+	return m.certs[0].cert, nil
+}
+
+// Reload reloads the certificates which files changed, a certificate fails
+// to load is kept in use, and the last error is returned.
+func (m *CertManager) Reload() (err error) {
+	m.mu.RLock()
+	certs := make([]*certFile, len(m.certs))
+	copy(certs, m.certs)
+	m.mu.RUnlock()
+	changed := false
+	for i, c := range certs {
+		if fileStat(c.certFile) == c.certStat && fileStat(c.keyFile) == c.keyStat {
+			continue
+		}
+		n, e := loadCertFile(c.certFile, c.keyFile)
+		if e != nil {
+			err = e
+			if m.logger != nil {
+				m.logger.Warnf("reload certificate %s fail, error: %s", c.certFile, e)
+			}
+			continue
+		}
+		certs[i] = n
+		changed = true
+		if m.logger != nil {
+			m.logger.Infof("certificate %s reloaded", c.certFile)
+		}
+	}
+	if changed {
+		m.mu.Lock()
+		m.certs = certs
+		m.index()
+		m.mu.Unlock()
+	}
+	return
+}
+
+// Watch checks the files every interval and reloads the changed certificates
+// until Stop called.
+func (m *CertManager) Watch(interval time.Duration) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-t.C:
+				m.Reload()
+			}
+		}
+	}()
+}
+
+// Stop stops watching.
+func (m *CertManager) Stop() {
+	m.once.Do(func() { close(m.done) })
+}
+
+// index rebuilds the names map, the name of the certificate added first wins.
+func (m *CertManager) index() {
+	m.names = map[string]*tls.Certificate{}
+	for _, c := range m.certs {
+		for _, name := range c.names {
+			if _, ok := m.names[name]; !ok {
+				m.names[name] = c.cert
+			}
+		}
+	}
+}
+
+func loadCertFile(certFile0, keyFile string) (c *certFile, err error) {
+	c = &certFile{
+		certFile: certFile0,
+		keyFile:  keyFile,
+		certStat: fileStat(certFile0),
+		keyStat:  fileStat(keyFile),
+	}
+	cert, err := tls.LoadX509KeyPair(certFile0, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf = leaf
+	c.cert = &cert
+	for _, name := range leaf.DNSNames {
+		c.names = append(c.names, strings.ToLower(name))
+	}
+	if len(c.names) == 0 && leaf.Subject.CommonName != "" {
+		c.names = append(c.names, strings.ToLower(leaf.Subject.CommonName))
+	}
+	return
+}
+
+// fileStat returns the modify time and size of file, it's used to detect changes.
+func fileStat(file string) string {
+	info, err := os.Stat(file)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+}
+
+// newTLSConfig creates the tls.Config from the options in section of app.toml,
+// the certificates are served by the returned CertManager.
+func newTLSConfig(cfg gcore.Config, section string, logger gcore.Logger) (tlsCfg *tls.Config, m *CertManager, err error) {
+	m = NewCertManager()
+	m.SetLogger(logger)
+	if err = m.Add(cfg.GetString(section+".tlscert"), cfg.GetString(section+".tlskey")); err != nil {
+		return
+	}
+	certs, _ := cfg.Get(section + ".tlscerts").([]interface{})
+	for _, v := range certs {
+		item := gcast.ToStringMapString(v)
+		if err = m.Add(item["cert"], item["key"]); err != nil {
+			return
+		}
+	}
+	tlsCfg = &tls.Config{
+		GetCertificate: m.GetCertificate,
+	}
+	if err = applyTLSOptions(tlsCfg, cfg, section); err != nil {
+		return
+	}
+	if interval := cfg.GetInt(section + ".tlsreloadinterval"); interval > 0 {
+		m.Watch(time.Duration(interval) * time.Second)
+	}
+	return
+}
+
+// applyTLSOptions applies the tls version, cipher suites and client auth
+// options in section of app.toml to tlsCfg.
+func applyTLSOptions(tlsCfg *tls.Config, cfg gcore.Config, section string) (err error) {
+	if v := cfg.GetString(section + ".tlsminversion"); v != "" {
+		ver, ok := tlsVersions[v]
+		if !ok {
+			err = fmt.Errorf("unknown tls version %s", v)
+			return
+		}
+		tlsCfg.MinVersion = ver
+	}
+	for _, name := range cfg.GetStringSlice(section + ".tlsciphersuites") {
+		id, ok := tlsCipherSuites[name]
+		if !ok {
+			err = fmt.Errorf("unknown tls cipher suite %s", name)
+			return
+		}
+		tlsCfg.CipherSuites = append(tlsCfg.CipherSuites, id)
+	}
+	if cfg.GetBool(section + ".tlsclientauth") {
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		clientCertPool := x509.NewCertPool()
+		caBytes, e := ioutil.ReadFile(cfg.GetString(section + ".tlsclientsca"))
+		if e != nil {
+			err = e
+			return
+		}
+		if !clientCertPool.AppendCertsFromPEM(caBytes) {
+			err = gcore.Providers.Error("")().New("failed to parse tls clients root certificate")
+			return
+		}
+		tlsCfg.ClientCAs = clientCertPool
+	}
+	return
+}
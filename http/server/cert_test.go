@@ -0,0 +1,181 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestCert writes a self-signed certificate for names into dir, the
+// files are named by name.
+func writeTestCert(t *testing.T, dir, name, cn string, names ...string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return
+}
+
+func certCN(t *testing.T, m *CertManager, serverName string) string {
+	c, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.Leaf.Subject.CommonName
+}
+
+func TestCertManager_SNI(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc-cert")
+	defer os.RemoveAll(dir)
+	m := NewCertManager()
+	_, err := m.GetCertificate(&tls.ClientHelloInfo{})
+	assert.NotNil(err)
+	assert.Nil(m.Add(writeTestCert(t, dir, "default", "default")))
+	assert.Nil(m.Add(writeTestCert(t, dir, "a", "a", "a.example.com")))
+	assert.Nil(m.Add(writeTestCert(t, dir, "wildcard", "wildcard", "*.example.com")))
+	assert.Nil(m.Add(writeTestCert(t, dir, "cn", "cn.example.org")))
+	assert.NotNil(m.Add(filepath.Join(dir, "none.crt"), filepath.Join(dir, "none.key")))
+	assert.Equal(4, m.Count())
+
+	assert.Equal("a", certCN(t, m, "A.example.com."))
+	assert.Equal("wildcard", certCN(t, m, "b.example.com"))
+	assert.Equal("default", certCN(t, m, "c.b.example.com"))
+	assert.Equal("cn.example.org", certCN(t, m, "cn.example.org"))
+	assert.Equal("default", certCN(t, m, ""))
+}
+
+func TestCertManager_Reload(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc-cert")
+	defer os.RemoveAll(dir)
+	m := NewCertManager()
+	assert.Nil(m.Add(writeTestCert(t, dir, "site", "old")))
+	assert.Nil(m.Reload())
+	assert.Equal("old", certCN(t, m, ""))
+
+	m.Watch(time.Millisecond * 10)
+	defer m.Stop()
+	// make sure the modify time changed.
+	time.Sleep(time.Millisecond * 20)
+	writeTestCert(t, dir, "site", "new")
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal("new", certCN(t, m, ""))
+
+	// broken files keep the old certificate in use.
+	time.Sleep(time.Millisecond * 20)
+	ioutil.WriteFile(filepath.Join(dir, "site.crt"), []byte("broken"), 0600)
+	assert.NotNil(m.Reload())
+	assert.Equal("new", certCN(t, m, ""))
+}
+
+func mockTLSConfig(t *testing.T, dir string, extra string) gcore.Config {
+	certFile, keyFile := writeTestCert(t, dir, "default", "default")
+	aCert, aKey := writeTestCert(t, dir, "a", "a", "a.example.com")
+	cfg := gcore.Providers.Config("")()
+	cfg.SetConfigType("toml")
+	err := cfg.ReadConfig(strings.NewReader(`
+[httpserver]
+listen=":"
+tlsenable=true
+tlscert="` + certFile + `"
+tlskey="` + keyFile + `"
+tlsclientauth=false
+tlsclientsca="none.crt"
+tlscerts=[{cert="` + aCert + `",key="` + aKey + `"}]
+` + extra))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc-cert")
+	defer os.RemoveAll(dir)
+
+	tlsCfg, m, err := newTLSConfig(mockTLSConfig(t, dir, `
+tlsminversion="1.2"
+tlsciphersuites=["TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"]
+`), "httpserver", nil)
+	assert.Nil(err)
+	assert.Equal(2, m.Count())
+	assert.Equal(uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+	assert.Equal([]uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, tlsCfg.CipherSuites)
+	assert.Equal(tls.NoClientCert, tlsCfg.ClientAuth)
+	assert.Nil(tlsCfg.ClientCAs)
+
+	_, _, err = newTLSConfig(mockTLSConfig(t, dir, `tlsminversion="2.0"`), "httpserver", nil)
+	assert.NotNil(err)
+	_, _, err = newTLSConfig(mockTLSConfig(t, dir, `tlsciphersuites=["none"]`), "httpserver", nil)
+	assert.NotNil(err)
+	cfg := mockTLSConfig(t, dir, "")
+	cfg.Set("httpserver.tlscerts", []interface{}{map[string]interface{}{"cert": "none.crt", "key": "none.key"}})
+	_, _, err = newTLSConfig(cfg, "httpserver", nil)
+	assert.NotNil(err)
+}
+
+func TestListenTLS_SNI(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc-cert")
+	defer os.RemoveAll(dir)
+	s := mockHTTPServer()
+	s.SetConfig(mockTLSConfig(t, dir, ""))
+	assert.Nil(s.initTLSConfig())
+	assert.NotNil(s.CertManager())
+	assert.Nil(s.ListenTLS())
+	defer s.Close()
+
+	for serverName, cn := range map[string]string{"a.example.com": "a", "b.example.com": "default"} {
+		conn, err := tls.Dial("tcp", s.listener.Addr().String(), &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+		})
+		if !assert.Nil(err) {
+			continue
+		}
+		assert.Equal(cn, conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+		conn.Close()
+	}
+}
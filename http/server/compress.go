@@ -0,0 +1,465 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+)
+
+// The content codings supported by Compressor.
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// DefaultCompressTypes are the compressible content types by default, the
+// already compressed ones such as images, videos and archives are not in it.
+var DefaultCompressTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/x-javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/x-yaml",
+	"application/yaml",
+	"application/ld+json",
+	"application/manifest+json",
+	"application/problem+json",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// CompressConfig is the options of Compressor.
+type CompressConfig struct {
+	// Encodings are the content codings in order of preference when the
+	// client accepts several of them equally.
+	Encodings []string
+	// Level is the compression level of all encodings, 0 means the default
+	// level of each encoding.
+	Level int
+	// MinLength is the minimum bytes of a response to be compressed, the
+	// response is buffered until the length is reached or it's flushed.
+	MinLength int
+	// Types are the compressible content types, type/* matches any
+	// subtype.
+	Types []string
+}
+
+// NewCompressConfig returns the default config, brotli, gzip and deflate are
+// enabled, responses less than 1KB are not compressed.
+func NewCompressConfig() *CompressConfig {
+	return &CompressConfig{
+		Encodings: []string{EncodingBrotli, EncodingGzip, EncodingDeflate},
+		MinLength: 1024,
+		Types:     DefaultCompressTypes,
+	}
+}
+
+type resetWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compressor compresses the responses with the encoding negotiated by the
+// Accept-Encoding header of requests.
+type Compressor struct {
+	config *CompressConfig
+	types  map[string]bool
+	pools  map[string]*sync.Pool
+}
+
+// NewCompressor returns a Compressor of config, it returns error if any
+// encoding is not supported or the level is invalid.
+func NewCompressor(config *CompressConfig) (c *Compressor, err error) {
+	c = &Compressor{
+		config: config,
+		types:  map[string]bool{},
+		pools:  map[string]*sync.Pool{},
+	}
+	for _, t := range config.Types {
+		c.types[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+	for _, e := range config.Encodings {
+		var fn func() resetWriter
+		if fn, err = newEncodingWriter(e, config.Level); err != nil {
+			return nil, err
+		}
+		c.pools[e] = &sync.Pool{New: func() interface{} { return fn() }}
+	}
+	return
+}
+
+func newEncodingWriter(encoding string, level int) (fn func() resetWriter, err error) {
+	switch encoding {
+	case EncodingBrotli:
+		if level == 0 {
+			level = brotli.DefaultCompression
+		}
+		if level < brotli.BestSpeed || level > brotli.BestCompression {
+			break
+		}
+		return func() resetWriter { return brotli.NewWriterLevel(nil, level) }, nil
+	case EncodingGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		if _, err = gzip.NewWriterLevel(nil, level); err != nil {
+			return
+		}
+		return func() resetWriter {
+			w, _ := gzip.NewWriterLevel(nil, level)
+			return w
+		}, nil
+	case EncodingDeflate:
+		// the deflate content coding is the zlib format.
+		if level == 0 {
+			level = zlib.DefaultCompression
+		}
+		if _, err = zlib.NewWriterLevel(nil, level); err != nil {
+			return
+		}
+		return func() resetWriter {
+			w, _ := zlib.NewWriterLevel(nil, level)
+			return w
+		}, nil
+	default:
+		return nil, fmt.Errorf("compress: unsupported encoding %s", encoding)
+	}
+	return nil, fmt.Errorf("compress: invalid %s level %d", encoding, level)
+}
+
+type compressWriterKey struct{}
+
+// Start is a middleware0 wraps the response writer of ctx to compress the
+// response, if the client accepts any of the encodings.
+func (c *Compressor) Start(ctx gcore.Ctx) (isStop bool) {
+	rw, ok := ctx.Response().(*ghttputil.ResponseWriter)
+	if !ok {
+		return false
+	}
+	r := ctx.Request()
+	encoding := c.negotiate(r.Header.Get("Accept-Encoding"))
+	if encoding == "" || r.Method == http.MethodHead {
+		return false
+	}
+	w := &compressWriter{
+		ResponseWriter: rw.ResponseWriter,
+		c:              c,
+		encoding:       encoding,
+	}
+	// wraps the underlying writer, so the status code and write count of
+	// ghttputil.ResponseWriter still work.
+	rw.ResponseWriter = w
+	ctx.Set(compressWriterKey{}, w)
+	return false
+}
+
+// Finish is a middleware3 flushes the buffered or compressed data of the
+// response started by Start.
+func (c *Compressor) Finish(ctx gcore.Ctx) (isStop bool) {
+	if v, ok := ctx.Get(compressWriterKey{}); ok {
+		v.(*compressWriter).Close()
+	}
+	return false
+}
+
+// negotiate returns the encoding of the highest quality in acceptEncoding,
+// the order of Encodings breaks ties.
+func (c *Compressor) negotiate(acceptEncoding string) (encoding string) {
+	if e := acceptedEncodings(acceptEncoding, c.config.Encodings); len(e) > 0 {
+		encoding = e[0]
+	}
+	return
+}
+
+// acceptedEncodings returns the encodings acceptable by acceptEncoding, in the
+// order of quality, the order of encodings breaks ties.
+func acceptedEncodings(acceptEncoding string, encodings []string) (accepted []string) {
+	if acceptEncoding == "" {
+		return
+	}
+	q := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		v := 1.0
+		for _, p := range fields[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if f, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					v = f
+				}
+			}
+		}
+		q[name] = v
+	}
+	quality := map[string]float64{}
+	for _, e := range encodings {
+		v, ok := q[e]
+		if !ok {
+			v, ok = q["*"]
+		}
+		if ok && v > 0 {
+			accepted = append(accepted, e)
+			quality[e] = v
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return quality[accepted[i]] > quality[accepted[j]]
+	})
+	return
+}
+
+func (c *Compressor) compressible(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if c.types[t] {
+		return true
+	}
+	if i := strings.Index(t, "/"); i > 0 {
+		return c.types[t[:i]+"/*"]
+	}
+	return false
+}
+
+const (
+	stateUndecided = iota
+	stateCompress
+	statePlain
+)
+
+// compressWriter buffers the response until MinLength is reached, then
+// decides to compress it or not by the status code and headers.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *Compressor
+	encoding string
+	code     int
+	buf      []byte
+	state    int
+	zw       resetWriter
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.state != stateUndecided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code < 200 {
+		// informational responses are sent immediately.
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	switch w.state {
+	case stateCompress:
+		return w.zw.Write(b)
+	case statePlain:
+		return w.ResponseWriter.Write(b)
+	}
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if !w.mayCompress() {
+		w.plain(false)
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.c.config.MinLength {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// mayCompress checks the status code and headers, the content type may be
+// sniffed later.
+func (w *compressWriter) mayCompress() bool {
+	h := w.Header()
+	if w.code < 200 || w.code == http.StatusNoContent || w.code == http.StatusNotModified ||
+		w.code == http.StatusPartialContent || h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < w.c.config.MinLength {
+			return false
+		}
+	}
+	if ct := h.Get("Content-Type"); ct != "" {
+		return w.c.compressible(ct)
+	}
+	return true
+}
+
+// decide compresses the response if it's compressible and long enough, or
+// force is true.
+func (w *compressWriter) decide(force bool) (err error) {
+	h := w.Header()
+	if !w.mayCompress() {
+		return w.plain(false)
+	}
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// sniff it like net/http, which can't do it after compressed.
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+		if !w.mayCompress() {
+			return w.plain(false)
+		}
+	}
+	if !force && (len(w.buf) == 0 || len(w.buf) < w.c.config.MinLength) {
+		return w.plain(true)
+	}
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", w.encoding)
+	addVary(h)
+	w.state = stateCompress
+	w.zw = w.c.pools[w.encoding].Get().(resetWriter)
+	w.zw.Reset(w.ResponseWriter)
+	w.ResponseWriter.WriteHeader(w.code)
+	if len(w.buf) > 0 {
+		_, err = w.zw.Write(w.buf)
+		w.buf = nil
+	}
+	return
+}
+
+// plain writes the buffered data uncompressed, vary is true if the response
+// is compressible but too short.
+func (w *compressWriter) plain(vary bool) (err error) {
+	if vary {
+		addVary(w.Header())
+	}
+	w.state = statePlain
+	w.ResponseWriter.WriteHeader(w.code)
+	if len(w.buf) > 0 {
+		_, err = w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+	return
+}
+
+func addVary(h http.Header) {
+	for _, v := range h["Vary"] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+}
+
+// Flush implements http.Flusher, the response is compressed regardless of
+// MinLength if it's flushed before the length reached, so streaming works.
+func (w *compressWriter) Flush() {
+	if w.state == stateUndecided {
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		w.decide(true)
+	}
+	if w.state == stateCompress {
+		w.zw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, it's used by websocket upgrading.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support hijacking")
+	}
+	w.state = statePlain
+	return h.Hijack()
+}
+
+// Close writes the buffered data and the end of compressed data.
+func (w *compressWriter) Close() (err error) {
+	switch w.state {
+	case stateUndecided:
+		if w.code == 0 && len(w.buf) == 0 {
+			// nothing written.
+			w.state = statePlain
+			return
+		}
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		if err = w.decide(false); err != nil || w.state != stateCompress {
+			return
+		}
+		fallthrough
+	case stateCompress:
+		err = w.zw.Close()
+		w.zw.Reset(nil)
+		w.c.pools[w.encoding].Put(w.zw)
+		w.zw = nil
+		w.state = statePlain
+	}
+	return
+}
+
+type compressServer interface {
+	AddMiddleware0(m gcore.Middleware)
+	AddMiddleware3(m gcore.Middleware)
+}
+
+// bindCompress compresses the responses of server if the `compress` sub
+// section of section in app.toml is enabled, such as [httpserver.compress].
+func bindCompress(cfg gcore.Config, section string, server compressServer) (err error) {
+	prefix := section + ".compress."
+	if cfg == nil || !cfg.GetBool(prefix+"enable") {
+		return
+	}
+	c := NewCompressConfig()
+	if cfg.IsSet(prefix + "encodings") {
+		c.Encodings = cfg.GetStringSlice(prefix + "encodings")
+	}
+	if cfg.IsSet(prefix + "minlength") {
+		c.MinLength = cfg.GetInt(prefix + "minlength")
+	}
+	if v := cfg.GetStringSlice(prefix + "types"); len(v) > 0 {
+		c.Types = v
+	}
+	c.Level = cfg.GetInt(prefix + "level")
+	compressor, err := NewCompressor(c)
+	if err != nil {
+		return
+	}
+	server.AddMiddleware0(compressor.Start)
+	server.AddMiddleware3(compressor.Finish)
+	return
+}
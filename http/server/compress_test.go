@@ -0,0 +1,168 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressor_Negotiate(t *testing.T) {
+	assert := assert.New(t)
+	c, err := NewCompressor(NewCompressConfig())
+	assert.Nil(err)
+	for k, v := range map[string]string{
+		"":                             "",
+		"identity":                     "",
+		"gzip":                         "gzip",
+		"gzip, deflate, br":            "br",
+		"gzip;q=1.0, br;q=0.5":         "gzip",
+		"br;q=0, deflate":              "deflate",
+		"*":                            "br",
+		"*;q=0.5, gzip":                "gzip",
+		"GZIP;Q=0.8, identity;q=1":     "gzip",
+		"compress, x-gzip;q=0.5, zstd": "",
+	} {
+		assert.Equal(v, c.negotiate(k), k)
+	}
+	_, err = NewCompressor(&CompressConfig{Encodings: []string{"zstd"}})
+	assert.NotNil(err)
+	_, err = NewCompressor(&CompressConfig{Encodings: []string{"gzip"}, Level: 20})
+	assert.NotNil(err)
+}
+
+func mockCompressAPI(t *testing.T, minLength int) *APIServer {
+	cfg := gcore.Providers.Config("")()
+	cfg.Set("apiserver.listen", "127.0.0.1:")
+	cfg.Set("apiserver.compress.enable", true)
+	cfg.Set("apiserver.compress.minlength", minLength)
+	api, err := NewDefaultAPIServer(gcore.Providers.Ctx("")(), cfg)
+	assert.Nil(t, err)
+	return api
+}
+
+func decompress(t *testing.T, encoding string, r io.Reader) string {
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(r)
+	case "deflate":
+		r, err = zlib.NewReader(r)
+	case "br":
+		r = brotli.NewReader(r)
+	}
+	assert.Nil(t, err)
+	b, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	return string(b)
+}
+
+func TestCompress(t *testing.T) {
+	assert := assert.New(t)
+	api := mockCompressAPI(t, 100)
+	body := strings.Repeat("hello gmc ", 100)
+	api.API("/big", func(c gcore.Ctx) {
+		c.JSON(201, body)
+	})
+	api.API("/small", func(c gcore.Ctx) {
+		c.Write("small")
+	})
+	api.API("/png", func(c gcore.Ctx) {
+		c.SetHeader("Content-Type", "image/png")
+		c.Write(body)
+	})
+	api.API("/empty", func(c gcore.Ctx) {
+		c.WriteHeader(http.StatusNoContent)
+	})
+	for _, encoding := range []string{"gzip", "deflate", "br"} {
+		w, r := mockRequest("/big")
+		r.Header.Set("Accept-Encoding", encoding)
+		api.ServeHTTP(w, r)
+		assert.Equal(201, w.Code)
+		assert.Equal(encoding, w.Header().Get("Content-Encoding"))
+		assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal("application/json", w.Header().Get("Content-Type"))
+		assert.True(w.Body.Len() < len(body))
+		assert.Equal(body, decompress(t, encoding, w.Body))
+	}
+
+	w, r := mockRequest("/small")
+	r.Header.Set("Accept-Encoding", "gzip")
+	api.ServeHTTP(w, r)
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal("text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal("small", w.Body.String())
+
+	w, r = mockRequest("/png")
+	r.Header.Set("Accept-Encoding", "gzip")
+	api.ServeHTTP(w, r)
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal(body, w.Body.String())
+
+	w, r = mockRequest("/empty")
+	r.Header.Set("Accept-Encoding", "gzip")
+	api.ServeHTTP(w, r)
+	assert.Equal(204, w.Code)
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+
+	w, r = mockRequest("/big")
+	api.ServeHTTP(w, r)
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal(body, w.Body.String())
+
+	w, r = mockRequest("/big")
+	r.Method = http.MethodHead
+	r.Header.Set("Accept-Encoding", "gzip")
+	api.ServeHTTP(w, r)
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+}
+
+func TestCompress_Flush(t *testing.T) {
+	assert := assert.New(t)
+	api := mockCompressAPI(t, 1024)
+	api.API("/stream", func(c gcore.Ctx) {
+		c.SetHeader("Content-Type", "text/event-stream")
+		c.Write("data: 1\n\n")
+		c.Response().(http.Flusher).Flush()
+		c.Write("data: 2\n\n")
+	})
+	w, r := mockRequest("/stream")
+	r.Header.Set("Accept-Encoding", "gzip")
+	api.ServeHTTP(w, r)
+	assert.True(w.Flushed)
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal("data: 1\n\ndata: 2\n\n", decompress(t, "gzip", w.Body))
+}
+
+func TestHTTPServer_Compress(t *testing.T) {
+	assert := assert.New(t)
+	cfg := mockConfig()
+	cfg.Set("httpserver.compress.enable", true)
+	cfg.Set("httpserver.compress.encodings", []string{"gzip"})
+	cfg.Set("httpserver.compress.minlength", 0)
+	s := mockHTTPServer(cfg)
+	s.router.HandlerFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>hello</body></html>"))
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip, br")
+	s.ServeHTTP(w, r)
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal("<html><body>hello</body></html>", decompress(t, "gzip", w.Body))
+}
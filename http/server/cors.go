@@ -0,0 +1,317 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+// CORSConfig is the options of CORS.
+type CORSConfig struct {
+	// Origins is the allowed origins, an origin can be `*` for any origin,
+	// an exact origin such as `https://example.com`, a wildcard such as
+	// `https://*.example.com`, or a regexp starts with `~`, such as
+	// `~^https://(a|b)\.example\.com$`.
+	Origins []string
+	// Methods is the allowed methods of preflight requests, empty means the
+	// requested method is allowed.
+	Methods []string
+	// Headers is the allowed headers of preflight requests, `*` means any
+	// requested header is allowed.
+	Headers []string
+	// ExposeHeaders is the headers the browser can access in response.
+	ExposeHeaders []string
+	// Credentials allows the requests with cookies or authorization, the
+	// request origin is responded instead of `*` if it's true.
+	Credentials bool
+	// MaxAge is the seconds of preflight result can be cached, 0 means no
+	// Access-Control-Max-Age header.
+	MaxAge int
+}
+
+// NewCORSConfig returns the default options of CORS, all origins are
+// allowed.
+func NewCORSConfig() *CORSConfig {
+	return &CORSConfig{
+		Origins: []string{"*"},
+		Methods: []string{http.MethodGet, http.MethodHead, http.MethodPost,
+			http.MethodPut, http.MethodPatch, http.MethodDelete},
+		Headers: []string{"Origin", "Accept", "Content-Type", "X-Requested-With"},
+		MaxAge:  600,
+	}
+}
+
+// corsPolicy is a compiled CORSConfig.
+type corsPolicy struct {
+	config      *CORSConfig
+	anyOrigin   bool
+	origins     map[string]bool
+	patterns    []*regexp.Regexp
+	anyHeader   bool
+	headers     map[string]bool
+	methods     map[string]bool
+	allowMethod string
+	allowHeader string
+	expose      string
+	maxAge      string
+}
+
+func newCORSPolicy(c *CORSConfig) (p *corsPolicy, err error) {
+	p = &corsPolicy{
+		config:  c,
+		origins: map[string]bool{},
+		headers: map[string]bool{},
+		methods: map[string]bool{},
+		expose:  strings.Join(c.ExposeHeaders, ", "),
+	}
+	for _, o := range c.Origins {
+		o = strings.TrimSpace(o)
+		switch {
+		case o == "*":
+			p.anyOrigin = true
+		case strings.HasPrefix(o, "~"):
+			re, e := regexp.Compile(o[1:])
+			if e != nil {
+				return nil, gcore.Providers.Error("")().New("cors origin " + o + " error: " + e.Error())
+			}
+			p.patterns = append(p.patterns, re)
+		case strings.Contains(o, "*"):
+			re := regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(strings.ToLower(o)), `\*`, `[^/]*`, -1) + "$")
+			p.patterns = append(p.patterns, re)
+		case o != "":
+			p.origins[strings.ToLower(o)] = true
+		}
+	}
+	var headers []string
+	for _, h := range c.Headers {
+		if h == "*" {
+			p.anyHeader = true
+			continue
+		}
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		p.headers[h] = true
+		headers = append(headers, h)
+	}
+	p.allowHeader = strings.Join(headers, ", ")
+	var methods []string
+	for _, m := range c.Methods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		p.methods[m] = true
+		methods = append(methods, m)
+	}
+	p.allowMethod = strings.Join(methods, ", ")
+	if c.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(c.MaxAge)
+	}
+	return
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders returns false if any of the comma separated headers is not
+// allowed.
+func (p *corsPolicy) allowHeaders(headers string) bool {
+	if p.anyHeader {
+		return true
+	}
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" && !p.headers[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+	return true
+}
+
+type corsGroup struct {
+	path   string
+	policy *corsPolicy
+}
+
+// CORS handles the cross-origin requests of browsers, the policy of the
+// longest matched group path is used, otherwise the default one.
+type CORS struct {
+	policy *corsPolicy
+	groups []corsGroup
+}
+
+// NewCORS creates a CORS with the default policy c.
+func NewCORS(c *CORSConfig) (cors *CORS, err error) {
+	p, err := newCORSPolicy(c)
+	if err != nil {
+		return
+	}
+	return &CORS{policy: p}, nil
+}
+
+// Group sets the policy of the requests path is path or under it, such as
+// the namespace of a router group, `/v1` matches `/v1/user` but not `/v10`.
+func (c *CORS) Group(path string, config *CORSConfig) (err error) {
+	p, err := newCORSPolicy(config)
+	if err != nil {
+		return
+	}
+	c.groups = append(c.groups, corsGroup{path: path, policy: p})
+	sort.SliceStable(c.groups, func(i, j int) bool {
+		return len(c.groups[i].path) > len(c.groups[j].path)
+	})
+	return
+}
+
+func (c *CORS) match(path string) *corsPolicy {
+	for _, g := range c.groups {
+		if ghttputil.MatchPathPrefix(g.path, path) {
+			return g.policy
+		}
+	}
+	return c.policy
+}
+
+// Handle is a middleware0 sets the CORS headers of the requests from
+// allowed origins. The preflight request is replied by the OPTIONS handler
+// of route, or the automatic OPTIONS reply of server if it has no handler.
+func (c *CORS) Handle(ctx gcore.Ctx) (isStop bool) {
+	r := ctx.Request()
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	p := c.match(r.URL.Path)
+	header := ctx.Response().Header()
+	if !p.anyOrigin || p.config.Credentials {
+		header.Add("Vary", "Origin")
+	}
+	reqMethod := r.Header.Get("Access-Control-Request-Method")
+	isPreflight := r.Method == http.MethodOptions && reqMethod != ""
+	if isPreflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if !p.allowOrigin(origin) {
+		return false
+	}
+	if isPreflight {
+		reqHeaders := r.Header.Get("Access-Control-Request-Headers")
+		if (len(p.methods) > 0 && !p.methods[strings.ToUpper(reqMethod)]) || !p.allowHeaders(reqHeaders) {
+			return false
+		}
+		if p.allowMethod != "" {
+			header.Set("Access-Control-Allow-Methods", p.allowMethod)
+		} else {
+			header.Set("Access-Control-Allow-Methods", strings.ToUpper(reqMethod))
+		}
+		if p.anyHeader {
+			if reqHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+		} else if p.allowHeader != "" {
+			header.Set("Access-Control-Allow-Headers", p.allowHeader)
+		}
+		if p.maxAge != "" {
+			header.Set("Access-Control-Max-Age", p.maxAge)
+		}
+	} else if p.expose != "" {
+		header.Set("Access-Control-Expose-Headers", p.expose)
+	}
+	if p.anyOrigin && !p.config.Credentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.config.Credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return false
+}
+
+// NewCORSConfigFromConfig parses the `cors` sub section of section in
+// app.toml, such as [apiserver.cors], the keys not set are the default of
+// NewCORSConfig.
+func NewCORSConfigFromConfig(cfg gcore.Config, section string) *CORSConfig {
+	prefix := section + ".cors."
+	m := map[string]interface{}{}
+	for _, k := range []string{"origins", "methods", "headers", "exposeheaders", "credentials", "maxage"} {
+		if cfg.IsSet(prefix + k) {
+			m[k] = cfg.Get(prefix + k)
+		}
+	}
+	return corsConfigFromMap(NewCORSConfig(), m)
+}
+
+// corsConfigFromMap returns a copy of base overridden by the keys in m.
+func corsConfigFromMap(base *CORSConfig, m map[string]interface{}) *CORSConfig {
+	c := *base
+	if v, ok := m["origins"]; ok {
+		c.Origins = gcast.ToStringSlice(v)
+	}
+	if v, ok := m["methods"]; ok {
+		c.Methods = gcast.ToStringSlice(v)
+	}
+	if v, ok := m["headers"]; ok {
+		c.Headers = gcast.ToStringSlice(v)
+	}
+	if v, ok := m["exposeheaders"]; ok {
+		c.ExposeHeaders = gcast.ToStringSlice(v)
+	}
+	if v, ok := m["credentials"]; ok {
+		c.Credentials = gcast.ToBool(v)
+	}
+	if v, ok := m["maxage"]; ok {
+		c.MaxAge = gcast.ToInt(v)
+	}
+	return &c
+}
+
+type corsServer interface {
+	AddMiddleware0(m gcore.Middleware)
+}
+
+// bindCORS handles the cross-origin requests of server if the `cors` sub
+// section of section in app.toml is enabled, such as [apiserver.cors], the
+// `groups` of it set the policy of the paths, the keys not set in a group
+// are the same as the section.
+func bindCORS(cfg gcore.Config, section string, server corsServer) (err error) {
+	prefix := section + ".cors."
+	if cfg == nil || !cfg.GetBool(prefix+"enable") {
+		return
+	}
+	c := NewCORSConfigFromConfig(cfg, section)
+	cors, err := NewCORS(c)
+	if err != nil {
+		return
+	}
+	groups, _ := cfg.Get(prefix + "groups").([]interface{})
+	for _, v := range groups {
+		m := gcast.ToStringMap(v)
+		if err = cors.Group(gcast.ToString(m["path"]), corsConfigFromMap(c, m)); err != nil {
+			return
+		}
+	}
+	server.AddMiddleware0(cors.Handle)
+	return
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"net/http"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCORSAPI(t *testing.T, set func(cfg gcore.Config)) *APIServer {
+	cfg := gcore.Providers.Config("")()
+	cfg.Set("apiserver.listen", "127.0.0.1:")
+	cfg.Set("apiserver.cors.enable", true)
+	if set != nil {
+		set(cfg)
+	}
+	api, err := NewDefaultAPIServer(gcore.Providers.Ctx("")(), cfg)
+	assert.Nil(t, err)
+	api.Router().GET("/user", func(w http.ResponseWriter, r *http.Request, ps gcore.Params) {
+		w.Write([]byte("get"))
+	})
+	api.Router().POST("/user", func(w http.ResponseWriter, r *http.Request, ps gcore.Params) {
+		w.Write([]byte("post"))
+	})
+	return api
+}
+
+func corsRequest(api *APIServer, method, uri, origin string, header ...string) http.Header {
+	w, r := mockRequest(uri)
+	r.Method = method
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	api.ServeHTTP(w, r)
+	h := w.Header()
+	h.Set("X-Status", http.StatusText(w.Code))
+	return h
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	assert := assert.New(t)
+	api := mockCORSAPI(t, nil)
+	h := corsRequest(api, "OPTIONS", "/user", "https://a.com",
+		"Access-Control-Request-Method", "POST",
+		"Access-Control-Request-Headers", "content-type")
+	assert.Equal("No Content", h.Get("X-Status"))
+	assert.Equal("*", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal("GET, HEAD, POST, PUT, PATCH, DELETE", h.Get("Access-Control-Allow-Methods"))
+	assert.Equal("Origin, Accept, Content-Type, X-Requested-With", h.Get("Access-Control-Allow-Headers"))
+	assert.Equal("600", h.Get("Access-Control-Max-Age"))
+	assert.Equal("GET, OPTIONS, POST", h.Get("Allow"))
+
+	// header not allowed.
+	h = corsRequest(api, "OPTIONS", "/user", "https://a.com",
+		"Access-Control-Request-Method", "POST",
+		"Access-Control-Request-Headers", "X-Token")
+	assert.Equal("", h.Get("Access-Control-Allow-Origin"))
+	// method not allowed.
+	h = corsRequest(api, "OPTIONS", "/user", "https://a.com",
+		"Access-Control-Request-Method", "TRACE")
+	assert.Equal("", h.Get("Access-Control-Allow-Origin"))
+	// no route.
+	h = corsRequest(api, "OPTIONS", "/none", "https://a.com",
+		"Access-Control-Request-Method", "GET")
+	assert.Equal("Not Found", h.Get("X-Status"))
+
+	h = corsRequest(api, "GET", "/user", "https://a.com")
+	assert.Equal("OK", h.Get("X-Status"))
+	assert.Equal("*", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal("", h.Get("Access-Control-Allow-Methods"))
+	h = corsRequest(api, "GET", "/user", "")
+	assert.Equal("", h.Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS_Origins(t *testing.T) {
+	assert := assert.New(t)
+	api := mockCORSAPI(t, func(cfg gcore.Config) {
+		cfg.Set("apiserver.cors.origins", []string{"https://a.com", "https://*.b.com", `~^https://c[0-9]\.com$`})
+		cfg.Set("apiserver.cors.headers", []string{"*"})
+		cfg.Set("apiserver.cors.exposeheaders", []string{"X-Total"})
+		cfg.Set("apiserver.cors.credentials", true)
+		cfg.Set("apiserver.cors.maxage", 0)
+	})
+	for origin, allowed := range map[string]bool{
+		"https://a.com":     true,
+		"https://A.com":     true,
+		"http://a.com":      false,
+		"https://x.b.com":   true,
+		"https://x.y.b.com": true,
+		"https://b.com":     false,
+		"https://c1.com":    true,
+		"https://c11.com":   false,
+	} {
+		h := corsRequest(api, "GET", "/user", origin)
+		if allowed {
+			assert.Equal(origin, h.Get("Access-Control-Allow-Origin"), origin)
+			assert.Equal("true", h.Get("Access-Control-Allow-Credentials"))
+			assert.Equal("X-Total", h.Get("Access-Control-Expose-Headers"))
+		} else {
+			assert.Equal("", h.Get("Access-Control-Allow-Origin"), origin)
+		}
+		assert.Equal("Origin", h.Get("Vary"))
+	}
+	h := corsRequest(api, "OPTIONS", "/user", "https://a.com",
+		"Access-Control-Request-Method", "PUT",
+		"Access-Control-Request-Headers", "X-Token, X-Id")
+	assert.Equal("X-Token, X-Id", h.Get("Access-Control-Allow-Headers"))
+	assert.Equal("", h.Get("Access-Control-Max-Age"))
+}
+
+func TestCORS_Groups(t *testing.T) {
+	assert := assert.New(t)
+	api := mockCORSAPI(t, func(cfg gcore.Config) {
+		cfg.Set("apiserver.cors.origins", []string{"https://a.com"})
+		cfg.Set("apiserver.cors.groups", []interface{}{
+			map[string]interface{}{"path": "/v1/", "origins": []interface{}{"https://b.com"}},
+			map[string]interface{}{"path": "/v1/public/", "origins": []interface{}{"*"}, "methods": []interface{}{}},
+			map[string]interface{}{"path": "/v2", "origins": []interface{}{"https://b.com"}},
+		})
+	})
+	g := api.Group("/v1")
+	g.API("/user", func(c gcore.Ctx) {})
+	g.Group("/public").API("/user", func(c gcore.Ctx) {})
+
+	assert.Equal("https://a.com", corsRequest(api, "GET", "/user", "https://a.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal("", corsRequest(api, "GET", "/v1/user", "https://a.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal("https://b.com", corsRequest(api, "GET", "/v1/user", "https://b.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal("*", corsRequest(api, "GET", "/v1/public/user", "https://c.com").Get("Access-Control-Allow-Origin"))
+	// groups match whole path segments.
+	api.API("/v2", func(c gcore.Ctx) {})
+	api.API("/v2/user", func(c gcore.Ctx) {})
+	api.API("/v20/user", func(c gcore.Ctx) {})
+	assert.Equal("https://b.com", corsRequest(api, "GET", "/v2", "https://b.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal("https://b.com", corsRequest(api, "GET", "/v2/user", "https://b.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal("", corsRequest(api, "GET", "/v20/user", "https://b.com").Get("Access-Control-Allow-Origin"))
+	assert.Equal("https://a.com", corsRequest(api, "GET", "/v20/user", "https://a.com").Get("Access-Control-Allow-Origin"))
+	h := corsRequest(api, "OPTIONS", "/v1/public/user", "https://c.com", "Access-Control-Request-Method", "TRACE")
+	assert.Equal("TRACE", h.Get("Access-Control-Allow-Methods"))
+
+	cors, err := NewCORS(NewCORSConfig())
+	assert.Nil(err)
+	assert.NotNil(cors.Group("/x", &CORSConfig{Origins: []string{"~("}}))
+}
+
+func TestHTTPServer_OPTIONS(t *testing.T) {
+	assert := assert.New(t)
+	s := mockHTTPServer()
+	s.router.HandlerFunc("GET", "/user", func(w http.ResponseWriter, r *http.Request) {})
+	w, r := mockRequest("/user")
+	r.Method = "OPTIONS"
+	s.ServeHTTP(w, r)
+	assert.Equal(http.StatusNoContent, w.Code)
+	assert.Equal("GET, OPTIONS", w.Header().Get("Allow"))
+	w, r = mockRequest("/none")
+	r.Method = "OPTIONS"
+	s.ServeHTTP(w, r)
+	assert.Equal(http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,289 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+)
+
+const (
+	// CSRFModeSession stores the token in the session of request.
+	CSRFModeSession = "session"
+	// CSRFModeCookie stores the token in a cookie, the double submit cookie
+	// pattern, no session is required.
+	CSRFModeCookie = "cookie"
+)
+
+var (
+	// ErrCSRFTokenMissing is passed to the error handler if the request has
+	// no token.
+	ErrCSRFTokenMissing = errors.New("csrf token missing")
+	// ErrCSRFTokenInvalid is passed to the error handler if the token of
+	// request mismatches.
+	ErrCSRFTokenInvalid = errors.New("csrf token invalid")
+)
+
+// CSRFConfig is the options of CSRF.
+type CSRFConfig struct {
+	// Mode is CSRFModeSession or CSRFModeCookie.
+	Mode string
+	// FieldName is the form field name of token.
+	FieldName string
+	// HeaderName is the header name of token, for AJAX requests.
+	HeaderName string
+	// SessionKey is the session key of token in CSRFModeSession.
+	SessionKey string
+	// CookieName is the cookie name of token in CSRFModeCookie.
+	CookieName string
+	// CookieMaxAge is the max age of token cookie in seconds, 0 means a
+	// session cookie of browser.
+	CookieMaxAge int
+	// CookieSecure sets the Secure flag of token cookie.
+	CookieSecure bool
+	// CookieHTTPOnly sets the HttpOnly flag of token cookie, it must be false
+	// if the token is read from cookie by javascript.
+	CookieHTTPOnly bool
+	// Exempt is the routes skipped, an item is a route path with optional
+	// path.Match patterns in segments, and an optional method prefix, such
+	// as `/api/*`, `/static/*filepath` and `POST /webhook/:name`.
+	Exempt []string
+}
+
+// NewCSRFConfig returns the default options of CSRF.
+func NewCSRFConfig() *CSRFConfig {
+	return &CSRFConfig{
+		Mode:       CSRFModeSession,
+		FieldName:  "_csrf",
+		HeaderName: "X-CSRF-Token",
+		SessionKey: "_csrf",
+		CookieName: "_csrf",
+	}
+}
+
+// NewCSRFConfigFromConfig parses the `csrf` sub section of section in
+// app.toml, such as [httpserver.csrf].
+func NewCSRFConfigFromConfig(cfg gcore.Config, section string) *CSRFConfig {
+	prefix := section + ".csrf."
+	c := NewCSRFConfig()
+	for k, v := range map[string]*string{
+		"mode":       &c.Mode,
+		"fieldname":  &c.FieldName,
+		"headername": &c.HeaderName,
+		"sessionkey": &c.SessionKey,
+		"cookiename": &c.CookieName,
+	} {
+		if s := cfg.GetString(prefix + k); s != "" {
+			*v = s
+		}
+	}
+	c.CookieMaxAge = cfg.GetInt(prefix + "cookiemaxage")
+	c.CookieSecure = cfg.GetBool(prefix + "cookiesecure")
+	c.CookieHTTPOnly = cfg.GetBool(prefix + "cookiehttponly")
+	c.Exempt = cfg.GetStringSlice(prefix + "exempt")
+	return c
+}
+
+// CSRF verifies the token of unsafe requests, the methods except GET, HEAD,
+// OPTIONS and TRACE, and sets the token of request in Ctx by
+// gcore.CSRFTokenKey, the controller sets it in view data as `CSRF`, then
+// `{{csrf_field .}}` outputs the hidden input of it in template, or
+// `{{csrf_field $}}` inside range and with.
+type CSRF struct {
+	config       *CSRFConfig
+	exempt       []string
+	errorHandler func(ctx gcore.Ctx, err error)
+	lock         sync.RWMutex
+}
+
+// NewCSRF creates a CSRF by c.
+func NewCSRF(c *CSRFConfig) (csrf *CSRF, err error) {
+	if c.Mode != CSRFModeSession && c.Mode != CSRFModeCookie {
+		return nil, gcore.Providers.Error("")().New("unknown csrf mode: " + c.Mode)
+	}
+	csrf = &CSRF{
+		config: c,
+		exempt: append([]string{}, c.Exempt...),
+	}
+	return
+}
+
+// Exempt adds the routes skipped, the format is the same as
+// CSRFConfig.Exempt.
+func (c *CSRF) Exempt(routes ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.exempt = append(c.exempt, routes...)
+}
+
+// SetErrorHandler sets the handler called if the verification fails, the
+// default one responds 403.
+func (c *CSRF) SetErrorHandler(fn func(ctx gcore.Ctx, err error)) {
+	c.errorHandler = fn
+}
+
+// Handle is a middleware1 issues and verifies the token of request, the
+// exempt routes are skipped.
+func (c *CSRF) Handle(ctx gcore.Ctx) (isStop bool) {
+	if c.isExempt(ctx) {
+		return false
+	}
+	token, err := c.token(ctx)
+	if err != nil {
+		c.fail(ctx, err)
+		return true
+	}
+	ctx.Set(gcore.CSRFTokenKey, &gcore.CSRFToken{
+		Token:      token,
+		FieldName:  c.config.FieldName,
+		HeaderName: c.config.HeaderName,
+	})
+	switch ctx.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	r := ctx.Request()
+	got := r.Header.Get(c.config.HeaderName)
+	if got == "" {
+		got = r.FormValue(c.config.FieldName)
+	}
+	if got == "" {
+		c.fail(ctx, ErrCSRFTokenMissing)
+		return true
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		c.fail(ctx, ErrCSRFTokenInvalid)
+		return true
+	}
+	return false
+}
+
+func (c *CSRF) fail(ctx gcore.Ctx, err error) {
+	if c.errorHandler != nil {
+		c.errorHandler(ctx, err)
+		return
+	}
+	ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+	ctx.WriteHeader(http.StatusForbidden)
+	ctx.Write("Forbidden, " + err.Error())
+}
+
+func (c *CSRF) isExempt(ctx gcore.Ctx) bool {
+	r := ctx.Request()
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, v := range c.exempt {
+		if ghttputil.MatchRoute(v, r.Method, r.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// token returns the token of request, a new one is issued if not found.
+func (c *CSRF) token(ctx gcore.Ctx) (token string, err error) {
+	if c.config.Mode == CSRFModeCookie {
+		if cookie, e := ctx.Request().Cookie(c.config.CookieName); e == nil && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+		if token, err = newCSRFToken(); err != nil {
+			return
+		}
+		http.SetCookie(ctx.Response(), &http.Cookie{
+			Name:     c.config.CookieName,
+			Value:    token,
+			Path:     "/",
+			MaxAge:   c.config.CookieMaxAge,
+			Secure:   c.config.CookieSecure,
+			HttpOnly: c.config.CookieHTTPOnly,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return
+	}
+	sess, err := c.session(ctx)
+	if err != nil {
+		return
+	}
+	if v, ok := sess.Get(c.config.SessionKey).(string); ok && v != "" {
+		return v, nil
+	}
+	if token, err = newCSRFToken(); err != nil {
+		return
+	}
+	sess.Set(c.config.SessionKey, token)
+	err = ctx.WebServer().SessionStore().Save(sess)
+	return
+}
+
+// session loads the session of request, if not found, a new session is
+// started, and its cookie is added to request too, so the controller
+// starts the same session.
+func (c *CSRF) session(ctx gcore.Ctx) (sess gcore.Session, err error) {
+	server := ctx.WebServer()
+	if server == nil || server.SessionStore() == nil {
+		return nil, gcore.Providers.Error("")().New("session is disabled")
+	}
+	store := server.SessionStore()
+	cookieName := server.Config().GetString("session.cookiename")
+	if cookie, e := ctx.Request().Cookie(cookieName); e == nil && cookie.Value != "" {
+		if sess, ok := store.Load(cookie.Value); ok {
+			return sess, nil
+		}
+	}
+	sess = gcore.Providers.Session("")()
+	sess.Touch()
+	if err = store.Save(sess); err != nil {
+		return
+	}
+	gcore.Providers.Cookies("")(ctx).Set(cookieName, sess.SessionID(), &gcore.CookieOptions{
+		Path:     "/",
+		MaxAge:   server.Config().GetInt("session.ttl"),
+		HTTPOnly: true,
+	})
+	r := ctx.Request()
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, v := range cookies {
+		if v.Name != cookieName {
+			r.AddCookie(v)
+		}
+	}
+	r.AddCookie(&http.Cookie{Name: cookieName, Value: sess.SessionID()})
+	return
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type csrfServer interface {
+	AddMiddleware1(m gcore.Middleware)
+}
+
+// bindCSRF verifies the csrf token of requests if the `csrf` sub section of
+// section in app.toml is enabled, such as [httpserver.csrf].
+func bindCSRF(cfg gcore.Config, section string, server csrfServer) (csrf *CSRF, err error) {
+	if cfg == nil || !cfg.GetBool(section+".csrf.enable") {
+		return
+	}
+	csrf, err = NewCSRF(NewCSRFConfigFromConfig(cfg, section))
+	if err != nil {
+		return
+	}
+	server.AddMiddleware1(csrf.Handle)
+	return
+}
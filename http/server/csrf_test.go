@@ -0,0 +1,170 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCSRFServer(t *testing.T, set func(cfg gcore.Config)) *HTTPServer {
+	cfg := mockConfig()
+	cfg.Set("httpserver.csrf.enable", true)
+	if set != nil {
+		set(cfg)
+	}
+	s := mockHTTPServer(cfg)
+	assert.NotNil(t, s.CSRF())
+	for _, method := range []string{"GET", "POST"} {
+		s.router.HandlerFunc(method, "/form", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+		s.router.HandlerFunc(method, "/hook/:name", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+	}
+	return s
+}
+
+func csrfRequest(s *HTTPServer, method, uri string, form url.Values, cookies []*http.Cookie, header ...string) (code int, resp *http.Response) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "http://example.com"+uri, strings.NewReader(form.Encode()))
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	s.ServeHTTP(w, r)
+	return w.Code, w.Result()
+}
+
+func TestCSRF_Cookie(t *testing.T) {
+	assert := assert.New(t)
+	s := mockCSRFServer(t, func(cfg gcore.Config) {
+		cfg.Set("httpserver.csrf.mode", CSRFModeCookie)
+	})
+	code, resp := csrfRequest(s, "GET", "/form", nil, nil)
+	assert.Equal(http.StatusOK, code)
+	cookies := resp.Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal("_csrf", cookies[0].Name)
+	token := cookies[0].Value
+	assert.NotEmpty(token)
+
+	// the token cookie is reused.
+	_, resp = csrfRequest(s, "GET", "/form", nil, cookies)
+	assert.Len(resp.Cookies(), 0)
+
+	code, _ = csrfRequest(s, "POST", "/form", nil, cookies)
+	assert.Equal(http.StatusForbidden, code)
+	code, _ = csrfRequest(s, "POST", "/form", nil, cookies, "X-CSRF-Token", "foo")
+	assert.Equal(http.StatusForbidden, code)
+	code, _ = csrfRequest(s, "POST", "/form", nil, cookies, "X-CSRF-Token", token)
+	assert.Equal(http.StatusOK, code)
+	code, _ = csrfRequest(s, "POST", "/form", url.Values{"_csrf": {token}}, cookies)
+	assert.Equal(http.StatusOK, code)
+	// no cookie, a new token is issued, the submitted one mismatches.
+	code, _ = csrfRequest(s, "POST", "/form", url.Values{"_csrf": {token}}, nil)
+	assert.Equal(http.StatusForbidden, code)
+}
+
+func TestCSRF_Session(t *testing.T) {
+	assert := assert.New(t)
+	s := mockCSRFServer(t, nil)
+	code, resp := csrfRequest(s, "GET", "/form", nil, nil)
+	assert.Equal(http.StatusOK, code)
+	cookies := resp.Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal("gmcsid", cookies[0].Name)
+	sess, ok := s.SessionStore().Load(cookies[0].Value)
+	assert.True(ok)
+	token, _ := sess.Get("_csrf").(string)
+	assert.NotEmpty(token)
+
+	_, resp = csrfRequest(s, "GET", "/form", nil, cookies)
+	assert.Len(resp.Cookies(), 0)
+	sess, _ = s.SessionStore().Load(cookies[0].Value)
+	assert.Equal(token, sess.Get("_csrf"))
+
+	code, _ = csrfRequest(s, "POST", "/form", nil, cookies)
+	assert.Equal(http.StatusForbidden, code)
+	code, _ = csrfRequest(s, "POST", "/form", url.Values{"_csrf": {token}}, cookies)
+	assert.Equal(http.StatusOK, code)
+	code, _ = csrfRequest(s, "POST", "/form", nil, cookies, "X-CSRF-Token", token)
+	assert.Equal(http.StatusOK, code)
+
+	// a stale session cookie is replaced.
+	stale := []*http.Cookie{{Name: "gmcsid", Value: "none"}}
+	_, resp = csrfRequest(s, "GET", "/form", nil, stale)
+	assert.Len(resp.Cookies(), 1)
+	assert.NotEqual("none", resp.Cookies()[0].Value)
+}
+
+func TestCSRF_Exempt(t *testing.T) {
+	assert := assert.New(t)
+	s := mockCSRFServer(t, func(cfg gcore.Config) {
+		cfg.Set("httpserver.csrf.mode", CSRFModeCookie)
+		cfg.Set("httpserver.csrf.exempt", []string{"POST /hook/:name"})
+	})
+	code, resp := csrfRequest(s, "POST", "/hook/github", nil, nil)
+	assert.Equal(http.StatusOK, code)
+	assert.Len(resp.Cookies(), 0)
+	code, _ = csrfRequest(s, "POST", "/form", nil, nil)
+	assert.Equal(http.StatusForbidden, code)
+	s.CSRF().Exempt("/fo*")
+	code, _ = csrfRequest(s, "POST", "/form", nil, nil)
+	assert.Equal(http.StatusOK, code)
+	s.CSRF().Exempt("GET /none")
+	assert.Len(s.CSRF().exempt, 4)
+	assert.True(ghttputil.MatchRoute("/static/*filepath", "GET", "/static/js/a.js"))
+	assert.True(ghttputil.MatchRoute("/static/*filepath", "GET", "/static/"))
+	assert.False(ghttputil.MatchRoute("/static/*filepath", "GET", "/static"))
+	assert.False(ghttputil.MatchRoute("/hook/:name", "GET", "/hook/"))
+	assert.False(ghttputil.MatchRoute("/hook/:name", "GET", "/hook/a/b"))
+	assert.False(ghttputil.MatchRoute("POST /hook/:name", "GET", "/hook/a"))
+}
+
+func TestCSRF_ErrorHandler(t *testing.T) {
+	assert := assert.New(t)
+	s := mockCSRFServer(t, func(cfg gcore.Config) {
+		cfg.Set("httpserver.csrf.mode", CSRFModeCookie)
+	})
+	var errs []error
+	s.CSRF().SetErrorHandler(func(ctx gcore.Ctx, err error) {
+		errs = append(errs, err)
+		ctx.WriteHeader(http.StatusBadRequest)
+	})
+	cookies := []*http.Cookie{{Name: "_csrf", Value: "abc"}}
+	code, _ := csrfRequest(s, "POST", "/form", nil, cookies)
+	assert.Equal(http.StatusBadRequest, code)
+	code, _ = csrfRequest(s, "POST", "/form", nil, cookies, "X-CSRF-Token", "abd")
+	assert.Equal(http.StatusBadRequest, code)
+	assert.Equal([]error{ErrCSRFTokenMissing, ErrCSRFTokenInvalid}, errs)
+}
+
+func TestNewCSRF(t *testing.T) {
+	assert := assert.New(t)
+	c := NewCSRFConfig()
+	c.Mode = "foo"
+	_, err := NewCSRF(c)
+	assert.NotNil(err)
+	cfg := mockConfig()
+	cfg.Set("httpserver.csrf.enable", true)
+	cfg.Set("httpserver.csrf.mode", "foo")
+	s := NewHTTPServer(gcore.Providers.Ctx("")())
+	assert.NotNil(s.Init(cfg))
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghealth "github.com/snail007/gmc/module/health"
+)
+
+// bindHealth binds /healthz and /readyz to router if the `health` sub section
+// of section in app.toml is enabled, such as [httpserver.health].
+func bindHealth(cfg gcore.Config, section string, router gcore.HTTPRouter) {
+	prefix := section + ".health."
+	if cfg == nil || !cfg.GetBool(prefix+"enable") {
+		return
+	}
+	ghealth.BindRouter(router, cfg.GetString(prefix+"healthpath"), cfg.GetString(prefix+"readypath"))
+}
+
+// drain sets the app not ready, and waits for load balancers to stop sending
+// traffic, the time is `drainwait` of the `health` sub section in seconds.
+func drain(cfg gcore.Config, section string) {
+	ghealth.SetReady(false)
+	if cfg == nil || !cfg.GetBool(section+".health.enable") {
+		return
+	}
+	time.Sleep(time.Duration(cfg.GetInt(section+".health.drainwait")) * time.Second)
+}
+
+// HealthCheck implements gcore.HealthChecker, it fails when the server is
+// shutting down.
+func (s *HTTPServer) HealthCheck(ctx context.Context) error {
+	if s.isShutdown {
+		return fmt.Errorf("http server is shutting down")
+	}
+	return nil
+}
+
+// HealthCheck implements gcore.HealthChecker, it fails when the server is
+// shutting down.
+func (this *APIServer) HealthCheck(ctx context.Context) error {
+	if this.isShutdown {
+		return fmt.Errorf("api server is shutting down")
+	}
+	return nil
+}
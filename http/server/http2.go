@@ -0,0 +1,64 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"net/http"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTP2Config is the HTTP/2 options of HTTPServer and APIServer.
+type HTTP2Config struct {
+	// H2C enables HTTP/2 over cleartext TCP, both prior knowledge and
+	// upgrading from HTTP/1.1 are supported. It's intended for internal
+	// service-to-service traffic, browsers only use HTTP/2 over TLS.
+	H2C bool
+	// MaxConcurrentStreams is the number of concurrent streams a client
+	// may have open at a time, 0 means the default 250.
+	MaxConcurrentStreams uint32
+	// MaxReadFrameSize is the largest frame the server will read,
+	// 0 means the default 1MB, valid values are 16KB to 16MB.
+	MaxReadFrameSize uint32
+	// IdleTimeout is how long until an idle connection is closed,
+	// 0 means the IdleTimeout of http.Server is used.
+	IdleTimeout time.Duration
+}
+
+// NewHTTP2ConfigFromConfig parses the `http2` sub section of section in
+// app.toml, such as [httpserver.http2], [apiserver.http2].
+func NewHTTP2ConfigFromConfig(cfg gcore.Config, section string) *HTTP2Config {
+	prefix := section + ".http2."
+	return &HTTP2Config{
+		H2C:                  cfg.GetBool(prefix + "h2c"),
+		MaxConcurrentStreams: uint32(cfg.GetInt(prefix + "maxconcurrentstreams")),
+		MaxReadFrameSize:     uint32(cfg.GetInt(prefix + "maxreadframesize")),
+		IdleTimeout:          time.Duration(cfg.GetInt(prefix+"idletimeout")) * time.Second,
+	}
+}
+
+// configureHTTP2 applies c to server, the handler of server is wrapped
+// with h2c if c.H2C is true, so it must be called after the handler set.
+func configureHTTP2(server *http.Server, c *HTTP2Config) (err error) {
+	if c == nil {
+		return
+	}
+	h2s := &http2.Server{
+		MaxConcurrentStreams: c.MaxConcurrentStreams,
+		MaxReadFrameSize:     c.MaxReadFrameSize,
+		IdleTimeout:          c.IdleTimeout,
+	}
+	if err = http2.ConfigureServer(server, h2s); err != nil {
+		return
+	}
+	if c.H2C {
+		server.Handler = h2c.NewHandler(server.Handler, h2s)
+	}
+	return
+}
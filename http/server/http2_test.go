@@ -0,0 +1,96 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+)
+
+func h2cGet(url string) (body string, proto string, err error) {
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	return string(b), resp.Proto, err
+}
+
+func TestNewHTTP2ConfigFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := mockConfig()
+	cfg.Set("apiserver.http2.h2c", true)
+	cfg.Set("apiserver.http2.maxconcurrentstreams", 100)
+	cfg.Set("apiserver.http2.maxreadframesize", 1<<16)
+	cfg.Set("apiserver.http2.idletimeout", 30)
+	c := NewHTTP2ConfigFromConfig(cfg, "apiserver")
+	assert.True(c.H2C)
+	assert.Equal(uint32(100), c.MaxConcurrentStreams)
+	assert.Equal(uint32(1<<16), c.MaxReadFrameSize)
+	assert.Equal(30*time.Second, c.IdleTimeout)
+	assert.False(NewHTTP2ConfigFromConfig(cfg, "httpserver").H2C)
+}
+
+func TestAPIServer_H2C(t *testing.T) {
+	assert := assert.New(t)
+	cfg := mockConfig()
+	cfg.Set("apiserver.listen", ":")
+	cfg.Set("apiserver.keepalive", true)
+	cfg.Set("apiserver.idletimeout", 10)
+	cfg.Set("apiserver.http2.h2c", true)
+	api, err := NewDefaultAPIServer(gcore.Providers.Ctx("")(), cfg)
+	assert.Nil(err)
+	assert.Equal(10*time.Second, api.Server().IdleTimeout)
+	api.API("/proto", func(ctx gcore.Ctx) {
+		ctx.Write(ctx.Request().Proto)
+	})
+	s := httptest.NewServer(api.Server().Handler)
+	defer s.Close()
+	body, proto, err := h2cGet(s.URL + "/proto")
+	assert.Nil(err)
+	assert.Equal("HTTP/2.0", proto)
+	assert.Equal("HTTP/2.0", body)
+
+	// HTTP/1.1 still works.
+	resp, err := http.Get(s.URL + "/proto")
+	assert.Nil(err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal("HTTP/1.1", string(b))
+}
+
+func TestHTTPServer_H2C(t *testing.T) {
+	assert := assert.New(t)
+	cfg := mockConfig()
+	cfg.Set("httpserver.http2.h2c", true)
+	srv := mockHTTPServer(cfg)
+	srv.router.HandlerFunc("GET", "/proto", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	s := httptest.NewServer(srv.Server().Handler)
+	defer s.Close()
+	body, _, err := h2cGet(s.URL + "/proto")
+	assert.Nil(err)
+	assert.Equal("HTTP/2.0", body)
+}
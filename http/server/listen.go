@@ -0,0 +1,143 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// UnixSocketPrefix is the prefix of unix domain socket listen address,
+// such as unix:/var/run/app.sock
+const UnixSocketPrefix = "unix:"
+
+// UnixSocketOptions is the file options of unix domain socket listeners.
+type UnixSocketOptions struct {
+	// Mode is the file mode of the socket file, 0 means umask applied.
+	Mode os.FileMode
+	// User and Group are the owner of the socket file, name or id, empty
+	// means the current user and group.
+	User  string
+	Group string
+}
+
+// NewUnixSocketOptionsFromConfig parses the unix socket options of section
+// in app.toml, such as [httpserver], [apiserver].
+func NewUnixSocketOptionsFromConfig(cfg gcore.Config, section string) (opt *UnixSocketOptions, err error) {
+	opt = &UnixSocketOptions{
+		User:  cfg.GetString(section + ".unixsocketuser"),
+		Group: cfg.GetString(section + ".unixsocketgroup"),
+	}
+	if v := cfg.GetString(section + ".unixsocketmode"); v != "" {
+		mode, e := strconv.ParseUint(v, 8, 32)
+		if e != nil {
+			return nil, fmt.Errorf("invalid unix socket mode %s", v)
+		}
+		opt.Mode = os.FileMode(mode)
+	}
+	return
+}
+
+// ListenAddrs splits addrs separated by comma, such as
+// ":7080,unix:/var/run/app.sock", empty items are ignored.
+func ListenAddrs(addrs string) (list []string) {
+	for _, v := range strings.Split(addrs, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return
+}
+
+// Listen listens on addr, addr with prefix `unix:` is an unix domain socket,
+// others are tcp addresses. The stale socket file is removed before
+// listening, opt is applied to the socket file if not nil.
+func Listen(addr string, opt *UnixSocketOptions) (l net.Listener, err error) {
+	if !strings.HasPrefix(addr, UnixSocketPrefix) {
+		return net.Listen("tcp", addr)
+	}
+	path := strings.TrimPrefix(addr, UnixSocketPrefix)
+	if info, e := os.Stat(path); e == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err = net.Listen("unix", path)
+	if err != nil || opt == nil {
+		return
+	}
+	if err = opt.apply(path); err != nil {
+		l.Close()
+		l = nil
+	}
+	return
+}
+
+func (opt *UnixSocketOptions) apply(path string) (err error) {
+	if opt.Mode != 0 {
+		if err = os.Chmod(path, opt.Mode); err != nil {
+			return
+		}
+	}
+	if opt.User == "" && opt.Group == "" {
+		return
+	}
+	uid, gid := -1, -1
+	if opt.User != "" {
+		if uid, err = lookupID(opt.User, false); err != nil {
+			return
+		}
+	}
+	if opt.Group != "" {
+		if gid, err = lookupID(opt.Group, true); err != nil {
+			return
+		}
+	}
+	return os.Chown(path, uid, gid)
+}
+
+func lookupID(name string, isGroup bool) (id int, err error) {
+	if id, err = strconv.Atoi(name); err == nil {
+		return
+	}
+	var v string
+	if isGroup {
+		g, e := user.LookupGroup(name)
+		if e != nil {
+			return 0, e
+		}
+		v = g.Gid
+	} else {
+		u, e := user.Lookup(name)
+		if e != nil {
+			return 0, e
+		}
+		v = u.Uid
+	}
+	return strconv.Atoi(v)
+}
+
+// listenerAddr returns the address of l, it's in the format of listen
+// address, such as 127.0.0.1:7080, unix:/var/run/app.sock
+func listenerAddr(l net.Listener) string {
+	if l.Addr().Network() == "unix" {
+		return UnixSocketPrefix + l.Addr().String()
+	}
+	return l.Addr().String()
+}
+
+// listenersAddr joins the addresses of listeners with comma.
+func listenersAddr(listeners []net.Listener) string {
+	addrs := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		addrs = append(addrs, listenerAddr(l))
+	}
+	return strings.Join(addrs, ",")
+}
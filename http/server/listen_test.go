@@ -0,0 +1,121 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+}
+
+func TestListenAddrs(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{":7080", "unix:/tmp/a.sock"}, ListenAddrs(" :7080, ,unix:/tmp/a.sock"))
+	assert.Empty(ListenAddrs(""))
+}
+
+func TestListen_Unix(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc-unix")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.sock")
+	cfg := mockConfig()
+	cfg.Set("httpserver.unixsocketmode", "0600")
+	cfg.Set("httpserver.unixsocketgroup", os.Getegid())
+	opt, err := NewUnixSocketOptionsFromConfig(cfg, "httpserver")
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0600), opt.Mode)
+
+	l, err := Listen(UnixSocketPrefix+path, opt)
+	assert.Nil(err)
+	assert.Equal(UnixSocketPrefix+path, listenerAddr(l))
+	info, err := os.Stat(path)
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// a stale socket file left by a crashed process is replaced.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	l, err = Listen(UnixSocketPrefix+path, nil)
+	assert.Nil(err)
+	l.Close()
+
+	cfg.Set("httpserver.unixsocketmode", "abc")
+	_, err = NewUnixSocketOptionsFromConfig(cfg, "httpserver")
+	assert.NotNil(err)
+	_, err = Listen(UnixSocketPrefix+path, &UnixSocketOptions{User: "gmc-none-user"})
+	assert.NotNil(err)
+}
+
+func TestHTTPServer_MultiListen(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc-unix")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "web.sock")
+	cfg := mockConfig()
+	cfg.Set("httpserver.listen", []string{"127.0.0.1:", UnixSocketPrefix + path})
+	s := mockHTTPServer(cfg)
+	s.addr = strings.Join(cfg.GetStringSlice("httpserver.listen"), ",")
+	s.router.HandlerFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	assert.Nil(s.Listen())
+	defer s.Close()
+	assert.Len(s.Listeners(), 2)
+	assert.Equal(s.Listeners()[0], s.Listener())
+	assert.True(strings.HasSuffix(s.addr, ","+UnixSocketPrefix+path))
+
+	for _, c := range []*http.Client{http.DefaultClient, unixClient(path)} {
+		resp, err := c.Get("http://" + s.Listener().Addr().String() + "/")
+		if assert.Nil(err) {
+			b, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal("hello", string(b))
+		}
+	}
+
+	s0 := mockHTTPServer(cfg)
+	s0.addr = "127.0.0.1:," + s.Listener().Addr().String()
+	assert.NotNil(s0.Listen())
+}
+
+func TestAPIServer_MultiListen(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc-unix")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "api.sock")
+	api := NewAPIServer(gcore.Providers.Ctx("")(), "127.0.0.1:,"+UnixSocketPrefix+path)
+	api.API("/", func(c gcore.Ctx) {
+		c.Write("hello")
+	})
+	assert.Nil(api.Run())
+	defer api.Stop()
+	assert.Len(api.Listeners(), 2)
+	assert.Equal(UnixSocketPrefix+path, listenerAddr(api.Listeners()[1]))
+
+	resp, err := unixClient(path).Get("http://unix/")
+	if assert.Nil(err) {
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal("hello", string(b))
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	gcore "github.com/snail007/gmc/core"
+	gmetrics "github.com/snail007/gmc/module/metrics"
+)
+
+type metricsServer interface {
+	ActiveConnCount() int64
+	AddMiddleware3(m gcore.Middleware)
+}
+
+// bindMetrics records the requests and connections of server, and binds the
+// metrics handler to router if the `metrics` sub section of section in
+// app.toml is enabled, such as [httpserver.metrics].
+func bindMetrics(cfg gcore.Config, section string, router gcore.HTTPRouter, server metricsServer) {
+	prefix := section + ".metrics."
+	if cfg == nil || !cfg.GetBool(prefix+"enable") {
+		return
+	}
+	m := gmetrics.NewHTTPMetrics(section)
+	server.AddMiddleware3(m.Middleware)
+	gmetrics.Register(section+".requests", m)
+	gmetrics.Register(section+".connections", gmetrics.ConnCollector(section, server))
+	gmetrics.BindRouter(router, cfg.GetString(prefix+"path"))
+}
@@ -0,0 +1,183 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// CSPNoncePlaceholder in SecureConfig.CSP is replaced with the nonce of
+// request, such as `script-src 'self' 'nonce-{nonce}'`.
+const CSPNoncePlaceholder = "{nonce}"
+
+// SecureConfig is the options of Secure, an empty value disables the header.
+type SecureConfig struct {
+	// HSTSMaxAge is the max-age of Strict-Transport-Security in seconds, it's
+	// only sent in HTTPS requests, the request has TLS or the header
+	// X-Forwarded-Proto is https.
+	HSTSMaxAge int
+	// HSTSIncludeSubdomains adds includeSubDomains to
+	// Strict-Transport-Security.
+	HSTSIncludeSubdomains bool
+	// HSTSPreload adds preload to Strict-Transport-Security.
+	HSTSPreload bool
+	// ContentTypeNosniff sets X-Content-Type-Options to nosniff.
+	ContentTypeNosniff bool
+	// FrameOptions is the X-Frame-Options, such as DENY or SAMEORIGIN.
+	FrameOptions string
+	// ReferrerPolicy is the Referrer-Policy.
+	ReferrerPolicy string
+	// PermissionsPolicy is the Permissions-Policy, such as
+	// `camera=(), microphone=()`.
+	PermissionsPolicy string
+	// CSP is the Content-Security-Policy, CSPNoncePlaceholder in it is
+	// replaced with a new nonce of each request.
+	CSP string
+	// CSPReportOnly sends CSP as Content-Security-Policy-Report-Only.
+	CSPReportOnly bool
+}
+
+// NewSecureConfig returns the default options of Secure.
+func NewSecureConfig() *SecureConfig {
+	return &SecureConfig{
+		HSTSMaxAge:         31536000,
+		ContentTypeNosniff: true,
+		FrameOptions:       "SAMEORIGIN",
+		ReferrerPolicy:     "strict-origin-when-cross-origin",
+	}
+}
+
+// NewSecureConfigFromConfig parses the `secure` sub section of section in
+// app.toml, such as [httpserver.secure], the keys not set are the default
+// of NewSecureConfig.
+func NewSecureConfigFromConfig(cfg gcore.Config, section string) *SecureConfig {
+	prefix := section + ".secure."
+	c := NewSecureConfig()
+	for k, v := range map[string]*string{
+		"frameoptions":      &c.FrameOptions,
+		"referrerpolicy":    &c.ReferrerPolicy,
+		"permissionspolicy": &c.PermissionsPolicy,
+		"csp":               &c.CSP,
+	} {
+		if cfg.IsSet(prefix + k) {
+			*v = cfg.GetString(prefix + k)
+		}
+	}
+	for k, v := range map[string]*bool{
+		"hstsincludesubdomains": &c.HSTSIncludeSubdomains,
+		"hstspreload":           &c.HSTSPreload,
+		"contenttypenosniff":    &c.ContentTypeNosniff,
+		"cspreportonly":         &c.CSPReportOnly,
+	} {
+		if cfg.IsSet(prefix + k) {
+			*v = cfg.GetBool(prefix + k)
+		}
+	}
+	if cfg.IsSet(prefix + "hstsmaxage") {
+		c.HSTSMaxAge = cfg.GetInt(prefix + "hstsmaxage")
+	}
+	return c
+}
+
+// Secure sets the security headers of responses. The headers are set before
+// routing, so a handler can override them. If the CSP contains
+// CSPNoncePlaceholder, the nonce of request is set in Ctx by
+// gcore.CSPNonceKey, the controller sets it in view data as `CSPNonce`, then
+// an inline script can be allowed by `<script nonce="{{.CSPNonce}}">`.
+type Secure struct {
+	config    *SecureConfig
+	hsts      string
+	csp       string
+	cspHeader string
+	nonce     bool
+}
+
+// NewSecure creates a Secure by c.
+func NewSecure(c *SecureConfig) *Secure {
+	s := &Secure{
+		config:    c,
+		csp:       strings.TrimSpace(c.CSP),
+		cspHeader: "Content-Security-Policy",
+	}
+	if c.HSTSMaxAge > 0 {
+		s.hsts = "max-age=" + strconv.Itoa(c.HSTSMaxAge)
+		if c.HSTSIncludeSubdomains {
+			s.hsts += "; includeSubDomains"
+		}
+		if c.HSTSPreload {
+			s.hsts += "; preload"
+		}
+	}
+	if c.CSPReportOnly {
+		s.cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	s.nonce = strings.Contains(s.csp, CSPNoncePlaceholder)
+	return s
+}
+
+// Handle is a middleware0 sets the security headers of response.
+func (s *Secure) Handle(ctx gcore.Ctx) (isStop bool) {
+	r := ctx.Request()
+	header := ctx.Response().Header()
+	if s.hsts != "" && (r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")) {
+		header.Set("Strict-Transport-Security", s.hsts)
+	}
+	if s.config.ContentTypeNosniff {
+		header.Set("X-Content-Type-Options", "nosniff")
+	}
+	if s.config.FrameOptions != "" {
+		header.Set("X-Frame-Options", s.config.FrameOptions)
+	}
+	if s.config.ReferrerPolicy != "" {
+		header.Set("Referrer-Policy", s.config.ReferrerPolicy)
+	}
+	if s.config.PermissionsPolicy != "" {
+		header.Set("Permissions-Policy", s.config.PermissionsPolicy)
+	}
+	if s.csp == "" {
+		return false
+	}
+	csp := s.csp
+	if s.nonce {
+		nonce, err := newCSPNonce()
+		if err != nil {
+			ctx.WriteHeader(http.StatusInternalServerError)
+			ctx.Write("Internal Server Error, " + err.Error())
+			return true
+		}
+		ctx.Set(gcore.CSPNonceKey, nonce)
+		csp = strings.Replace(csp, CSPNoncePlaceholder, nonce, -1)
+	}
+	header.Set(s.cspHeader, csp)
+	return false
+}
+
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+type secureServer interface {
+	AddMiddleware0(m gcore.Middleware)
+}
+
+// bindSecure sets the security headers of responses if the `secure` sub
+// section of section in app.toml is enabled, such as [httpserver.secure].
+func bindSecure(cfg gcore.Config, section string, server secureServer) {
+	if cfg == nil || !cfg.GetBool(section+".secure.enable") {
+		return
+	}
+	server.AddMiddleware0(NewSecure(NewSecureConfigFromConfig(cfg, section)).Handle)
+}
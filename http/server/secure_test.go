@@ -0,0 +1,80 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecure(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gcore.Providers.Config("")()
+	cfg.Set("apiserver.listen", "127.0.0.1:")
+	cfg.Set("apiserver.secure.enable", true)
+	cfg.Set("apiserver.secure.hstsincludesubdomains", true)
+	cfg.Set("apiserver.secure.permissionspolicy", "camera=()")
+	cfg.Set("apiserver.secure.csp", "script-src 'self' 'nonce-{nonce}'")
+	api, err := NewDefaultAPIServer(gcore.Providers.Ctx("")(), cfg)
+	assert.Nil(err)
+	api.API("/", func(c gcore.Ctx) {
+		nonce, _ := c.Get(gcore.CSPNonceKey)
+		c.Write(nonce)
+	})
+	var nonces []string
+	for i := 0; i < 2; i++ {
+		w, r := mockRequest("/")
+		api.ServeHTTP(w, r)
+		h := w.Header()
+		nonce := w.Body.String()
+		assert.Len(nonce, 24)
+		assert.Equal("script-src 'self' 'nonce-"+nonce+"'", h.Get("Content-Security-Policy"))
+		assert.Equal("", h.Get("Strict-Transport-Security"))
+		assert.Equal("nosniff", h.Get("X-Content-Type-Options"))
+		assert.Equal("SAMEORIGIN", h.Get("X-Frame-Options"))
+		assert.Equal("strict-origin-when-cross-origin", h.Get("Referrer-Policy"))
+		assert.Equal("camera=()", h.Get("Permissions-Policy"))
+		nonces = append(nonces, nonce)
+	}
+	assert.NotEqual(nonces[0], nonces[1])
+
+	w, r := mockRequest("/none")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	api.ServeHTTP(w, r)
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Equal("max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+	assert.True(strings.HasPrefix(w.Header().Get("Content-Security-Policy"), "script-src 'self' 'nonce-"))
+}
+
+func TestSecure_HTTPServer(t *testing.T) {
+	assert := assert.New(t)
+	cfg := mockConfig()
+	cfg.Set("httpserver.secure.enable", true)
+	cfg.Set("httpserver.secure.hstsmaxage", 0)
+	cfg.Set("httpserver.secure.contenttypenosniff", false)
+	cfg.Set("httpserver.secure.frameoptions", "DENY")
+	cfg.Set("httpserver.secure.referrerpolicy", "")
+	cfg.Set("httpserver.secure.csp", "default-src 'self'")
+	cfg.Set("httpserver.secure.cspreportonly", true)
+	s := mockHTTPServer(cfg)
+	s.router.HandlerFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	})
+	w, r := mockRequest("/")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	s.ServeHTTP(w, r)
+	h := w.Header()
+	assert.Equal("", h.Get("Strict-Transport-Security"))
+	assert.Equal("", h.Get("X-Content-Type-Options"))
+	assert.Equal("", h.Get("Referrer-Policy"))
+	assert.Equal("SAMEORIGIN", h.Get("X-Frame-Options"))
+	assert.Equal("", h.Get("Content-Security-Policy"))
+	assert.Equal("default-src 'self'", h.Get("Content-Security-Policy-Report-Only"))
+}
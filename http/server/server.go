@@ -6,21 +6,16 @@
 package ghttpserver
 
 import (
-	"compress/gzip"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	gcore "github.com/snail007/gmc/core"
 	ghttputil "github.com/snail007/gmc/internal/util/http"
+	"golang.org/x/crypto/acme/autocert"
 	"io"
-	"io/ioutil"
 	"log"
-	"mime"
 	"net"
 	"net/http"
-	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -40,6 +35,7 @@ func SetBinData(data map[string]string) {
 		}
 		bindata[k] = b
 	}
+	bindataModTime = time.Now()
 }
 
 type HTTPServer struct {
@@ -50,6 +46,7 @@ type HTTPServer struct {
 	logger       gcore.Logger
 	addr         string
 	listener     net.Listener
+	listeners    []net.Listener
 	server       *http.Server
 	connCnt      *int64
 	config       gcore.Config
@@ -59,6 +56,8 @@ type HTTPServer struct {
 	isTestNotClosedError bool
 	staticDir            string
 	staticUrlpath        string
+	staticHandler        http.Handler
+	csrf                 *CSRF
 	middleware0          []gcore.Middleware
 	middleware1          []gcore.Middleware
 	middleware2          []gcore.Middleware
@@ -66,6 +65,10 @@ type HTTPServer struct {
 	isShutdown           bool
 	remoteAddrDataMap    *sync.Map
 	ctx                  gcore.Ctx
+	certManager          *CertManager
+	acme                 *autocert.Manager
+	acmeHTTPListen       string
+	acmeServer           *http.Server
 }
 
 func (s *HTTPServer) SetCtx(ctx gcore.Ctx) {
@@ -104,9 +107,21 @@ func (s *HTTPServer) Init(cfg gcore.Config) (err error) {
 
 	//init base objects
 	err = s.initBaseObjets()
+	if err != nil {
+		return
+	}
+
+	// init http2, must be after tls configuration inited
+	err = s.ConfigureHTTP2(NewHTTP2ConfigFromConfig(s.config, "httpserver"))
 	return
 }
 
+// ConfigureHTTP2 applies the HTTP/2 options, it's called in Init with
+// [httpserver.http2] of app.toml, and should not be called again.
+func (s *HTTPServer) ConfigureHTTP2(c *HTTP2Config) (err error) {
+	return configureHTTP2(s.server, c)
+}
+
 func (s *HTTPServer) Ctx() gcore.Ctx {
 	return s.ctx
 }
@@ -143,10 +158,43 @@ func (s *HTTPServer) initBaseObjets() (err error) {
 
 	// init http server router
 	s.router = gcore.Providers.HTTPRouter("")(s.ctx)
-	s.addr = s.config.GetString("httpserver.listen")
+	s.addr = strings.Join(s.config.GetStringSlice("httpserver.listen"), ",")
 
 	// init static files handler, must be after router inited
-	s.initStatic()
+	if err = s.initStatic(); err != nil {
+		return
+	}
+
+	// init acme challenges handler, must be after router inited
+	s.initACMEChallenge()
+
+	// init health handlers, must be after router inited
+	bindHealth(s.config, "httpserver", s.router)
+
+	// init request tracing, must be before any middleware added
+	bindTrace(s.config, s)
+
+	// init metrics handler, must be after router inited
+	bindMetrics(s.config, "httpserver", s.router, s)
+
+	// init security headers of responses
+	bindSecure(s.config, "httpserver", s)
+
+	// init cross-origin requests handler
+	if err = bindCORS(s.config, "httpserver", s); err != nil {
+		return
+	}
+
+	// init csrf protection of forms
+	if s.csrf, err = bindCSRF(s.config, "httpserver", s); err != nil {
+		return
+	}
+	if s.csrf != nil && s.staticHandler != nil {
+		s.csrf.Exempt(s.staticUrlpath + "*filepath")
+	}
+
+	// init response compression
+	err = bindCompress(s.config, "httpserver", s)
 	return
 }
 func (this *HTTPServer) initRequestCtx(w http.ResponseWriter, r *http.Request) gcore.Ctx {
@@ -165,6 +213,7 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// init ctx
 	reqCtx := s.initRequestCtx(w, r)
 	defer func() {
+		closeSSE(reqCtx)
 		// middleware3
 		s.callMiddleware(reqCtx, s.middleware3)
 	}()
@@ -177,6 +226,9 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h, params, _ := s.router.Lookup(r.Method, r.URL.Path)
 	if h != nil {
 		reqCtx.SetParam(params)
+		if _, method := s.router.RouteController(params.MatchedRoutePath()); method != "" {
+			reqCtx.SetControllerMethod(method)
+		}
 		// middleware1
 		if s.callMiddleware(reqCtx, s.middleware1) {
 			return
@@ -186,6 +238,7 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		status := ""
 		err := s.call(func() { h(reqCtx.Response(), reqCtx.Request(), reqCtx.Param()) })
 		reqCtx.SetTimeUsed(time.Now().Sub(start))
+		closeSSE(reqCtx)
 		if err != nil {
 			status = fmt.Sprintf("%s", err)
 			switch status {
@@ -200,12 +253,37 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if s.callMiddleware(reqCtx, s.middleware2) {
 			return
 		}
-	} else {
+	} else if !serveOPTIONS(s.router, reqCtx) {
 		//404
 		s.handle40x(reqCtx)
 	}
 
 }
+
+// serveOPTIONS replies the OPTIONS request has no handler with the Allow
+// header of the methods of path in router, it returns false if path has
+// no handler of any method.
+func serveOPTIONS(router gcore.HTTPRouter, ctx gcore.Ctx) bool {
+	r := ctx.Request()
+	if r.Method != http.MethodOptions {
+		return false
+	}
+	allow := router.Allowed(r.URL.Path)
+	if allow == "" {
+		return false
+	}
+	ctx.SetHeader("Allow", allow)
+	ctx.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// closeSSE closes the SSE stream started by the handler, so the heartbeat
+// stops writing before the following middlewares run.
+func closeSSE(ctx gcore.Ctx) {
+	if v, ok := ctx.Get(gcore.SSEKey); ok {
+		v.(gcore.SSE).Close()
+	}
+}
 func (s *HTTPServer) call(fn func()) (err interface{}) {
 	func() {
 		defer gcore.Providers.Error("")().Recover(func(e interface{}) {
@@ -266,6 +344,12 @@ func (s *HTTPServer) ActiveConnCount() int64 {
 	return atomic.LoadInt64(s.connCnt)
 }
 func (s *HTTPServer) Close() {
+	if s.certManager != nil {
+		s.certManager.Stop()
+	}
+	if s.acmeServer != nil {
+		s.acmeServer.Close()
+	}
 	s.server.Close()
 }
 func (s *HTTPServer) Listener() net.Listener {
@@ -274,11 +358,12 @@ func (s *HTTPServer) Listener() net.Listener {
 
 //Listeners implements service.Service Listeners
 func (s *HTTPServer) Listeners() []net.Listener {
-	return []net.Listener{s.listener}
+	return s.listeners
 }
 
 //InjectListeners implements service.Service InjectListeners
 func (s *HTTPServer) InjectListeners(l []net.Listener) {
+	s.listeners = l
 	s.listener = l[0]
 }
 func (s *HTTPServer) Server() *http.Server {
@@ -334,12 +419,33 @@ func (s *HTTPServer) bind(addr string) {
 	s.addr = addr
 }
 func (s *HTTPServer) createListener() (err error) {
-	if s.listener == nil {
-		s.listener, err = net.Listen("tcp", s.addr)
-		if err == nil {
-			s.addr = s.listener.Addr().String()
+	if len(s.listeners) == 0 {
+		var opt *UnixSocketOptions
+		if s.config != nil {
+			opt, err = NewUnixSocketOptionsFromConfig(s.config, "httpserver")
+			if err != nil {
+				return
+			}
+		}
+		addrs := ListenAddrs(s.addr)
+		if len(addrs) == 0 {
+			addrs = []string{""}
+		}
+		listeners := []net.Listener{}
+		for _, addr := range addrs {
+			l, e := Listen(addr, opt)
+			if e != nil {
+				for _, v := range listeners {
+					v.Close()
+				}
+				return e
+			}
+			listeners = append(listeners, l)
 		}
+		s.listeners = listeners
 	}
+	s.listener = s.listeners[0]
+	s.addr = listenersAddr(s.listeners)
 	return
 }
 func (s *HTTPServer) Listen() (err error) {
@@ -347,27 +453,10 @@ func (s *HTTPServer) Listen() (err error) {
 	if err != nil {
 		return
 	}
-	go func() {
-		for {
-			err := s.server.Serve(s.listener)
-			if err != nil {
-				if !s.isTestNotClosedError && strings.Contains(err.Error(), "closed") {
-					if s.isShutdown {
-						s.logger.Infof("http server graceful shutdown on http://%s", s.addr)
-					} else {
-						s.logger.Infof("http server closed on http://%s", s.addr)
-						s.server.Close()
-					}
-					break
-				} else {
-					s.logger.Warnf("http server Serve fail on http://%s , error : %s", s.addr, err)
-					time.Sleep(time.Second * 3)
-					continue
-				}
-			}
-		}
-	}()
-	s.logger.Infof("http server listen on http://%s", s.listener.Addr())
+	for _, l := range s.listeners {
+		go s.serve(l, false)
+		s.logger.Infof("http server listen on http://%s", listenerAddr(l))
+	}
 	return
 }
 func (s *HTTPServer) ListenTLS() (err error) {
@@ -375,29 +464,52 @@ func (s *HTTPServer) ListenTLS() (err error) {
 	if err != nil {
 		return
 	}
-	go func() {
-		for {
-			err := s.server.ServeTLS(s.listener, s.config.GetString("httpserver.tlscert"),
+	err = s.listenACMEHTTP()
+	if err != nil {
+		return
+	}
+	for _, l := range s.listeners {
+		go s.serve(l, true)
+		s.logger.Infof("https server listen on https://%s", listenerAddr(l))
+	}
+	return
+}
+
+// serve serves l until the server closed, it retries after 3 seconds when
+// serving fail.
+func (s *HTTPServer) serve(l net.Listener, isTLS bool) {
+	scheme := "http"
+	if isTLS {
+		scheme = "https"
+	}
+	addr := listenerAddr(l)
+	for {
+		var err error
+		if !isTLS {
+			err = s.server.Serve(l)
+		} else if s.certManager != nil || s.acme != nil {
+			// certificates are served by tls.Config.GetCertificate
+			err = s.server.ServeTLS(l, "", "")
+		} else {
+			err = s.server.ServeTLS(l, s.config.GetString("httpserver.tlscert"),
 				s.config.GetString("httpserver.tlskey"))
-			if err != nil {
-				if !s.isTestNotClosedError && strings.Contains(err.Error(), "closed") {
-					if s.isShutdown {
-						s.logger.Infof("https server graceful shutdown on https://%s", s.addr)
-					} else {
-						s.logger.Infof("https server closed on https://%s", s.addr)
-						s.server.Close()
-					}
-					break
+		}
+		if err != nil {
+			if !s.isTestNotClosedError && strings.Contains(err.Error(), "closed") {
+				if s.isShutdown {
+					s.logger.Infof("%s server graceful shutdown on %s://%s", scheme, scheme, addr)
 				} else {
-					s.logger.Warnf("http server ServeTLS fail , error : %s", err)
-					time.Sleep(time.Second * 3)
-					continue
+					s.logger.Infof("%s server closed on %s://%s", scheme, scheme, addr)
+					s.server.Close()
 				}
+				break
+			} else {
+				s.logger.Warnf("%s server Serve fail on %s://%s , error : %s", scheme, scheme, addr, err)
+				time.Sleep(time.Second * 3)
+				continue
 			}
 		}
-	}()
-	s.logger.Infof("https server listen on https://%s", s.listener.Addr())
-	return
+	}
 }
 
 //ConnState count the active conntions
@@ -405,91 +517,78 @@ func (s *HTTPServer) connState(c net.Conn, st http.ConnState) {
 	switch st {
 	case http.StateNew:
 		atomic.AddInt64(s.connCnt, 1)
+		if c.RemoteAddr().String() == "" {
+			// unix socket connections have no remote address to be distinguished.
+			return
+		}
 		s.remoteAddrDataMap.Store(c.RemoteAddr().String(), remoteAddrItem{
 			remoteAddr: c.RemoteAddr().String(),
 			localAddr:  c.LocalAddr().String(),
 			conn:       c,
 		})
-	case http.StateClosed:
+	case http.StateClosed, http.StateHijacked:
+		// hijacked connections, such as websocket and h2c, are not tracked by http.Server any more.
 		atomic.AddInt64(s.connCnt, -1)
 		s.remoteAddrDataMap.Delete(c.RemoteAddr().String())
 	}
 }
 
 // must be called after router inited
-func (s *HTTPServer) initStatic() {
+func (s *HTTPServer) initStatic() (err error) {
 	s.staticDir = s.config.GetString("static.dir")
 	s.staticUrlpath = s.config.GetString("static.urlpath")
-	if s.staticDir != "" && s.staticUrlpath != "" {
+	if (s.staticDir != "" || staticFS != nil) && s.staticUrlpath != "" {
 		if strings.HasSuffix(s.staticUrlpath, "/") {
 			s.staticUrlpath = strings.TrimRight(s.staticUrlpath, "/")
 		}
+		h, e := NewStaticHandler(NewStaticConfigFromConfig(s.config))
+		if e != nil {
+			return e
+		}
+		s.staticHandler = http.StripPrefix(s.staticUrlpath, h)
 		s.router.HandlerFunc("GET", s.staticUrlpath+"/*filepath", s.serveStatic)
+		s.router.HandlerFunc("HEAD", s.staticUrlpath+"/*filepath", s.serveStatic)
 		s.staticUrlpath += "/"
 	}
+	return
 }
 func (s *HTTPServer) initTLSConfig() (err error) {
 	if s.config.GetBool("httpserver.tlsenable") {
-		tlsCfg := &tls.Config{}
-		if s.config.GetBool("httpserver.tlsclientauth") {
-			tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if s.config.GetBool("httpserver.acme.enable") {
+			return s.initACME(NewACMEConfigFromConfig(s.config, "httpserver"))
 		}
-		clientCertPool := x509.NewCertPool()
-		caBytes, e := ioutil.ReadFile(s.config.GetString("httpserver.tlsclientsca"))
+		tlsCfg, m, e := newTLSConfig(s.config, "httpserver", s.logger)
 		if e != nil {
 			return e
 		}
-		ok := clientCertPool.AppendCertsFromPEM(caBytes)
-		if !ok {
-			err = gcore.Providers.Error("")().New(("failed to parse tls clients root certificate"))
-			return
+		if s.certManager != nil {
+			s.certManager.Stop()
 		}
-		tlsCfg.ClientCAs = clientCertPool
+		s.certManager = m
 		s.server.TLSConfig = tlsCfg
 	}
 	return
 }
 
+// CSRF returns the csrf protection created from [httpserver.csrf] of
+// app.toml, it's nil if csrf is not enabled there.
+func (s *HTTPServer) CSRF() *CSRF {
+	return s.csrf
+}
+
+// CertManager returns the certificates manager of tls, it's nil if tls
+// is not enabled.
+func (s *HTTPServer) CertManager() *CertManager {
+	return s.certManager
+}
+
 func (s *HTTPServer) serveStatic(w http.ResponseWriter, r *http.Request) {
-	pathA := strings.Split(r.URL.Path, "?")
-	path := filepath.Clean(pathA[0])
-	path = strings.TrimPrefix(path, s.staticUrlpath)
-	var b []byte
-	var ok bool
-	//1. find in bindata
-	if len(bindata) > 0 {
-		b, ok = bindata[path]
-	}
-	//2. find in system path
-	if !ok {
-		var e error
-		b, e = ioutil.ReadFile(filepath.Join(s.staticDir, path))
-		ok = e == nil
-	}
-	if !ok {
+	if s.staticHandler == nil {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("Not Found"))
 		return
 	}
-	cacheSince := time.Now().Format(http.TimeFormat)
-	cacheUntil := time.Now().AddDate(66, 0, 0).Format(http.TimeFormat)
-	ext := filepath.Ext(path)
-	typ := mime.TypeByExtension(ext)
-	w.Header().Set("Cache-Control", "max-age:290304000, public")
-	w.Header().Set("Last-Modified", cacheSince)
-	w.Header().Set("Expires", cacheUntil)
-	w.Header().Set("Content-Type", typ)
-	gizpCheck := map[string]bool{".js": true, ".css": true}
-	if _, ok := gizpCheck[ext]; ok {
-		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			w.Header().Set("Content-Encoding", "gzip")
-			gz := gzip.NewWriter(w)
-			defer gz.Close()
-			gz.Write(b)
-			return
-		}
-	}
-	w.Write(b)
+	s.staticHandler.ServeHTTP(w, r)
 }
 
 // PrintRouteTable dump all routes into `w`, if `w` is nil, os.Stdout will be used.
@@ -527,8 +626,15 @@ func (s *HTTPServer) GracefulStop() {
 		return
 	}
 	s.isShutdown = true
+	drain(s.config, "httpserver")
+	if s.certManager != nil {
+		s.certManager.Stop()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
+	if s.acmeServer != nil {
+		s.acmeServer.Shutdown(ctx)
+	}
 	s.server.Shutdown(ctx)
 	return
 }
@@ -514,6 +514,20 @@ func Test_Controller_Args(t *testing.T) {
 	str, _ := result(w)
 	assert.Equal("hello/user/:args", str)
 }
+func Test_Controller_Middleware1(t *testing.T) {
+	assert := assert.New(t)
+	s := mockHTTPServer()
+	s.router.Controller("/user/", new(User))
+	s.AddMiddleware1(func(ctx gcore.Ctx) (isStop bool) {
+		controller, method := ctx.WebServer().Router().RouteController(ctx.FullPath())
+		ctx.Write(ctx.FullPath() + " " + controller + "." + ctx.ControllerMethod() + " " + method)
+		return true
+	})
+	w, r := mockRequest("/user/url")
+	s.ServeHTTP(w, r)
+	str, _ := result(w)
+	assert.Equal("/user/url User.URL URL", str)
+}
 
 type User struct {
 	gcontroller.Controller
@@ -0,0 +1,500 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcast "github.com/snail007/gmc/util/cast"
+	gfs "github.com/snail007/gmc/util/fs"
+)
+
+var (
+	// bindataModTime is the Last-Modified of the files in bindata.
+	bindataModTime = time.Now()
+	// staticFS is the embedded static files set by SetFS.
+	staticFS http.FileSystem
+)
+
+// SetFS sets the embedded static files, such as the result of
+// gfs.FromFS(embedFS, "static"). The `source` of [static] in app.toml
+// selects disk, embed or overlay of them, files in bindata are always
+// found first.
+func SetFS(fs http.FileSystem) {
+	staticFS = fs
+}
+
+// StaticCacheRule sets the Cache-Control of the files match Pattern.
+type StaticCacheRule struct {
+	// Pattern is a path.Match pattern, it matches the file name if it
+	// contains no `/`, otherwise it matches the path relative to the
+	// static dir, such as `*.html` and `js/*.js`.
+	Pattern string
+	// CacheControl is the value of Cache-Control header, empty means
+	// no Cache-Control header.
+	CacheControl string
+}
+
+// StaticConfig is the options of StaticHandler.
+type StaticConfig struct {
+	// Dir is the local filesystem path of static files.
+	Dir string
+	// Source is one of gfs.SourceDisk, gfs.SourceEmbed and gfs.SourceOverlay,
+	// it selects the files of Dir, the files set by SetFS, or both of them,
+	// and the disk files override the embedded ones. Empty means embed if
+	// SetFS is called, otherwise disk.
+	Source string
+	// FS overrides Dir and Source if it is not nil.
+	FS http.FileSystem
+	// Index is the file names served for a directory, the first found wins.
+	Index []string
+	// ListDir lists the files of a directory if it has no index file.
+	ListDir bool
+	// Precompressed serves the `.br` or `.gz` sibling of a file directly,
+	// if the client accepts it, such as `app.js.br` for `app.js`.
+	Precompressed bool
+	// GzipExt is the extensions of the files compressed by gzip on the fly
+	// if they have no precompressed sibling, the results are cached in
+	// memory.
+	GzipExt []string
+	// GzipMaxSize is the max bytes of the files compressed on the fly, the
+	// larger ones are served uncompressed.
+	GzipMaxSize int64
+	// GzipCacheSize and GzipCacheEntries limit the total bytes and count of
+	// the cached results, the least recently used ones are evicted.
+	GzipCacheSize    int64
+	GzipCacheEntries int
+	// CacheControl is the Cache-Control of the files match no CacheRules.
+	CacheControl string
+	// CacheRules sets Cache-Control by path pattern, the first matched wins.
+	CacheRules []StaticCacheRule
+}
+
+// NewStaticConfig returns the default options of StaticHandler.
+func NewStaticConfig() *StaticConfig {
+	return &StaticConfig{
+		Index:            []string{"index.html"},
+		Precompressed:    true,
+		GzipExt:          []string{".js", ".css"},
+		GzipMaxSize:      1 << 20,
+		GzipCacheSize:    32 << 20,
+		GzipCacheEntries: 1024,
+		CacheControl:     "public, max-age=86400",
+	}
+}
+
+// NewStaticConfigFromConfig parses the [static] section in app.toml.
+func NewStaticConfigFromConfig(cfg gcore.Config) *StaticConfig {
+	c := NewStaticConfig()
+	c.Dir = cfg.GetString("static.dir")
+	c.Source = cfg.GetString("static.source")
+	if cfg.IsSet("static.index") {
+		c.Index = cfg.GetStringSlice("static.index")
+	}
+	if cfg.IsSet("static.precompressed") {
+		c.Precompressed = cfg.GetBool("static.precompressed")
+	}
+	if cfg.IsSet("static.gzipext") {
+		c.GzipExt = cfg.GetStringSlice("static.gzipext")
+	}
+	if cfg.IsSet("static.gzipmaxsize") {
+		c.GzipMaxSize = cfg.GetInt64("static.gzipmaxsize")
+	}
+	if cfg.IsSet("static.gzipcachesize") {
+		c.GzipCacheSize = cfg.GetInt64("static.gzipcachesize")
+	}
+	if cfg.IsSet("static.gzipcacheentries") {
+		c.GzipCacheEntries = cfg.GetInt("static.gzipcacheentries")
+	}
+	if cfg.IsSet("static.cachecontrol") {
+		c.CacheControl = cfg.GetString("static.cachecontrol")
+	}
+	c.ListDir = cfg.GetBool("static.listdir")
+	rules, _ := cfg.Get("static.cacherules").([]interface{})
+	for _, v := range rules {
+		item := gcast.ToStringMapString(v)
+		c.CacheRules = append(c.CacheRules, StaticCacheRule{
+			Pattern:      item["pattern"],
+			CacheControl: item["cachecontrol"],
+		})
+	}
+	return c
+}
+
+// StaticHandler serves the static files in bindata, then in the Dir or FS of
+// StaticConfig, the path of request is relative to the static dir. It
+// supports conditional requests by ETag and Last-Modified, byte ranges,
+// directory index and precompressed files.
+type StaticHandler struct {
+	config    *StaticConfig
+	fs        http.FileSystem
+	gzipCache *gzipCache
+	etagCache sync.Map
+}
+
+// NewStaticHandler creates a StaticHandler by c.
+func NewStaticHandler(c *StaticConfig) (h *StaticHandler, err error) {
+	fs := c.FS
+	if fs == nil {
+		fs, err = gfs.Select(c.Source, c.Dir, staticFS)
+		if err != nil {
+			return
+		}
+	}
+	h = &StaticHandler{
+		config:    c,
+		fs:        fs,
+		gzipCache: newGzipCache(),
+	}
+	return
+}
+
+// staticFile is a file opened from bindata or the file system.
+type staticFile struct {
+	name    string
+	content io.ReadSeeker
+	modTime time.Time
+	isDir   bool
+	size    int64
+	etag    string
+	file    http.File
+}
+
+func (f *staticFile) Close() {
+	if f.file != nil {
+		f.file.Close()
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *StaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	f := h.open(name)
+	if f == nil {
+		h.notFound(w)
+		return
+	}
+	defer f.Close()
+	if f.isDir {
+		if !strings.HasSuffix(r.URL.Path, "/") && name != "" {
+			localRedirect(w, r, path.Base(name)+"/")
+			return
+		}
+		for _, index := range h.config.Index {
+			if idx := h.open(path.Join(name, index)); idx != nil {
+				defer idx.Close()
+				if !idx.isDir {
+					h.serveFile(w, r, idx)
+					return
+				}
+			}
+		}
+		if h.config.ListDir && f.file != nil {
+			h.listDir(w, f)
+			return
+		}
+		h.notFound(w)
+		return
+	}
+	h.serveFile(w, r, f)
+}
+
+func (h *StaticHandler) notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+}
+
+func (h *StaticHandler) serveFile(w http.ResponseWriter, r *http.Request, f *staticFile) {
+	header := w.Header()
+	ctype := mime.TypeByExtension(path.Ext(f.name))
+	if cc := h.cacheControl(f.name); cc != "" {
+		header.Set("Cache-Control", cc)
+	}
+	isGzipExt := h.isGzipExt(f.name)
+	if h.config.Precompressed || isGzipExt {
+		header.Add("Vary", "Accept-Encoding")
+		encodings := acceptedEncodings(r.Header.Get("Accept-Encoding"), []string{EncodingBrotli, EncodingGzip})
+		for _, encoding := range encodings {
+			encoded := h.encoded(f, encoding, isGzipExt)
+			if encoded == nil {
+				continue
+			}
+			defer encoded.Close()
+			if ctype == "" {
+				ctype = "application/octet-stream"
+			}
+			header.Set("Content-Encoding", encoding)
+			f = encoded
+			break
+		}
+	}
+	if ctype != "" {
+		header.Set("Content-Type", ctype)
+	}
+	header.Set("ETag", f.etag)
+	http.ServeContent(w, r, f.name, f.modTime, f.content)
+}
+
+// encoded returns the precompressed sibling of f in encoding, or the gzip
+// result of f if gzipOnTheFly, nil if not found.
+func (h *StaticHandler) encoded(f *staticFile, encoding string, gzipOnTheFly bool) *staticFile {
+	ext := ".gz"
+	if encoding == EncodingBrotli {
+		ext = ".br"
+	}
+	if h.config.Precompressed {
+		if sibling := h.open(f.name + ext); sibling != nil {
+			if !sibling.isDir {
+				sibling.etag = strings.TrimSuffix(sibling.etag, `"`) + "-" + encoding + `"`
+				return sibling
+			}
+			sibling.Close()
+		}
+	}
+	if encoding != EncodingGzip || !gzipOnTheFly || f.size > h.config.GzipMaxSize {
+		return nil
+	}
+	data, ok := h.gzipCache.get(f.name, f.etag)
+	if !ok {
+		buf := &bytes.Buffer{}
+		gz := gzip.NewWriter(buf)
+		if _, err := io.Copy(gz, f.content); err != nil {
+			return nil
+		}
+		gz.Close()
+		data = buf.Bytes()
+		h.gzipCache.set(f.name, f.etag, data, h.config.GzipCacheSize, h.config.GzipCacheEntries)
+	}
+	return &staticFile{
+		name:    f.name,
+		content: bytes.NewReader(data),
+		modTime: f.modTime,
+		etag:    strings.TrimSuffix(f.etag, `"`) + "-" + encoding + `"`,
+	}
+}
+
+// open finds name in bindata, then in the file system, returns nil if not
+// found.
+func (h *StaticHandler) open(name string) *staticFile {
+	if b, ok := bindata[name]; ok {
+		return &staticFile{
+			name:    name,
+			content: bytes.NewReader(b),
+			modTime: bindataModTime,
+			size:    int64(len(b)),
+			etag:    h.contentETag(name, b),
+		}
+	}
+	if name == "" || h.bindataDir(name) {
+		return &staticFile{name: name, isDir: true, file: h.openFile(name)}
+	}
+	file := h.openFile(name)
+	if file == nil {
+		return nil
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil
+	}
+	etag, err := fileETag(file, fi)
+	if err != nil {
+		file.Close()
+		return nil
+	}
+	return &staticFile{
+		name:    name,
+		content: file,
+		modTime: fi.ModTime(),
+		isDir:   fi.IsDir(),
+		size:    fi.Size(),
+		etag:    etag,
+		file:    file,
+	}
+}
+
+// fileETag returns the ETag by the modification time and size of file, or by
+// hash of the content if the modification time is zero, such as the files
+// of embed.FS, a same size change can not be found by the size only.
+func fileETag(file http.File, fi os.FileInfo) (etag string, err error) {
+	if !fi.ModTime().IsZero() || fi.IsDir() {
+		return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()), nil
+	}
+	hash := fnv.New64a()
+	if _, err = io.Copy(hash, file); err != nil {
+		return
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	return fmt.Sprintf(`"%x"`, hash.Sum64()), nil
+}
+
+// gzipCache is a LRU cache of the gzip results of files, keyed by file name,
+// so a modified file replaces its stale entry.
+type gzipCache struct {
+	lock  sync.Mutex
+	list  *list.List
+	items map[string]*list.Element
+	size  int64
+}
+
+type gzipEntry struct {
+	name string
+	etag string
+	data []byte
+}
+
+func newGzipCache() *gzipCache {
+	return &gzipCache{
+		list:  list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// get returns the result of name, ok is false if not found or etag changed.
+func (c *gzipCache) get(name, etag string) (data []byte, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	el, ok := c.items[name]
+	if !ok {
+		return
+	}
+	e := el.Value.(*gzipEntry)
+	if e.etag != etag {
+		c.remove(el)
+		return nil, false
+	}
+	c.list.MoveToFront(el)
+	return e.data, true
+}
+
+// set caches data of name, then evicts the least recently used entries until
+// the total bytes and count are in maxSize and maxEntries.
+func (c *gzipCache) set(name, etag string, data []byte, maxSize int64, maxEntries int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if el, ok := c.items[name]; ok {
+		c.remove(el)
+	}
+	if int64(len(data)) > maxSize || maxEntries <= 0 {
+		return
+	}
+	c.items[name] = c.list.PushFront(&gzipEntry{name: name, etag: etag, data: data})
+	c.size += int64(len(data))
+	for c.size > maxSize || c.list.Len() > maxEntries {
+		c.remove(c.list.Back())
+	}
+}
+
+func (c *gzipCache) remove(el *list.Element) {
+	e := c.list.Remove(el).(*gzipEntry)
+	delete(c.items, e.name)
+	c.size -= int64(len(e.data))
+}
+
+func (h *StaticHandler) openFile(name string) http.File {
+	f, err := h.fs.Open("/" + name)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// bindataDir returns true if name is a directory of files in bindata.
+func (h *StaticHandler) bindataDir(name string) bool {
+	for k := range bindata {
+		if strings.HasPrefix(k, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// contentETag returns the ETag by hash of the content of name in bindata.
+func (h *StaticHandler) contentETag(name string, b []byte) string {
+	key := fmt.Sprintf("%s-%p-%d", name, b, len(b))
+	if v, ok := h.etagCache.Load(key); ok {
+		return v.(string)
+	}
+	hash := fnv.New64a()
+	hash.Write(b)
+	etag := fmt.Sprintf(`"%x"`, hash.Sum64())
+	h.etagCache.Store(key, etag)
+	return etag
+}
+
+func (h *StaticHandler) cacheControl(name string) string {
+	for _, rule := range h.config.CacheRules {
+		s := name
+		if !strings.Contains(rule.Pattern, "/") {
+			s = path.Base(name)
+		}
+		if ok, _ := path.Match(rule.Pattern, s); ok {
+			return rule.CacheControl
+		}
+	}
+	return h.config.CacheControl
+}
+
+func (h *StaticHandler) isGzipExt(name string) bool {
+	ext := path.Ext(name)
+	for _, v := range h.config.GzipExt {
+		if strings.EqualFold(v, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *StaticHandler) listDir(w http.ResponseWriter, f *staticFile) {
+	files, err := f.file.Readdir(-1)
+	if err != nil {
+		http.Error(w, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<pre>\n")
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() {
+			name += "/"
+		}
+		u := url.URL{Path: name}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", u.String(), html.EscapeString(name))
+	}
+	fmt.Fprintf(w, "</pre>\n")
+}
+
+func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
+	if q := r.URL.RawQuery; q != "" {
+		newPath += "?" + q
+	}
+	w.Header().Set("Location", newPath)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
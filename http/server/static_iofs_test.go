@@ -0,0 +1,41 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+//go:build go1.16
+// +build go1.16
+
+package ghttpserver
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticHandler_ZeroModTime(t *testing.T) {
+	assert := assert.New(t)
+	// the files of embed.FS and MapFS have zero modification time.
+	fsys := fstest.MapFS{"a.txt": {Data: []byte("aaa")}}
+	h, err := NewStaticHandler(&StaticConfig{FS: http.FS(fsys)})
+	assert.Nil(err)
+	w := serveStatic(h, "/a.txt")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("aaa", w.Body.String())
+	assert.Equal("", w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(etag)
+
+	w = serveStatic(h, "/a.txt", "If-None-Match", etag)
+	assert.Equal(http.StatusNotModified, w.Code)
+
+	// a change of the same size gets a new ETag.
+	fsys["a.txt"].Data = []byte("bbb")
+	w = serveStatic(h, "/a.txt", "If-None-Match", etag)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("bbb", w.Body.String())
+	assert.NotEqual(etag, w.Header().Get("ETag"))
+}
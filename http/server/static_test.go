@@ -0,0 +1,292 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockStaticDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "static")
+	assert.Nil(t, err)
+	for k, v := range files {
+		file := filepath.Join(dir, k)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(t, ioutil.WriteFile(file, []byte(v), 0644))
+	}
+	return dir
+}
+
+func serveStatic(h http.Handler, uri string, header ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", uri, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestStaticHandler_Conditional(t *testing.T) {
+	assert := assert.New(t)
+	dir := mockStaticDir(t, map[string]string{"a.txt": "hello"})
+	defer os.RemoveAll(dir)
+	c := NewStaticConfig()
+	c.Dir = dir
+	h, _ := NewStaticHandler(c)
+	w := serveStatic(h, "/a.txt")
+	assert.Equal(200, w.Code)
+	assert.Equal("hello", w.Body.String())
+	assert.Equal("public, max-age=86400", w.Header().Get("Cache-Control"))
+	assert.Equal("", w.Header().Get("Expires"))
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	assert.NotEmpty(etag)
+	assert.NotEmpty(lastModified)
+
+	w = serveStatic(h, "/a.txt", "If-None-Match", etag)
+	assert.Equal(http.StatusNotModified, w.Code)
+	assert.Equal("", w.Body.String())
+	w = serveStatic(h, "/a.txt", "If-Modified-Since", lastModified)
+	assert.Equal(http.StatusNotModified, w.Code)
+	w = serveStatic(h, "/a.txt", "If-None-Match", `"foo"`)
+	assert.Equal(200, w.Code)
+
+	w = serveStatic(h, "/none.txt")
+	assert.Equal(404, w.Code)
+	w = serveStatic(h, "/../a.txt")
+	assert.Equal(200, w.Code)
+}
+
+func TestStaticHandler_Range(t *testing.T) {
+	assert := assert.New(t)
+	dir := mockStaticDir(t, map[string]string{"v.mp4": "0123456789"})
+	defer os.RemoveAll(dir)
+	c := NewStaticConfig()
+	c.Dir = dir
+	h, _ := NewStaticHandler(c)
+	w := serveStatic(h, "/v.mp4", "Range", "bytes=2-5")
+	assert.Equal(http.StatusPartialContent, w.Code)
+	assert.Equal("2345", w.Body.String())
+	assert.Equal("bytes 2-5/10", w.Header().Get("Content-Range"))
+	assert.Equal("video/mp4", w.Header().Get("Content-Type"))
+
+	w = serveStatic(h, "/v.mp4", "Range", "bytes=2-5", "If-Range", `"foo"`)
+	assert.Equal(200, w.Code)
+	assert.Equal("0123456789", w.Body.String())
+	w = serveStatic(h, "/v.mp4", "Range", "bytes=20-")
+	assert.Equal(http.StatusRequestedRangeNotSatisfiable, w.Code)
+}
+
+func TestStaticHandler_Precompressed(t *testing.T) {
+	assert := assert.New(t)
+	dir := mockStaticDir(t, map[string]string{
+		"app.js":     "js",
+		"app.js.br":  "js-br",
+		"app.js.gz":  "js-gz",
+		"app.css":    "css",
+		"index.html": "html",
+	})
+	defer os.RemoveAll(dir)
+	c := NewStaticConfig()
+	c.Dir = dir
+	h, _ := NewStaticHandler(c)
+	w := serveStatic(h, "/app.js", "Accept-Encoding", "gzip, br")
+	assert.Equal("br", w.Header().Get("Content-Encoding"))
+	assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	assert.Contains(w.Header().Get("Content-Type"), "javascript")
+	assert.Equal("js-br", w.Body.String())
+	brETag := w.Header().Get("ETag")
+
+	w = serveStatic(h, "/app.js", "Accept-Encoding", "gzip")
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal("js-gz", w.Body.String())
+	assert.NotEqual(brETag, w.Header().Get("ETag"))
+
+	w = serveStatic(h, "/app.js")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal("js", w.Body.String())
+
+	// no sibling, gzip on the fly.
+	w = serveStatic(h, "/app.css", "Accept-Encoding", "br, gzip")
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal("css", decompress(t, "gzip", w.Body))
+
+	w = serveStatic(h, "/index.html", "Accept-Encoding", "gzip")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal("html", w.Body.String())
+
+	c.Precompressed = false
+	c.GzipExt = nil
+	w = serveStatic(h, "/app.js", "Accept-Encoding", "gzip, br")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal("", w.Header().Get("Vary"))
+	assert.Equal("js", w.Body.String())
+}
+
+func TestStaticHandler_Dir(t *testing.T) {
+	assert := assert.New(t)
+	dir := mockStaticDir(t, map[string]string{
+		"docs/index.htm": "index",
+		"img/a.png":      "png",
+		"img/b.png":      "png",
+	})
+	defer os.RemoveAll(dir)
+	c := NewStaticConfig()
+	c.Dir = dir
+	c.Index = []string{"index.html", "index.htm"}
+	h, _ := NewStaticHandler(c)
+	w := serveStatic(h, "/docs/")
+	assert.Equal(200, w.Code)
+	assert.Equal("index", w.Body.String())
+	w = serveStatic(h, "/docs?a=1")
+	assert.Equal(http.StatusMovedPermanently, w.Code)
+	assert.Equal("docs/?a=1", w.Header().Get("Location"))
+
+	w = serveStatic(h, "/img/")
+	assert.Equal(404, w.Code)
+	c.ListDir = true
+	w = serveStatic(h, "/img/")
+	assert.Equal(200, w.Code)
+	assert.Equal("<pre>\n<a href=\"a.png\">a.png</a>\n<a href=\"b.png\">b.png</a>\n</pre>\n", w.Body.String())
+}
+
+func TestStaticHandler_CacheRules(t *testing.T) {
+	assert := assert.New(t)
+	cfg := mockConfig()
+	cfg.Set("static.cachecontrol", "public, max-age=60")
+	cfg.Set("static.cacherules", []interface{}{
+		map[string]interface{}{"pattern": "*.html", "cachecontrol": "no-cache"},
+		map[string]interface{}{"pattern": "assets/*", "cachecontrol": "public, max-age=31536000, immutable"},
+		map[string]interface{}{"pattern": "private.txt", "cachecontrol": ""},
+	})
+	c := NewStaticConfigFromConfig(cfg)
+	assert.Len(c.CacheRules, 3)
+	h, _ := NewStaticHandler(c)
+	for k, v := range map[string]string{
+		"index.html":       "no-cache",
+		"docs/a.html":      "no-cache",
+		"assets/app.js":    "public, max-age=31536000, immutable",
+		"assets/js/app.js": "public, max-age=60",
+		"private.txt":      "",
+		"a.txt":            "public, max-age=60",
+	} {
+		assert.Equal(v, h.cacheControl(k), k)
+	}
+}
+
+func TestHTTPServer_Static(t *testing.T) {
+	assert := assert.New(t)
+	SetBinData(map[string]string{
+		"d.txt": base64.StdEncoding.EncodeToString([]byte("bindata")),
+	})
+	defer func() { bindata = nil }()
+	cfg := mockConfig()
+	cfg.Set("static.dir", "tests")
+	s := mockHTTPServer(cfg)
+	s.initStatic()
+	// bindata is found before dir.
+	w, r := mockRequest("/static/d.txt")
+	s.ServeHTTP(w, r)
+	assert.Equal("bindata", w.Body.String())
+	etag := w.Header().Get("ETag")
+	w, r = mockRequest("/static/d.txt")
+	r.Header.Set("If-None-Match", etag)
+	s.ServeHTTP(w, r)
+	assert.Equal(http.StatusNotModified, w.Code)
+
+	bindata = nil
+	w, r = mockRequest("/static/d.txt")
+	r.Method = http.MethodHead
+	s.ServeHTTP(w, r)
+	assert.Equal(200, w.Code)
+	assert.Equal("1", w.Header().Get("Content-Length"))
+	assert.Equal("", w.Body.String())
+}
+
+func TestHTTPServer_StaticFS(t *testing.T) {
+	assert := assert.New(t)
+	embed := mockStaticDir(t, map[string]string{"a.txt": "embed", "b.txt": "embed"})
+	defer os.RemoveAll(embed)
+	SetFS(http.Dir(embed))
+	defer SetFS(nil)
+	for source, expect := range map[string][]string{
+		"":      {"embed", "embed"},
+		"embed": {"embed", "embed"},
+		// the files of tests on disk are found too.
+		"overlay": {"embed", "embed", "d"},
+		"disk":    {"Not Found", "Not Found", "d"},
+	} {
+		cfg := mockConfig()
+		cfg.Set("static.dir", "tests")
+		cfg.Set("static.source", source)
+		s := mockHTTPServer(cfg)
+		assert.Nil(s.initStatic())
+		for i, name := range []string{"a.txt", "b.txt", "d.txt"}[:len(expect)] {
+			w, r := mockRequest("/static/" + name)
+			s.ServeHTTP(w, r)
+			assert.Equal(expect[i], w.Body.String(), source+name)
+		}
+	}
+	cfg := mockConfig()
+	cfg.Set("static.source", "none")
+	s := mockHTTPServer(cfg)
+	assert.NotNil(s.initStatic())
+}
+
+func TestStaticHandler_GzipCache(t *testing.T) {
+	assert := assert.New(t)
+	dir := mockStaticDir(t, map[string]string{
+		"a.js":   "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"b.js":   "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		"c.js":   "cccccccccccccccccccccccccccccccccccccccccccccccccc",
+		"big.js": string(make([]byte, 100)),
+	})
+	defer os.RemoveAll(dir)
+	c := NewStaticConfig()
+	c.Dir = dir
+	c.GzipMaxSize = 60
+	c.GzipCacheEntries = 2
+	h, _ := NewStaticHandler(c)
+	for _, name := range []string{"a.js", "b.js", "c.js"} {
+		w := serveStatic(h, "/"+name, "Accept-Encoding", "gzip")
+		assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	}
+	// a.js is evicted by the count.
+	assert.Equal(2, h.gzipCache.list.Len())
+	_, ok := h.gzipCache.items["a.js"]
+	assert.False(ok)
+
+	// larger than GzipMaxSize, not compressed.
+	w := serveStatic(h, "/big.js", "Accept-Encoding", "gzip")
+	assert.Equal("", w.Header().Get("Content-Encoding"))
+	assert.Equal(100, w.Body.Len())
+	assert.Equal(2, h.gzipCache.list.Len())
+
+	// the modified file replaces its entry.
+	file := filepath.Join(dir, "c.js")
+	assert.Nil(ioutil.WriteFile(file, []byte("c2"), 0644))
+	assert.Nil(os.Chtimes(file, time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+	w = serveStatic(h, "/c.js", "Accept-Encoding", "gzip")
+	assert.Equal("c2", decompress(t, "gzip", w.Body))
+	assert.Equal(2, h.gzipCache.list.Len())
+
+	// the total bytes.
+	size := h.gzipCache.size
+	c.GzipCacheSize = size - 1
+	serveStatic(h, "/a.js", "Accept-Encoding", "gzip")
+	assert.Equal(1, h.gzipCache.list.Len())
+	assert.True(h.gzipCache.size < size)
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	gcore "github.com/snail007/gmc/core"
+	gtrace "github.com/snail007/gmc/module/trace"
+)
+
+type traceServer interface {
+	AddMiddleware0(m gcore.Middleware)
+	AddMiddleware3(m gcore.Middleware)
+}
+
+// bindTrace starts a span for every request of server if [trace] in app.toml
+// is enabled, the middlewares are added before any others, so the span covers
+// all of them.
+func bindTrace(cfg gcore.Config, server traceServer) {
+	if cfg == nil || !cfg.GetBool("trace.enable") {
+		return
+	}
+	server.AddMiddleware0(gtrace.StartRequest)
+	server.AddMiddleware3(gtrace.EndRequest)
+}
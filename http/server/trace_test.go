@@ -0,0 +1,106 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	gtrace "github.com/snail007/gmc/module/trace"
+	ghttp "github.com/snail007/gmc/util/http"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func mockTracer() (spans func() []gtrace.SpanData, restore func()) {
+	var mu sync.Mutex
+	var all []gtrace.SpanData
+	c := gtrace.NewConfig()
+	c.Exporter = gtrace.ExporterFunc(func(s []gtrace.SpanData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		all = append(all, s...)
+		return nil
+	})
+	old := gtrace.SetDefault(gtrace.NewTracer(c))
+	spans = func() []gtrace.SpanData {
+		gtrace.Flush()
+		mu.Lock()
+		defer mu.Unlock()
+		return all
+	}
+	restore = func() {
+		gtrace.SetDefault(old).Stop()
+	}
+	return
+}
+
+func TestHTTPServer_Trace(t *testing.T) {
+	assert := assert.New(t)
+	spans, restore := mockTracer()
+	defer restore()
+	cfg := mockConfig()
+	cfg.Set("trace.enable", true)
+	s := mockHTTPServer(cfg)
+	s.router.ControllerMethod("/user/:args", new(User), "Ps")
+	w, r := mockRequest("/user/hello")
+	r.Header.Set(gtrace.TraceparentHeader, testTraceparent)
+	s.ServeHTTP(w, r)
+	assert.Equal(http.StatusOK, w.Code)
+	all := spans()
+	if !assert.Len(all, 2) {
+		return
+	}
+	controller, server := all[0], all[1]
+	assert.Equal("User.Ps", controller.Name)
+	assert.Equal(server.SpanID, controller.ParentSpanID)
+	assert.Equal("GET /user/:args", server.Name)
+	assert.Equal(gtrace.KindServer, server.Kind)
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID.String())
+	assert.Equal("00f067aa0ba902b7", server.ParentSpanID.String())
+	assert.Equal(200, server.Attributes["http.status_code"])
+}
+
+func TestAPIServer_Trace(t *testing.T) {
+	assert := assert.New(t)
+	spans, restore := mockTracer()
+	defer restore()
+	var tp string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tp = r.Header.Get(gtrace.TraceparentHeader)
+	}))
+	defer backend.Close()
+	cfg := gcore.Providers.Config("")()
+	cfg.Set("apiserver.listen", "127.0.0.1:")
+	cfg.Set("trace.enable", true)
+	api, err := NewDefaultAPIServer(gcore.Providers.Ctx("")(), cfg)
+	assert.Nil(err)
+	api.API("/call", func(c gcore.Ctx) {
+		_, _, _, err := ghttp.Client.WithContext(c.Request().Context()).Get(backend.URL, 0, nil)
+		if err != nil {
+			c.WriteHeader(http.StatusInternalServerError)
+		}
+		c.Write(gtrace.TraceIDFromContext(c.Request().Context()))
+	})
+	w, r := mockRequest("/call")
+	api.ServeHTTP(w, r)
+	assert.Equal(http.StatusOK, w.Code)
+	all := spans()
+	if !assert.Len(all, 2) {
+		return
+	}
+	client, server := all[0], all[1]
+	assert.Equal(server.TraceID.String(), w.Body.String())
+	assert.Equal(server.SpanID, client.ParentSpanID)
+	assert.False(server.ParentSpanID.IsValid())
+	assert.Equal(gtrace.KindClient, client.Kind)
+	sc := gtrace.SpanContext{TraceID: client.TraceID, SpanID: client.SpanID, Flags: 1}
+	assert.Equal(sc.Traceparent(), tp)
+}
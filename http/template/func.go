@@ -16,11 +16,14 @@ func addFunc(ctx gcore.Ctx) map[string]interface{} {
 	i18n, _ := gcore.Providers.I18n("")(ctx)
 	funcMap := sprig.FuncMap()
 	f2 := map[string]interface{}{
-		"tr":     i18n.TrV,
-		"trs":    i18n.Tr,
-		"string": anyToString,
-		"tohtml": anyToTplHTML,
-		"val":    trimNoValue,
+		"tr":         i18n.TrV,
+		"trs":        i18n.Tr,
+		"string":     anyToString,
+		"tohtml":     anyToTplHTML,
+		"val":        trimNoValue,
+		"csrf_field": csrfField,
+		"csrf_token": csrfToken,
+		"can":        can,
 	}
 	for k, v := range f2 {
 		funcMap[k] = v
@@ -65,3 +68,56 @@ func trimNoValue(m interface{}, key ...string) interface{} {
 	}
 	return ""
 }
+
+// csrfTokenOf returns the csrf token in v, v is the view data contains `CSRF`
+// or the token itself. The view data is . only at the top level
+// of a view, use $ inside range and with, such as `{{csrf_field $}}`, and
+// pass it to partials, such as `{{template "form" $}}`.
+func csrfTokenOf(v interface{}) *gcore.CSRFToken {
+	if m, ok := v.(map[string]interface{}); ok {
+		v = m["CSRF"]
+	}
+	switch t := v.(type) {
+	case *gcore.CSRFToken:
+		return t
+	case gcore.CSRFToken:
+		return &t
+	}
+	return nil
+}
+
+// csrfField outputs the hidden input of csrf token, such as `{{csrf_field .}}`.
+func csrfField(v interface{}) template.HTML {
+	t := csrfTokenOf(v)
+	if t == nil {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(t.FieldName) +
+		`" value="` + template.HTMLEscapeString(t.Token) + `">`)
+}
+
+// csrfToken outputs the csrf token, such as `{{csrf_token .}}`.
+func csrfToken(v interface{}) string {
+	if t := csrfTokenOf(v); t != nil {
+		return t.Token
+	}
+	return ""
+}
+
+// can reports whether the caller of request is granted the permission, v is
+// the view data contains `Can` set by the rbac middleware, or the
+// gcore.PermissionChecker itself, such as `{{if can "post:edit" .}}`. It's
+// false if v is not the view data, so use `{{if can "post:edit" $}}` inside
+// range and with, and pass $ to partials, such as `{{template "nav" $}}`.
+func can(permission string, v interface{}) bool {
+	if m, ok := v.(map[string]interface{}); ok {
+		v = m["Can"]
+	}
+	switch fn := v.(type) {
+	case gcore.PermissionChecker:
+		return fn(permission)
+	case func(string) bool:
+		return fn(permission)
+	}
+	return false
+}
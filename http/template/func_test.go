@@ -1,8 +1,10 @@
 package gtemplate
 
 import (
+	"bytes"
 	gcore "github.com/snail007/gmc/core"
 	assert2 "github.com/stretchr/testify/assert"
+	"html/template"
 	"testing"
 )
 
@@ -44,3 +46,39 @@ func TestVal(t *testing.T) {
 	assert.Nil(err)
 	assert.Empty(output)
 }
+
+func TestCSRFField(t *testing.T) {
+	assert := assert2.New(t)
+	token := &gcore.CSRFToken{Token: "a<b", FieldName: "_csrf", HeaderName: "X-CSRF-Token"}
+	assert.Equal(`<input type="hidden" name="_csrf" value="a&lt;b">`, string(csrfField(map[string]interface{}{"CSRF": token})))
+	assert.Equal(`<input type="hidden" name="_csrf" value="a&lt;b">`, string(csrfField(*token)))
+	assert.Equal("a<b", csrfToken(token))
+	assert.Empty(csrfField(map[string]interface{}{}))
+	assert.Empty(csrfToken(nil))
+}
+
+func TestCan(t *testing.T) {
+	assert := assert2.New(t)
+	checker := gcore.PermissionChecker(func(permission string) bool {
+		return permission == "post:edit"
+	})
+	assert.True(can("post:edit", map[string]interface{}{"Can": checker}))
+	assert.False(can("post:delete", map[string]interface{}{"Can": checker}))
+	assert.True(can("post:edit", checker))
+	assert.True(can("post:edit", func(string) bool { return true }))
+	assert.False(can("post:edit", map[string]interface{}{}))
+	assert.False(can("post:edit", nil))
+}
+
+func TestCan_Range(t *testing.T) {
+	assert := assert2.New(t)
+	checker := gcore.PermissionChecker(func(permission string) bool {
+		return permission == "post:edit"
+	})
+	tpl := template.Must(template.New("").Funcs(map[string]interface{}{"can": can}).Parse(
+		`{{range .Posts}}{{.}}:{{can "post:edit" .}},{{can "post:edit" $}};{{end}}`))
+	buf := &bytes.Buffer{}
+	assert.Nil(tpl.Execute(buf, map[string]interface{}{"Can": checker, "Posts": []string{"a"}}))
+	// . is the item inside range, $ is the view data.
+	assert.Equal("a:false,true;", buf.String())
+}
@@ -7,14 +7,21 @@ package gtemplate
 
 import (
 	gcore "github.com/snail007/gmc/core"
+	gfs "github.com/snail007/gmc/util/fs"
 )
 
 func Init(ctx gcore.Ctx) (tpl gcore.Template, err error) {
 	cfg := ctx.Config()
-	tpl, err = NewTemplate(ctx, cfg.GetString("template.dir"))
+	t, err := NewTemplate(ctx, cfg.GetString("template.dir"))
 	if err != nil {
 		return nil, err
 	}
+	fs, err := gfs.Select(cfg.GetString("template.source"), t.rootDir, templateFS)
+	if err != nil {
+		return nil, err
+	}
+	t.SetFS(fs)
+	tpl = t
 	tpl.Delims(cfg.GetString("template.delimiterleft"),
 		cfg.GetString("template.delimiterright"))
 	tpl.Extension(cfg.GetString("template.ext"))
@@ -10,7 +10,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	gcore "github.com/snail007/gmc/core"
-	"io/ioutil"
+	gfs "github.com/snail007/gmc/util/fs"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,6 +20,8 @@ import (
 
 var (
 	bindata = map[string][]byte{}
+	// templateFS is the embedded view files set by SetFS.
+	templateFS http.FileSystem
 )
 
 func SetBinData(data map[string]string) {
@@ -32,8 +35,16 @@ func SetBinData(data map[string]string) {
 	}
 }
 
+// SetFS sets the embedded view files, such as the result of
+// gfs.FromFS(embedFS, "views"). The `source` of [template] in app.toml
+// selects disk, embed or overlay of them.
+func SetFS(fs http.FileSystem) {
+	templateFS = fs
+}
+
 type Template struct {
 	rootDir string
+	fs      http.FileSystem
 	tpl     *gotemplate.Template
 	parsed  bool
 	ext     string
@@ -83,6 +94,11 @@ func (t *Template) String() string {
 	return t.tpl.DefinedTemplates()
 }
 
+// SetFS sets the file system of view files, it overrides the rootDir.
+func (t *Template) SetFS(fs http.FileSystem) {
+	t.fs = fs
+}
+
 //Extension sets template file extension, default is : .html
 //only files have the extension will be parsed.
 func (t *Template) Extension(ext string) {
@@ -117,8 +133,15 @@ func (t *Template) Parse() (err error) {
 		t.ctx.Logger().Infof("parse views from binary data")
 		err = t.parseFromBinData()
 	} else {
-		t.ctx.Logger().Infof("parse views from disk")
-		err = t.parseFromDisk()
+		fs := t.fs
+		if fs == nil {
+			fs, err = gfs.Select("", t.rootDir, templateFS)
+			if err != nil {
+				return
+			}
+		}
+		t.ctx.Logger().Infof("parse views from file system")
+		err = t.parseFromFS(fs)
 	}
 	if err != nil {
 		return
@@ -144,83 +167,25 @@ func (t *Template) parseFromBinData() (err error) {
 	}
 	return
 }
-func (t *Template) parseFromDisk() (err error) {
-	names := []string{}
-	err = t.tree(t.rootDir, &names)
-	if err != nil {
-		return
-	}
-	var b []byte
-	for _, v := range names {
-		b, err = ioutil.ReadFile(filepath.Join(t.rootDir, v+t.ext))
+func (t *Template) parseFromFS(fs http.FileSystem) (err error) {
+	return gfs.Walk(fs, "/", func(name string, info os.FileInfo) (err error) {
+		if !strings.HasSuffix(name, t.ext) {
+			return
+		}
+		b, err := gfs.ReadFile(fs, "/"+name)
 		if err != nil {
 			return
 		}
-
+		v := strings.TrimSuffix(name, t.ext)
 		// template without extension
 		html := fmt.Sprintf("{{define \"%s\"}}%s{{end}}", v, string(b))
 		_, err = t.tpl.Parse(html)
-
-		// template with extension
-		html = fmt.Sprintf("{{define \"%s\"}}%s{{end}}", v+t.ext, string(b))
-		_, err = t.tpl.Parse(html)
 		if err != nil {
 			return
 		}
-	}
-	return
-}
-func (t *Template) tree(folder string, names *[]string) (err error) {
-	f, err := os.Open(folder)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	finfo, err := f.Stat()
-	if err != nil {
-		return
-	}
-	if !finfo.IsDir() {
-		return
-	}
-	files, err := filepath.Glob(folder + "/*")
-	if err != nil {
+		// template with extension
+		html = fmt.Sprintf("{{define \"%s\"}}%s{{end}}", name, string(b))
+		_, err = t.tpl.Parse(html)
 		return
-	}
-	var file *os.File
-	for _, v := range files {
-		if file != nil {
-			file.Close()
-		}
-		file, err = os.Open(v)
-		if err != nil {
-			return
-		}
-		fileInfo, err := file.Stat()
-		if err != nil {
-			return err
-		}
-		if fileInfo.IsDir() {
-			err = t.tree(v, names)
-			if err != nil {
-				return err
-			}
-		} else {
-			if !strings.HasSuffix(v, t.ext) {
-				continue
-			}
-			v, err = filepath.Abs(v)
-			if err != nil {
-				return err
-			}
-			v0 := strings.Replace(v, "\\", "/", -1)
-			v0 = strings.Replace(v0, t.rootDir+"/", "", -1)
-			v0 = strings.TrimSuffix(v0, t.ext)
-			*names = append(*names, v0)
-		}
-	}
-	if file != nil {
-		file.Close()
-	}
-	return
+	})
 }
@@ -6,7 +6,15 @@
 package gtemplate
 
 import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	gfs "github.com/snail007/gmc/util/fs"
+	assert2 "github.com/stretchr/testify/assert"
 )
 
 func TestParse(t *testing.T) {
@@ -17,3 +25,37 @@ func TestParse(t *testing.T) {
 	//fmt.Println(len(funcsM))
 	//t.Fail()
 }
+
+func TestTemplate_SetFS(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "views")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "user"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "user", "list.html"), []byte("disk {{.head}}"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "user", "list.txt"), []byte("txt"), 0644)
+
+	SetFS(http.Dir("tests/views"))
+	defer SetFS(nil)
+	for source, expect := range map[string]string{
+		gfs.SourceDisk:    "disk a",
+		gfs.SourceEmbed:   "a",
+		gfs.SourceOverlay: "disk a",
+	} {
+		ctx := gcore.Providers.Ctx("")()
+		cfg := gcore.Providers.Config("")()
+		cfg.Set("template.dir", dir)
+		cfg.Set("template.ext", ".html")
+		cfg.Set("template.source", source)
+		ctx.SetConfig(cfg)
+		tpl, err := Init(ctx)
+		assert.Nil(err)
+		assert.Nil(tpl.Parse())
+		b, err := tpl.Execute("user/list", map[string]string{"head": "a"})
+		assert.Nil(err)
+		assert.Equal(expect, string(b), source)
+		// common/head only exists in the embedded views.
+		_, err = tpl.Execute("common/head", nil)
+		assert.Equal(source == gfs.SourceDisk, err != nil, source)
+	}
+}
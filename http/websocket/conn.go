@@ -0,0 +1,461 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Message types defined in RFC 6455, section 11.8.
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// Close codes defined in RFC 6455, section 11.7.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const maxControlPayload = 125
+
+var (
+	// ErrClosed is returned when writing to a closed connection.
+	ErrClosed = errors.New("websocket: use of closed connection")
+)
+
+// CloseError is returned by reading when a close frame is received, or the
+// connection is closed because of a protocol error.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError returns true if err is a *CloseError with one of codes,
+// any *CloseError matches if codes is empty.
+func IsCloseError(err error, codes ...int) bool {
+	e, ok := err.(*CloseError)
+	if !ok {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, c := range codes {
+		if e.Code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a server side websocket connection, it implements gcore.WebSocketConn.
+type Conn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	ctx          gcore.Ctx
+	subprotocol  string
+	readLimit    int64
+	writeTimeout time.Duration
+	pongWait     time.Duration
+	writeMu      sync.Mutex
+	closeMu      sync.Mutex
+	closed       bool
+	closeFns     []func()
+	onPong       func(data []byte)
+	done         chan struct{}
+}
+
+func newConn(c net.Conn, br *bufio.Reader, ctx gcore.Ctx) *Conn {
+	if br == nil {
+		br = bufio.NewReader(c)
+	}
+	return &Conn{
+		conn: c,
+		br:   br,
+		ctx:  ctx,
+		done: make(chan struct{}),
+	}
+}
+
+// Ctx returns the gcore.Ctx of the upgrade request.
+func (c *Conn) Ctx() gcore.Ctx {
+	return c.ctx
+}
+
+// Session loads the session of the upgrade request, it returns nil if the
+// session is disabled or not started by a previous http request.
+func (c *Conn) Session() gcore.Session {
+	if c.ctx == nil || c.ctx.WebServer() == nil || c.ctx.WebServer().SessionStore() == nil {
+		return nil
+	}
+	sid := c.ctx.Cookie(c.ctx.WebServer().Config().GetString("session.cookiename"))
+	if sid == "" {
+		return nil
+	}
+	sess, ok := c.ctx.WebServer().SessionStore().Load(sid)
+	if !ok {
+		return nil
+	}
+	return sess
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// NetConn returns the underlying network connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// SetReadLimit sets the maximum size in bytes of a message, the connection
+// is closed with CloseMessageTooBig if a message exceeds it, 0 means no limit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// OnPong sets the function called when a pong frame is received.
+func (c *Conn) OnPong(fn func(data []byte)) {
+	c.onPong = fn
+}
+
+// OnClose adds a function called once when the connection is closed.
+func (c *Conn) OnClose(fn func()) {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if c.closed {
+		go fn()
+		return
+	}
+	c.closeFns = append(c.closeFns, fn)
+}
+
+// IsClosed returns true if the connection is closed.
+func (c *Conn) IsClosed() bool {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	return c.closed
+}
+
+// ReadMessage reads a complete message, ping and pong frames are handled
+// in place, a *CloseError is returned when the peer closed the connection.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	messageType = -1
+	for {
+		fin, opcode, payload, e := c.readFrame()
+		if e != nil {
+			return -1, nil, c.readFail(e)
+		}
+		if c.pongWait > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		}
+		switch opcode {
+		case PingMessage:
+			if e = c.writeFrame(PongMessage, payload); e != nil {
+				return -1, nil, c.readFail(e)
+			}
+			continue
+		case PongMessage:
+			if c.onPong != nil {
+				c.onPong(payload)
+			}
+			continue
+		case CloseMessage:
+			ce := parseClosePayload(payload)
+			code := ce.Code
+			if code == CloseNoStatusReceived {
+				code = CloseNormalClosure
+			}
+			c.CloseWithCode(code, "")
+			return -1, nil, ce
+		case TextMessage, BinaryMessage:
+			if messageType != -1 {
+				return -1, nil, c.fail(CloseProtocolError, "unexpected new message in fragments")
+			}
+			messageType = opcode
+			data = payload
+		case continuationFrame:
+			if messageType == -1 {
+				return -1, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+			data = append(data, payload...)
+		default:
+			return -1, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+		if c.readLimit > 0 && int64(len(data)) > c.readLimit {
+			return -1, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(data) {
+				return -1, nil, c.fail(CloseInvalidFramePayloadData, "invalid utf8 payload")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// ReadText reads a message as string.
+func (c *Conn) ReadText() (text string, err error) {
+	_, data, err := c.ReadMessage()
+	return string(data), err
+}
+
+// ReadJSON reads a message and decodes it into v.
+func (c *Conn) ReadJSON(v interface{}) (err error) {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage writes a message, messageType is TextMessage or BinaryMessage.
+func (c *Conn) WriteMessage(messageType int, data []byte) (err error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+func (c *Conn) WriteText(text string) (err error) {
+	return c.writeFrame(TextMessage, []byte(text))
+}
+
+func (c *Conn) WriteBinary(data []byte) (err error) {
+	return c.writeFrame(BinaryMessage, data)
+}
+
+// WriteJSON encodes v to json and writes it as a text message.
+func (c *Conn) WriteJSON(v interface{}) (err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	return c.writeFrame(TextMessage, data)
+}
+
+// Ping sends a ping frame, data is at most 125 bytes.
+func (c *Conn) Ping(data []byte) (err error) {
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("websocket: control frame payload too long")
+	}
+	return c.writeFrame(PingMessage, data)
+}
+
+// Close closes the connection with CloseNormalClosure.
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+// CloseWithCode sends a close frame with code and reason, and closes the
+// connection, it's safe to be called more than once.
+func (c *Conn) CloseWithCode(code int, reason string) (err error) {
+	return c.close(true, code, reason)
+}
+
+func (c *Conn) close(sendFrame bool, code int, reason string) (err error) {
+	c.closeMu.Lock()
+	if c.closed {
+		c.closeMu.Unlock()
+		return nil
+	}
+	c.closed = true
+	fns := c.closeFns
+	c.closeFns = nil
+	c.closeMu.Unlock()
+
+	if sendFrame {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > maxControlPayload {
+			payload = payload[:maxControlPayload]
+		}
+		c.writeFrameLocked(CloseMessage, payload)
+	}
+	close(c.done)
+	err = c.conn.Close()
+	for _, fn := range fns {
+		fn()
+	}
+	return
+}
+
+// keepalive pings the peer every interval until the connection closed.
+func (c *Conn) keepalive(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+			if c.Ping(nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Conn) fail(code int, text string) error {
+	c.CloseWithCode(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+// readFail closes the connection on a network error without the close frame.
+func (c *Conn) readFail(err error) error {
+	if _, ok := err.(*CloseError); ok {
+		return err
+	}
+	c.close(false, 0, "")
+	return &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	fin = h[0]&0x80 != 0
+	if h[0]&0x70 != 0 {
+		err = c.fail(CloseProtocolError, "reserved bits are set")
+		return
+	}
+	opcode = int(h[0] & 0x0f)
+	masked := h[1]&0x80 != 0
+	length := int64(h[1] & 0x7f)
+	if !masked {
+		err = c.fail(CloseProtocolError, "client frame is not masked")
+		return
+	}
+	isControl := opcode >= CloseMessage
+	if isControl && (!fin || length > maxControlPayload) {
+		err = c.fail(CloseProtocolError, "invalid control frame")
+		return
+	}
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(b[:]))
+		if length < 0 {
+			err = c.fail(CloseProtocolError, "invalid payload length")
+			return
+		}
+	}
+	if c.readLimit > 0 && length > c.readLimit {
+		err = c.fail(CloseMessageTooBig, "message too big")
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+func (c *Conn) writeFrame(opcode int, payload []byte) (err error) {
+	if c.IsClosed() {
+		return ErrClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *Conn) writeFrameLocked(opcode int, payload []byte) (err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	_, err = c.conn.Write(encodeFrame(opcode, payload))
+	return
+}
+
+// encodeFrame encodes an unmasked final frame, server frames are never masked.
+func encodeFrame(opcode int, payload []byte) []byte {
+	length := len(payload)
+	buf := make([]byte, 0, length+10)
+	buf = append(buf, 0x80|byte(opcode))
+	switch {
+	case length <= 125:
+		buf = append(buf, byte(length))
+	case length <= 0xffff:
+		buf = append(buf, 126, byte(length>>8), byte(length))
+	default:
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(length))
+		buf = append(buf, 127)
+		buf = append(buf, b[:]...)
+	}
+	return append(buf, payload...)
+}
+
+func parseClosePayload(payload []byte) *CloseError {
+	if len(payload) < 2 {
+		return &CloseError{Code: CloseNoStatusReceived}
+	}
+	return &CloseError{
+		Code: int(binary.BigEndian.Uint16(payload)),
+		Text: string(payload[2:]),
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Hub manages groups of connections for broadcasting, a connection leaves
+// all groups automatically when it's closed.
+type Hub struct {
+	mu     sync.RWMutex
+	groups map[string]map[gcore.WebSocketConn]bool
+	joined map[gcore.WebSocketConn]map[string]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		groups: map[string]map[gcore.WebSocketConn]bool{},
+		joined: map[gcore.WebSocketConn]map[string]bool{},
+	}
+}
+
+// Join adds conn to group.
+func (h *Hub) Join(group string, conn gcore.WebSocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.groups[group]; !ok {
+		h.groups[group] = map[gcore.WebSocketConn]bool{}
+	}
+	h.groups[group][conn] = true
+	if _, ok := h.joined[conn]; !ok {
+		h.joined[conn] = map[string]bool{}
+		conn.OnClose(func() { h.LeaveAll(conn) })
+	}
+	h.joined[conn][group] = true
+}
+
+// Leave removes conn from group.
+func (h *Hub) Leave(group string, conn gcore.WebSocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leave(group, conn)
+}
+
+// LeaveAll removes conn from all groups.
+func (h *Hub) LeaveAll(conn gcore.WebSocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for group := range h.joined[conn] {
+		h.leave(group, conn)
+	}
+}
+
+func (h *Hub) leave(group string, conn gcore.WebSocketConn) {
+	if g, ok := h.groups[group]; ok {
+		delete(g, conn)
+		if len(g) == 0 {
+			delete(h.groups, group)
+		}
+	}
+	if j, ok := h.joined[conn]; ok {
+		delete(j, group)
+		if len(j) == 0 {
+			delete(h.joined, conn)
+		}
+	}
+}
+
+// Count returns the count of connections in group.
+func (h *Hub) Count(group string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.groups[group])
+}
+
+// Groups returns the names of all groups.
+func (h *Hub) Groups() (groups []string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for g := range h.groups {
+		groups = append(groups, g)
+	}
+	return
+}
+
+// Conns returns the connections in group.
+func (h *Hub) Conns(group string) (conns []gcore.WebSocketConn) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.groups[group] {
+		conns = append(conns, c)
+	}
+	return
+}
+
+// Broadcast writes a message to all connections in group concurrently,
+// returns the count of connections written successfully. The connections
+// failed to write are closed.
+func (h *Hub) Broadcast(group string, messageType int, data []byte) int {
+	return h.broadcast(h.Conns(group), messageType, data)
+}
+
+// BroadcastAll writes a message to the connections of all groups.
+func (h *Hub) BroadcastAll(messageType int, data []byte) int {
+	h.mu.RLock()
+	conns := make([]gcore.WebSocketConn, 0, len(h.joined))
+	for c := range h.joined {
+		conns = append(conns, c)
+	}
+	h.mu.RUnlock()
+	return h.broadcast(conns, messageType, data)
+}
+
+func (h *Hub) broadcast(conns []gcore.WebSocketConn, messageType int, data []byte) int {
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		cnt int
+	)
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c gcore.WebSocketConn) {
+			defer wg.Done()
+			if err := c.WriteMessage(messageType, data); err != nil {
+				c.CloseWithCode(CloseGoingAway, "")
+				return
+			}
+			mu.Lock()
+			cnt++
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return cnt
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Upgrader upgrades http requests to websocket connections, it implements
+// gcore.WebSocketUpgrader.
+type Upgrader struct {
+	// Subprotocols is the server supported protocols in order of preference.
+	Subprotocols []string
+	// CheckOrigin returns true if the request Origin is acceptable, default
+	// accepts requests without Origin or Origin host equals to request host.
+	CheckOrigin func(r *http.Request) bool
+	// ReadLimit is the maximum size in bytes of a message, default is 1MB.
+	ReadLimit int64
+	// WriteTimeout is the timeout of writing a frame, default is 10 seconds.
+	WriteTimeout time.Duration
+	// PingInterval is the interval of pinging the client, the connection is
+	// closed when nothing is received in two intervals, 0 disables pinging.
+	PingInterval time.Duration
+}
+
+// NewUpgrader returns an Upgrader with default options,
+// pinging every 30 seconds.
+func NewUpgrader() *Upgrader {
+	return &Upgrader{
+		ReadLimit:    1 << 20,
+		WriteTimeout: time.Second * 10,
+		PingInterval: time.Second * 30,
+	}
+}
+
+// Upgrade implements gcore.WebSocketUpgrader.
+func (u *Upgrader) Upgrade(ctx gcore.Ctx) (gcore.WebSocketConn, error) {
+	c, err := u.UpgradeConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UpgradeConn upgrades the request of ctx, and returns the *Conn.
+func (u *Upgrader) UpgradeConn(ctx gcore.Ctx) (c *Conn, err error) {
+	w, r := ctx.Response(), ctx.Request()
+	if r.Method != http.MethodGet {
+		return nil, u.fail(w, http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, u.fail(w, http.StatusBadRequest, "not a websocket handshake")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, u.fail(w, http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if key == "" {
+		return nil, u.fail(w, http.StatusBadRequest, "Sec-WebSocket-Key is missing")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, u.fail(w, http.StatusForbidden, "origin is not allowed")
+	}
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, u.fail(w, http.StatusInternalServerError, "the ResponseWriter doesn't support hijacking")
+	}
+	subprotocol := u.selectSubprotocol(r)
+	netConn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, u.fail(w, http.StatusInternalServerError, err.Error())
+	}
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	resp += "\r\n"
+	// the deadlines of http server may be set on the hijacked connection.
+	netConn.SetDeadline(time.Time{})
+	if u.WriteTimeout > 0 {
+		netConn.SetWriteDeadline(time.Now().Add(u.WriteTimeout))
+	}
+	if _, err = netConn.Write([]byte(resp)); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	c = newConn(netConn, brw.Reader, ctx)
+	c.subprotocol = subprotocol
+	c.readLimit = u.ReadLimit
+	c.writeTimeout = u.WriteTimeout
+	if u.PingInterval > 0 {
+		c.pongWait = u.PingInterval * 2
+		netConn.SetReadDeadline(time.Now().Add(c.pongWait))
+		go c.keepalive(u.PingInterval)
+	}
+	return
+}
+
+func (u *Upgrader) fail(w http.ResponseWriter, status int, reason string) error {
+	http.Error(w, http.StatusText(status), status)
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	if len(u.Subprotocols) == 0 {
+		return ""
+	}
+	for _, p := range u.Subprotocols {
+		for _, v := range headerTokens(r.Header, "Sec-Websocket-Protocol") {
+			if v == p {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerTokens(header http.Header, name string) (tokens []string) {
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, t := range headerTokens(header, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+var defaultUpgrader = NewUpgrader()
+
+// Upgrade upgrades the request of ctx with the default Upgrader.
+func Upgrade(ctx gcore.Ctx) (gcore.WebSocketConn, error) {
+	return defaultUpgrader.Upgrade(ctx)
+}
+
+// Serve upgrades the request of ctx with upgrader, default Upgrader is used
+// if upgrader is nil, then calls handler and closes the connection after the
+// handler returned.
+func Serve(ctx gcore.Ctx, upgrader gcore.WebSocketUpgrader, handler gcore.WebSocketHandler) (err error) {
+	if upgrader == nil {
+		upgrader = defaultUpgrader
+	}
+	conn, err := upgrader.Upgrade(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	handler(ctx, conn)
+	return
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeClientFrame(w io.Writer, fin bool, opcode int, payload []byte) error {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	buf := []byte{b0}
+	switch l := len(payload); {
+	case l <= 125:
+		buf = append(buf, 0x80|byte(l))
+	case l <= 0xffff:
+		buf = append(buf, 0x80|126, byte(l>>8), byte(l))
+	default:
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(l))
+		buf = append(append(buf, 0x80|127), b[:]...)
+	}
+	mask := []byte{1, 2, 3, 4}
+	buf = append(buf, mask...)
+	for i, v := range payload {
+		buf = append(buf, v^mask[i%4])
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func readServerFrame(r io.Reader) (opcode int, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(r, h[:]); err != nil {
+		return
+	}
+	opcode = int(h[0] & 0x0f)
+	l := int(h[1] & 0x7f)
+	if l == 126 {
+		var b [2]byte
+		io.ReadFull(r, b[:])
+		l = int(binary.BigEndian.Uint16(b[:]))
+	} else if l == 127 {
+		var b [8]byte
+		io.ReadFull(r, b[:])
+		l = int(binary.BigEndian.Uint64(b[:]))
+	}
+	payload = make([]byte, l)
+	_, err = io.ReadFull(r, payload)
+	return
+}
+
+func pipeConn() (*Conn, net.Conn) {
+	s, c := net.Pipe()
+	return newConn(s, nil, nil), c
+}
+
+func TestAcceptKey(t *testing.T) {
+	// the example in RFC 6455, section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestConn_ReadWrite(t *testing.T) {
+	assert := assert.New(t)
+	conn, client := pipeConn()
+	defer client.Close()
+	type frame struct {
+		op      int
+		payload []byte
+	}
+	frames := make(chan frame, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			op, p, _ := readServerFrame(client)
+			frames <- frame{op, p}
+		}
+	}()
+	go func() {
+		writeClientFrame(client, false, TextMessage, []byte("hel"))
+		writeClientFrame(client, true, PingMessage, []byte("p"))
+		writeClientFrame(client, true, continuationFrame, []byte("lo"))
+	}()
+	typ, data, err := conn.ReadMessage()
+	assert.Nil(err)
+	assert.Equal(TextMessage, typ)
+	assert.Equal("hello", string(data))
+	f := <-frames
+	assert.Equal(PongMessage, f.op)
+	assert.Equal("p", string(f.payload))
+
+	assert.Nil(conn.WriteBinary(make([]byte, 300)))
+	f = <-frames
+	assert.Equal(BinaryMessage, f.op)
+	assert.Len(f.payload, 300)
+}
+
+func TestConn_ReadLimit(t *testing.T) {
+	assert := assert.New(t)
+	conn, client := pipeConn()
+	defer client.Close()
+	conn.SetReadLimit(4)
+	closed := make(chan bool, 1)
+	conn.OnClose(func() { closed <- true })
+	go writeClientFrame(client, true, BinaryMessage, []byte("12345"))
+	go func() {
+		op, payload, _ := readServerFrame(client)
+		assert.Equal(CloseMessage, op)
+		assert.Equal(CloseMessageTooBig, int(binary.BigEndian.Uint16(payload)))
+	}()
+	_, _, err := conn.ReadMessage()
+	assert.True(IsCloseError(err, CloseMessageTooBig))
+	assert.True(<-closed)
+	assert.True(conn.IsClosed())
+	assert.Equal(ErrClosed, conn.WriteText("x"))
+}
+
+func TestConn_PeerClose(t *testing.T) {
+	assert := assert.New(t)
+	conn, client := pipeConn()
+	defer client.Close()
+	go writeClientFrame(client, true, CloseMessage, []byte{0x03, 0xe9, 'b', 'y', 'e'})
+	echo := make(chan int, 1)
+	go func() {
+		_, payload, _ := readServerFrame(client)
+		echo <- int(binary.BigEndian.Uint16(payload))
+	}()
+	_, _, err := conn.ReadMessage()
+	assert.True(IsCloseError(err, CloseGoingAway))
+	assert.Equal("bye", err.(*CloseError).Text)
+	assert.Equal(CloseGoingAway, <-echo)
+}
+
+func TestConn_InvalidUTF8(t *testing.T) {
+	assert := assert.New(t)
+	conn, client := pipeConn()
+	defer client.Close()
+	go writeClientFrame(client, true, TextMessage, []byte{0xff, 0xfe})
+	go readServerFrame(client)
+	_, _, err := conn.ReadMessage()
+	assert.True(IsCloseError(err, CloseInvalidFramePayloadData))
+}
+
+func TestHub(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub()
+	c1, client1 := pipeConn()
+	c2, client2 := pipeConn()
+	defer client1.Close()
+	defer client2.Close()
+	hub.Join("room", c1)
+	hub.Join("room", c2)
+	hub.Join("other", c2)
+	assert.Equal(2, hub.Count("room"))
+	assert.Len(hub.Groups(), 2)
+
+	got := make(chan string, 2)
+	for _, c := range []net.Conn{client1, client2} {
+		go func(c net.Conn) {
+			_, p, _ := readServerFrame(c)
+			got <- string(p)
+		}(c)
+	}
+	assert.Equal(2, hub.Broadcast("room", TextMessage, []byte("hi")))
+	assert.Equal("hi", <-got)
+	assert.Equal("hi", <-got)
+
+	go readServerFrame(client2)
+	c2.Close()
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(1, hub.Count("room"))
+	assert.Equal(0, hub.Count("other"))
+	hub.Leave("room", c1)
+	assert.Equal(0, hub.Count("room"))
+}
+
+func TestUpgrader_Helpers(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(sameOrigin(&http.Request{Host: "a.com", Header: http.Header{}}))
+	assert.True(sameOrigin(&http.Request{Host: "a.com", Header: http.Header{"Origin": {"https://a.com"}}}))
+	assert.False(sameOrigin(&http.Request{Host: "a.com", Header: http.Header{"Origin": {"https://b.com"}}}))
+
+	u := &Upgrader{Subprotocols: []string{"v2", "v1"}}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "v1, v2")
+	assert.Equal("v2", u.selectSubprotocol(r))
+	assert.True(headerContains(http.Header{"Connection": {"keep-alive, Upgrade"}}, "Connection", "upgrade"))
+}
+
+func TestEncodeFrame(t *testing.T) {
+	assert := assert.New(t)
+	for _, l := range []int{0, 125, 126, 65535, 65536} {
+		f := encodeFrame(BinaryMessage, make([]byte, l))
+		op, p, err := readServerFrame(bufio.NewReader(strings.NewReader(string(f))))
+		assert.Nil(err)
+		assert.Equal(BinaryMessage, op)
+		assert.Len(p, l)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttputil
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchRoute reports whether the request of method and path p matches
+// pattern. pattern is a route path with an optional method prefix, such as
+// `/api/*`, `/static/*filepath` and `POST /webhook/:name`, a segment of it
+// can be a named parameter `:name`, a catch-all parameter `*name` at the
+// end, or a path.Match pattern.
+func MatchRoute(pattern, method, p string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if i := strings.Index(pattern, " "); i > 0 {
+		if !strings.EqualFold(pattern[:i], method) {
+			return false
+		}
+		pattern = strings.TrimSpace(pattern[i+1:])
+	}
+	patterns := strings.Split(pattern, "/")
+	segments := strings.Split(p, "/")
+	for i, v := range patterns {
+		if strings.HasPrefix(v, "*") && i == len(patterns)-1 && len(v) > 1 {
+			return i < len(segments)
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(v, ":") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if ok, _ := path.Match(v, segments[i]); !ok {
+			return false
+		}
+	}
+	return len(patterns) == len(segments)
+}
+
+// MatchPathPrefix reports whether path p is prefix or under it, prefix
+// matches whole segments, so `/v1` matches `/v1` and `/v1/user`, but not
+// `/v10`.
+func MatchPathPrefix(prefix, p string) bool {
+	return p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")
+}
@@ -6,6 +6,9 @@
 package ghttputil
 
 import (
+	"bufio"
+	"fmt"
+	"net"
 	"net/http"
 	"sync"
 )
@@ -56,6 +59,33 @@ func (this *ResponseWriter) Write(b []byte) (n int, err error) {
 	return
 }
 
+// Hijack implements http.Hijacker, it's used by websocket upgrading,
+// the status code is set to 101 after hijacked.
+func (this *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := this.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support hijacking")
+	}
+	c, rw, err := h.Hijack()
+	if err == nil {
+		this.statusCode = http.StatusSwitchingProtocols
+	}
+	return c, rw, err
+}
+
+// Flush implements http.Flusher, it sends any buffered data to the client.
+func (this *ResponseWriter) Flush() {
+	if f, ok := this.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CanFlush returns true if the underlying ResponseWriter is a http.Flusher.
+func (this *ResponseWriter) CanFlush() bool {
+	_, ok := this.ResponseWriter.(http.Flusher)
+	return ok
+}
+
 //WriteCount acquires outgoing bytes count by writer
 func (this *ResponseWriter) WriteCount() int64 {
 	return this.writeByteCnt
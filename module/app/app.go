@@ -9,9 +9,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/snail007/gmc/core"
+	ghealth "github.com/snail007/gmc/module/health"
+	gtrace "github.com/snail007/gmc/module/trace"
 	ghook "github.com/snail007/gmc/util/process/hook"
+	gsystemd "github.com/snail007/gmc/util/process/systemd"
 	"net"
 	"os"
+	"sort"
+	"sync"
+	"time"
 )
 
 type GMCApp struct {
@@ -25,6 +31,8 @@ type GMCApp struct {
 	configFile        string
 	config            gcore.Config
 	ctx               gcore.Ctx
+	stopOnce          sync.Once
+	stopCh            chan struct{}
 }
 
 func (s *GMCApp) Ctx() gcore.Ctx {
@@ -51,6 +59,7 @@ func New() gcore.App {
 		logger:            nil,
 		attachConfig:      map[string]gcore.Config{},
 		attachConfigfiles: map[string]string{},
+		stopCh:            make(chan struct{}),
 	}
 	c := gcore.Providers.Ctx("")()
 	c.SetApp(app)
@@ -109,6 +118,12 @@ func (s *GMCApp) initialize() (err error) {
 		}
 	}
 
+	// initialize tracing
+	if s.config.Sub("trace") != nil {
+		gtrace.Init(s.config, s.logger)
+		s.OnShutdown(gtrace.Stop)
+	}
+
 	// initialize i18n if needed
 	if s.config.Sub("i18n") != nil {
 		var i18n gcore.I18n
@@ -205,12 +220,17 @@ func (s *GMCApp) Run() (err error) {
 	if err != nil {
 		return
 	}
+	// not ready until all services started
+	ghealth.SetReady(false)
 	err = s.run()
 	if err != nil {
 		return
 	}
+	ghealth.SetReady(true)
 	s.reloadSignalMonitor()
 	s.logger.Infof("gmc app started done.")
+	s.sdNotify(gsystemd.Ready)
+	s.sdWatchdog()
 	ghook.RegistShutdown(func() {
 		s.Stop()
 	})
@@ -222,6 +242,11 @@ func (s *GMCApp) Run() (err error) {
 	return
 }
 func (s *GMCApp) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+		ghealth.SetReady(false)
+		s.sdNotify(gsystemd.Stopping)
+	})
 	for _, fn := range s.onShutdown {
 		func() {
 			defer gcore.Providers.Error("")().Recover(func(e interface{}) {
@@ -263,6 +288,10 @@ func (s *GMCApp) run() (err error) {
 	data := os.Getenv("GMC_REALOD_DATA")
 	fdMap := map[int]map[int]bool{}
 	json.Unmarshal([]byte(data), &fdMap)
+	sdListeners, err := gsystemd.Listeners()
+	if err != nil {
+		return
+	}
 	for i, srvI := range s.services {
 		srv := srvI.Service
 		var cfg gcore.Config
@@ -283,7 +312,13 @@ func (s *GMCApp) run() (err error) {
 		if isReload && len(fdMap[i]) > 0 {
 			// fmt.Println(fdMap)
 			listeners := []net.Listener{}
+			// keep the order of listeners, the first one is the main listener.
+			fds := []int{}
 			for k := range fdMap[i] {
+				fds = append(fds, k)
+			}
+			sort.Ints(fds)
+			for _, k := range fds {
 				listener, e := net.FileListener(os.NewFile(uintptr(k)+3, ""))
 				if e != nil {
 					err = fmt.Errorf("reload fail, %s", e)
@@ -292,6 +327,9 @@ func (s *GMCApp) run() (err error) {
 				listeners = append(listeners, listener)
 			}
 			srv.InjectListeners(listeners)
+		} else if ls := sdListeners[srvI.SocketName]; srvI.SocketName != "" && len(ls) > 0 {
+			// systemd socket activation
+			srv.InjectListeners(ls)
 		}
 
 		//init service
@@ -313,6 +351,42 @@ func (s *GMCApp) run() (err error) {
 		if err != nil {
 			return
 		}
+		if hc, ok := srv.(gcore.HealthChecker); ok {
+			ghealth.Register(fmt.Sprintf("service.%d", i), hc)
+		}
 	}
 	return
 }
+
+// sdNotify sends state to systemd, it does nothing if the app is not started
+// by systemd with Type=notify.
+func (s *GMCApp) sdNotify(state string) {
+	if _, err := gsystemd.Notify(state); err != nil {
+		s.logger.Warnf("systemd notify %s fail, error: %s", state, err)
+	}
+}
+
+// sdWatchdog sends watchdog to systemd at half of WatchdogSec= until app
+// stopped, if watchdog is enabled.
+func (s *GMCApp) sdWatchdog() {
+	interval, err := gsystemd.WatchdogInterval()
+	if err != nil {
+		s.logger.Warnf("systemd watchdog fail, error: %s", err)
+		return
+	}
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		t := time.NewTicker(interval / 2)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-t.C:
+				s.sdNotify(gsystemd.Watchdog)
+			}
+		}
+	}()
+}
@@ -10,14 +10,22 @@ package gapp
 import (
 	"encoding/json"
 	gcore "github.com/snail007/gmc/core"
+	ghealth "github.com/snail007/gmc/module/health"
+	gsystemd "github.com/snail007/gmc/util/process/systemd"
 	"net"
 	"os"
 	"os/exec"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 )
 
+// fileListener is implemented by *net.TCPListener and *net.UnixListener.
+type fileListener interface {
+	File() (*os.File, error)
+}
+
 func (s *GMCApp) reloadSignalMonitor() {
 	go func() {
 		// s.logger.Printf("monitor USR2 signal ...")
@@ -30,6 +38,14 @@ func (s *GMCApp) reloadSignalMonitor() {
 }
 
 func (s *GMCApp) reload() {
+	s.sdNotify(gsystemd.Reloading)
+	ghealth.SetReady(false)
+	// back to ready if reload fail, it's not called when reload done, because
+	// of os.Exit.
+	defer func() {
+		ghealth.SetReady(true)
+		s.sdNotify(gsystemd.Ready)
+	}()
 	files := []*os.File{}
 	fdMap := map[int]map[int]bool{}
 	k := 0
@@ -39,11 +55,20 @@ func (s *GMCApp) reload() {
 			fdMap[i] = map[int]bool{}
 		}
 		for _, l := range srv.Listeners() {
-			f, e := l.(*net.TCPListener).File()
+			fl, ok := l.(fileListener)
+			if !ok {
+				s.logger.Warnf("reload fail, unsupported listener %T", l)
+				return
+			}
+			f, e := fl.File()
 			if e != nil {
 				s.logger.Warnf("reload fail, %s", e)
 				return
 			}
+			if ul, ok := l.(*net.UnixListener); ok {
+				// the socket file is in use by the child process.
+				ul.SetUnlinkOnClose(false)
+			}
 			files = append(files, f)
 			fdMap[i][k] = true
 			k++
@@ -52,8 +77,7 @@ func (s *GMCApp) reload() {
 	// fmt.Println(fdMap, len(files))
 	data, _ := json.Marshal(fdMap)
 	cmd := exec.Cmd{}
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "GMC_REALOD=yes", "GMC_REALOD_DATA="+string(data))
+	cmd.Env = reloadEnv(os.Environ(), string(data))
 	if len(os.Args) > 1 {
 		cmd.Args = os.Args[1:]
 	}
@@ -67,6 +91,9 @@ func (s *GMCApp) reload() {
 		s.logger.Warnf("reload fail, fork error : %s", err)
 		return
 	}
+	// the child process becomes the main process, it sends READY=1 when
+	// started, that requires NotifyAccess=all of the systemd service unit.
+	s.sdNotify(gsystemd.MainPID(cmd.Process.Pid))
 
 	g := sync.WaitGroup{}
 	g.Add(len(s.services))
@@ -81,3 +108,17 @@ func (s *GMCApp) reload() {
 	os.Exit(0)
 	return
 }
+
+// reloadEnv returns the environment of the child process of reload.
+// WATCHDOG_PID is removed, it's the pid of current process, the child process
+// becomes the main process, so it must send the watchdog itself.
+func reloadEnv(environ []string, data string) (env []string) {
+	for _, v := range environ {
+		switch strings.SplitN(v, "=", 2)[0] {
+		case "WATCHDOG_PID", "GMC_REALOD", "GMC_REALOD_DATA":
+			continue
+		}
+		env = append(env, v)
+	}
+	return append(env, "GMC_REALOD=yes", "GMC_REALOD_DATA="+data)
+}
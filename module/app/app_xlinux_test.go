@@ -0,0 +1,33 @@
+// +build !windows
+
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gapp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_reloadEnv(t *testing.T) {
+	assert := assert.New(t)
+	env := reloadEnv([]string{
+		"PATH=/bin",
+		"WATCHDOG_USEC=30000000",
+		"WATCHDOG_PID=123",
+		"NOTIFY_SOCKET=/run/systemd/notify",
+		"GMC_REALOD=yes",
+		"GMC_REALOD_DATA={}",
+	}, `{"0":{"0":true}}`)
+	assert.Equal([]string{
+		"PATH=/bin",
+		"WATCHDOG_USEC=30000000",
+		"NOTIFY_SOCKET=/run/systemd/notify",
+		"GMC_REALOD=yes",
+		`GMC_REALOD_DATA={"0":{"0":true}}`,
+	}, env)
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// CacheInfo is the stats of one configured cache.
+type CacheInfo struct {
+	ID        string  `json:"id"`
+	Type      string  `json:"type"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Sets      int64   `json:"sets"`
+	Dels      int64   `json:"dels"`
+	Evictions int64   `json:"evictions"`
+	Items     int64   `json:"items"`
+	Size      int64   `json:"size"`
+	HitRatio  float64 `json:"hit_ratio"`
+	// Stats is false if the cache does not implement gcore.CacheStatsReader.
+	Stats bool `json:"stats"`
+}
+
+// AllStats returns the stats of all caches, sorted by type and id.
+func AllStats() (infos []CacheInfo) {
+	groups := []struct {
+		typ   string
+		group map[string]gcore.Cache
+	}{
+		{"file", groupFile},
+		{"memory", groupMemory},
+		{"redis", groupRedis},
+		{"user", myCache},
+	}
+	for _, g := range groups {
+		ids := []string{}
+		for id := range g.group {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			info := CacheInfo{ID: id, Type: g.typ, Items: -1, Size: -1}
+			if r, ok := g.group[id].(gcore.CacheStatsReader); ok {
+				st := r.Stats()
+				info.Hits, info.Misses, info.Sets, info.Dels = st.Hits, st.Misses, st.Sets, st.Dels
+				info.Evictions, info.Items, info.Size = st.Evictions, st.Items, st.Size
+				info.HitRatio = st.HitRatio()
+				info.Stats = true
+			}
+			infos = append(infos, info)
+		}
+	}
+	return
+}
+
+// BindRouter binds a handler to `r` which outputs the stats of all caches
+// in JSON, `path` default is /debug/cache.
+func BindRouter(r gcore.HTTPRouter, path string) {
+	if path == "" {
+		path = "/debug/cache"
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	r.HandlerFunc(http.MethodGet, path, statsHandler)
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	infos := AllStats()
+	if infos == nil {
+		infos = []CacheInfo{}
+	}
+	b, err := json.MarshalIndent(map[string]interface{}{
+		"default": defaultCache,
+		"caches":  infos,
+	}, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
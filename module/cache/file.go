@@ -25,15 +25,23 @@ var (
 	folder = ".gmcfilecache"
 )
 
+const tmpFilePrefix = ".tmp-"
+
 type FileCacheConfig struct {
 	Dir             string
 	CleanupInterval time.Duration
+	// ShardLevel is the depth of hashed sub directories, each level has 16 directories.
+	ShardLevel int
+	// MaxSize is the quota of the cache in bytes, the least recently used items
+	// are evicted when it is exceeded, 0 means no limit.
+	MaxSize int64
 }
 
 func NewFileCacheConfig() *FileCacheConfig {
 	return &FileCacheConfig{
 		CleanupInterval: time.Second * 30,
 		Dir:             os.TempDir(),
+		ShardLevel:      2,
 	}
 }
 
@@ -49,17 +57,27 @@ func (item *Item) hasExpired() bool {
 		(time.Now().Unix()-item.Created) >= item.TTL
 }
 
+func (item *Item) expire() int64 {
+	if item.TTL > 0 {
+		return item.Created + item.TTL
+	}
+	return 0
+}
+
 // FileCache represents a file cache adapter implementation.
 type FileCache struct {
 	gcore.Cache
-	cfg *FileCacheConfig
+	cfg     *FileCacheConfig
+	index   *fileIndex
+	counter counters
 }
 
 // NewFileCache creates and returns a new file cache.
 func NewFileCache(cfg interface{}) (cache *FileCache, err error) {
 	cfg0 := cfg.(*FileCacheConfig)
 	c := &FileCache{
-		cfg: cfg0,
+		cfg:   cfg0,
+		index: newFileIndex(),
 	}
 	c.cfg.Dir = strings.Replace(c.cfg.Dir, "{tmp}", os.TempDir(), 1)
 	if c.cfg.Dir == "" {
@@ -74,6 +92,10 @@ func NewFileCache(cfg interface{}) (cache *FileCache, err error) {
 	if err != nil {
 		return
 	}
+	err = c.loadIndex()
+	if err != nil {
+		return
+	}
 	go c.startGC()
 	cache = c
 	return
@@ -82,11 +104,17 @@ func NewFileCache(cfg interface{}) (cache *FileCache, err error) {
 func (c *FileCache) filepath(key string) string {
 	m := md5.Sum([]byte(key))
 	hash := hex.EncodeToString(m[:])
-	return filepath.Join(c.cfg.Dir, string(hash[0]), string(hash[1]), hash)
+	paths := []string{c.cfg.Dir}
+	for i := 0; i < c.cfg.ShardLevel && i < len(hash); i++ {
+		paths = append(paths, string(hash[i]))
+	}
+	return filepath.Join(append(paths, hash)...)
 }
 
 // Put puts value into cache with key and expire time.
 // If expired is 0, it will be deleted by next GC operation.
+// The value is written to a temporary file and renamed to the cache file,
+// so a crash never leaves a partly written item.
 func (c *FileCache) Set(key string, val string, ttl time.Duration) error {
 	filename := c.filepath(key)
 	item := &Item{val, time.Now().Unix(), int64(ttl / time.Second)}
@@ -94,9 +122,41 @@ func (c *FileCache) Set(key string, val string, ttl time.Duration) error {
 	if err != nil {
 		return err
 	}
+	err = writeFileAtomic(filename, data)
+	if err != nil {
+		return err
+	}
+	c.counter.set(1)
+	c.index.put(filename, int64(len(data)), item.expire())
+	c.evict()
+	return nil
+}
 
-	os.MkdirAll(filepath.Dir(filename), 0700)
-	return ioutil.WriteFile(filename, data, 0700)
+func writeFileAtomic(filename string, data []byte) (err error) {
+	dir := filepath.Dir(filename)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(dir, tmpFilePrefix+"*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	return os.Rename(f.Name(), filename)
 }
 
 func (c *FileCache) read(key string) (*Item, error) {
@@ -107,11 +167,16 @@ func (c *FileCache) read(key string) (*Item, error) {
 	}
 
 	item := new(Item)
-	return item, decodeGob(data, item)
+	err = decodeGob(data, item)
+	if err == nil {
+		c.index.touch(filename)
+	}
+	return item, err
 }
 
 // Get gets cached value by given key.
 func (c *FileCache) Get(key string) (val string, err error) {
+	defer func() { c.counter.hit(err) }()
 	item, err := c.read(key)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -121,7 +186,7 @@ func (c *FileCache) Get(key string) (val string, err error) {
 	}
 
 	if item.hasExpired() {
-		os.Remove(c.filepath(key))
+		c.remove(c.filepath(key))
 		err = ErrKeyNotExists
 		return
 	}
@@ -130,11 +195,18 @@ func (c *FileCache) Get(key string) (val string, err error) {
 
 // Delete deletes cached value by given key.
 func (c *FileCache) Del(key string) error {
-	return os.Remove(c.filepath(key))
+	c.counter.del(1)
+	return c.remove(c.filepath(key))
+}
+
+func (c *FileCache) remove(filename string) error {
+	c.index.remove(filename)
+	return os.Remove(filename)
 }
 
 func (c *FileCache) String() string {
-	return fmt.Sprintf("gmc file cache, gc: %ds, dir: %s", c.cfg.CleanupInterval/time.Second, c.cfg.Dir)
+	return fmt.Sprintf("gmc file cache, gc: %ds, dir: %s, size: %d/%d", c.cfg.CleanupInterval/time.Second,
+		c.cfg.Dir, c.index.totalSize(), c.cfg.MaxSize)
 }
 
 // Incr increases cached int-type value by given key as a counter.
@@ -200,6 +272,7 @@ func (c *FileCache) Has(key string) (bool, error) {
 
 // Flush deletes all cached data.
 func (c *FileCache) Clear() error {
+	c.index.reset()
 	return os.RemoveAll(c.cfg.Dir)
 }
 
@@ -235,42 +308,78 @@ func (c *FileCache) DelMulti(keys []string) (err error) {
 	return nil
 }
 
-func (c *FileCache) startGC() {
-	if c.cfg.CleanupInterval < 1 {
-		return
-	}
-
-	if err := filepath.Walk(c.cfg.Dir, func(path string, fi os.FileInfo, err error) error {
+// loadIndex scans the cache directory once on startup, it removes temporary
+// files left by a crash, unreadable items and expired items.
+func (c *FileCache) loadIndex() error {
+	defer c.index.sort()
+	return filepath.Walk(c.cfg.Dir, func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
 			return fmt.Errorf("Walk: %v", err)
 		}
-
 		if fi.IsDir() {
 			return nil
 		}
-
+		if strings.HasPrefix(fi.Name(), tmpFilePrefix) {
+			os.Remove(path)
+			return nil
+		}
 		data, err := ioutil.ReadFile(path)
-		if err != nil && !os.IsNotExist(err) {
-			fmt.Errorf("ReadFile: %v", err)
+		if err != nil {
+			return nil
 		}
-
 		item := new(Item)
-		if err = decodeGob(data, item); err != nil {
-			return err
-		}
-		if item.hasExpired() {
-			if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("Remove: %v", err)
-			}
+		if err = decodeGob(data, item); err != nil || item.hasExpired() {
+			os.Remove(path)
+			return nil
 		}
+		c.index.load(path, fi.Size(), item.expire(), fi.ModTime())
 		return nil
-	}); err != nil {
-		log.Printf("error gc cache files: %v", err)
-	}
+	})
+}
 
+// startGC removes expired items found in the index, it never touches the
+// items not expired, so a GC cycle costs no disk io when nothing expires.
+func (c *FileCache) startGC() {
+	if c.cfg.CleanupInterval < 1 {
+		return
+	}
+	paths := c.index.expired(time.Now().Unix())
+	for _, path := range paths {
+		if err := c.remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("error gc cache files: %v", err)
+		}
+	}
+	c.counter.evict(len(paths))
+	c.evict()
 	time.AfterFunc(c.cfg.CleanupInterval, func() { c.startGC() })
 }
 
+// evict removes the least recently used items until the size of cache
+// is not greater than MaxSize.
+func (c *FileCache) evict() {
+	if c.cfg.MaxSize <= 0 {
+		return
+	}
+	paths := c.index.overflow(c.cfg.MaxSize)
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("error evict cache files: %v", err)
+		}
+	}
+	c.counter.evict(len(paths))
+}
+
+// Stats returns the counters of the cache, Size is the disk usage of all items.
+func (c *FileCache) Stats() gcore.CacheStats {
+	st := c.counter.stats()
+	st.Items = int64(c.index.count())
+	st.Size = c.index.totalSize()
+	return st
+}
+
 func encodeGob(item *Item) ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
 	err := gob.NewEncoder(buf).Encode(item)
@@ -0,0 +1,142 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+type fileEntry struct {
+	path   string
+	size   int64
+	expire int64
+	atime  int64
+}
+
+// fileIndex keeps all items of a FileCache in memory, ordered by access time,
+// the front of lru is the most recently used item.
+type fileIndex struct {
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	size    int64
+}
+
+func newFileIndex() *fileIndex {
+	return &fileIndex{
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (s *fileIndex) put(path string, size, expire int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[path]; ok {
+		e := el.Value.(*fileEntry)
+		s.size += size - e.size
+		e.size, e.expire, e.atime = size, expire, time.Now().UnixNano()
+		s.lru.MoveToFront(el)
+		return
+	}
+	s.entries[path] = s.lru.PushFront(&fileEntry{path: path, size: size, expire: expire, atime: time.Now().UnixNano()})
+	s.size += size
+}
+
+// load adds an item found on disk, sort must be called after all items loaded.
+func (s *fileIndex) load(path string, size, expire int64, atime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[path]; ok {
+		return
+	}
+	s.entries[path] = s.lru.PushBack(&fileEntry{path: path, size: size, expire: expire, atime: atime.UnixNano()})
+	s.size += size
+}
+
+func (s *fileIndex) sort() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	arr := make([]*fileEntry, 0, s.lru.Len())
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		arr = append(arr, el.Value.(*fileEntry))
+	}
+	sort.Slice(arr, func(i, j int) bool { return arr[i].atime > arr[j].atime })
+	s.lru.Init()
+	for _, e := range arr {
+		s.entries[e.path] = s.lru.PushBack(e)
+	}
+}
+
+func (s *fileIndex) touch(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[path]; ok {
+		el.Value.(*fileEntry).atime = time.Now().UnixNano()
+		s.lru.MoveToFront(el)
+	}
+}
+
+func (s *fileIndex) remove(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[path]; ok {
+		s.size -= el.Value.(*fileEntry).size
+		s.lru.Remove(el)
+		delete(s.entries, path)
+	}
+}
+
+func (s *fileIndex) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lru.Init()
+	s.entries = map[string]*list.Element{}
+	s.size = 0
+}
+
+func (s *fileIndex) totalSize() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func (s *fileIndex) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// expired returns the paths of items expired at `now` in unix seconds.
+func (s *fileIndex) expired(now int64) (paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path, el := range s.entries {
+		if e := el.Value.(*fileEntry).expire; e > 0 && now >= e {
+			paths = append(paths, path)
+		}
+	}
+	return
+}
+
+// overflow removes the least recently used items from the index until the
+// total size is not greater than max, and returns the paths of them.
+func (s *fileIndex) overflow(max int64) (paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.size > max && s.lru.Len() > 0 {
+		el := s.lru.Back()
+		e := el.Value.(*fileEntry)
+		s.size -= e.size
+		s.lru.Remove(el)
+		delete(s.entries, e.path)
+		paths = append(paths, e.path)
+	}
+	return
+}
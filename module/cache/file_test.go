@@ -8,6 +8,9 @@ package gcache
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -118,3 +121,80 @@ func Test_FileMulti(t *testing.T) {
 	assert.False(ok)
 
 }
+
+func TestFileCache_MaxSize(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewFileCacheConfig()
+	cfg.Dir, _ = ioutil.TempDir("", "gmc")
+	defer os.RemoveAll(cfg.Dir)
+	c, err := NewFileCache(cfg)
+	assert.Nil(err)
+	assert.Nil(c.Set("a", "aaa", time.Minute))
+	size := c.index.totalSize()
+	c.cfg.MaxSize = size * 2
+	assert.Nil(c.Set("b", "bbb", time.Minute))
+	_, err = c.Get("a")
+	assert.Nil(err)
+	assert.Nil(c.Set("c", "ccc", time.Minute))
+	// b is the least recently used
+	_, err = c.Get("b")
+	assert.True(isNotExits(err))
+	_, err = c.Get("a")
+	assert.Nil(err)
+	_, err = c.Get("c")
+	assert.Nil(err)
+	assert.Equal(size*2, c.index.totalSize())
+}
+
+func TestFileCache_ShardLevel(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewFileCacheConfig()
+	cfg.Dir, _ = ioutil.TempDir("", "gmc")
+	defer os.RemoveAll(cfg.Dir)
+	cfg.ShardLevel = 0
+	c, err := NewFileCache(cfg)
+	assert.Nil(err)
+	assert.Equal(c.cfg.Dir, filepath.Dir(c.filepath("a")))
+	c.cfg.ShardLevel = 3
+	assert.Equal(c.cfg.Dir, filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(c.filepath("a"))))))
+}
+
+func TestFileCache_LoadIndex(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc")
+	defer os.RemoveAll(dir)
+	cfg := NewFileCacheConfig()
+	cfg.Dir = dir
+	c, err := NewFileCache(cfg)
+	assert.Nil(err)
+	assert.Nil(c.Set("a", "aaa", 0))
+	assert.Nil(c.Set("b", "bbb", time.Minute))
+	tmp := filepath.Join(filepath.Dir(c.filepath("a")), tmpFilePrefix+"123")
+	assert.Nil(ioutil.WriteFile(tmp, []byte("half"), 0600))
+	os.MkdirAll(filepath.Dir(c.filepath("broken")), 0700)
+	assert.Nil(ioutil.WriteFile(c.filepath("broken"), []byte("broken"), 0600))
+
+	c2, err := NewFileCache(&FileCacheConfig{Dir: dir, ShardLevel: 2})
+	assert.Nil(err)
+	assert.Equal(c.index.totalSize(), c2.index.totalSize())
+	assert.False(Exists(tmp))
+	assert.False(Exists(c.filepath("broken")))
+	v, err := c2.Get("b")
+	assert.Nil(err)
+	assert.Equal("bbb", v)
+}
+
+func TestFileCache_GC(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewFileCacheConfig()
+	cfg.Dir, _ = ioutil.TempDir("", "gmc")
+	defer os.RemoveAll(cfg.Dir)
+	cfg.CleanupInterval = time.Millisecond * 100
+	c, err := NewFileCache(cfg)
+	assert.Nil(err)
+	assert.Nil(c.Set("a", "aaa", time.Second))
+	assert.Nil(c.Set("b", "bbb", 0))
+	time.Sleep(time.Millisecond * 2200)
+	assert.False(Exists(c.filepath("a")))
+	assert.True(Exists(c.filepath("b")))
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// HealthCheck implements gcore.HealthChecker, it pings the redis server.
+func (c *RedisCache) HealthCheck(ctx context.Context) (err error) {
+	c.connect()
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	timeout := time.Second * 5
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	_, err = redis.DoWithTimeout(conn, timeout, "PING")
+	return
+}
+
+// HealthCheck implements gcore.HealthChecker, it checks the cache dir exists.
+func (c *FileCache) HealthCheck(ctx context.Context) (err error) {
+	info, err := os.Stat(c.cfg.Dir)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", c.cfg.Dir)
+	}
+	return
+}
@@ -8,6 +8,10 @@ package gcache
 import (
 	"fmt"
 	gcore "github.com/snail007/gmc/core"
+	ghealth "github.com/snail007/gmc/module/health"
+	gbyte "github.com/snail007/gmc/util/byte"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/snail007/gmc/util/cast"
@@ -58,20 +62,37 @@ func Init(cfg0 gcore.Config) (err error) {
 					Timeout:         time.Duration(gcast.ToInt(vvv["timeout"])) * time.Second,
 				}
 				groupRedis[id] = NewRedisCache(cfg)
+				ghealth.Register("cache.redis."+id, groupRedis[id].(gcore.HealthChecker))
 			} else if k == "memory" {
 				cfg := &MemCacheConfig{
-					CleanupInterval: time.Duration(gcast.ToInt(vvv["cleanupinterval"])) * time.Second,
+					CleanupInterval:  time.Duration(gcast.ToInt(vvv["cleanupinterval"])) * time.Second,
+					SnapshotFile:     strings.Replace(gcast.ToString(vvv["snapshotfile"]), "{tmp}", os.TempDir(), 1),
+					SnapshotInterval: time.Duration(gcast.ToInt(vvv["snapshotinterval"])) * time.Second,
+					SnapshotCompress: gcast.ToBool(vvv["snapshotcompress"]),
 				}
 				groupMemory[id] = NewMemCache(cfg)
 			} else if k == "file" {
 				cfg := &FileCacheConfig{
 					Dir:             gcast.ToString(vvv["dir"]),
 					CleanupInterval: time.Duration(gcast.ToInt(vvv["cleanupinterval"])) * time.Second,
+					ShardLevel:      2,
+				}
+				if v, ok := vvv["shardlevel"]; ok {
+					cfg.ShardLevel = gcast.ToInt(v)
+				}
+				if v := gcast.ToString(vvv["maxsize"]); v != "" {
+					var size uint64
+					size, err = gbyte.StrToSize(v)
+					if err != nil {
+						return
+					}
+					cfg.MaxSize = int64(size)
 				}
 				groupFile[id], err = NewFileCache(cfg)
 				if err != nil {
 					return
 				}
+				ghealth.Register("cache.file."+id, groupFile[id].(gcore.HealthChecker))
 			}
 		}
 	}
@@ -98,7 +119,8 @@ func Redis(id ...string) *RedisCache {
 	if len(groupRedis) == 0 {
 		return nil
 	}
-	return find("redis", id...).(*RedisCache)
+	c, _ := find("redis", id...).(*RedisCache)
+	return c
 }
 
 func AddCacheU(id string, c gcore.Cache) {
@@ -118,7 +140,8 @@ func Memory(id ...string) *MemCache {
 	if len(groupMemory) == 0 {
 		return nil
 	}
-	return find("memory", id...).(*MemCache)
+	c, _ := find("memory", id...).(*MemCache)
+	return c
 }
 
 //File acquires a file cache object associated the id, id default is : `default`
@@ -127,7 +150,8 @@ func File(id ...string) *FileCache {
 	if len(groupFile) == 0 {
 		return nil
 	}
-	return find("file", id...).(*FileCache)
+	c, _ := find("file", id...).(*FileCache)
+	return c
 }
 
 func find(typ string, id ...string) gcore.Cache {
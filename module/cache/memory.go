@@ -49,6 +49,7 @@ type cache struct {
 	mu                sync.RWMutex
 	onEvicted         func(string, interface{})
 	janitor           *janitor
+	expiredCount      int64
 }
 
 // Add an item to the cache, replacing any existing item. If the duration is 0
@@ -944,6 +945,7 @@ func (c *cache) DeleteExpired() {
 	for k, v := range c.items {
 		// "Inlining" of expired
 		if v.Expiration > 0 && now > v.Expiration {
+			c.expiredCount++
 			ov, evicted := c.delete(k)
 			if evicted {
 				evictedMemoryCacheItems = append(evictedMemoryCacheItems, keyAndValue{k, ov})
@@ -956,6 +958,14 @@ func (c *cache) DeleteExpired() {
 	}
 }
 
+// Returns the number of items deleted by DeleteExpired since the cache created.
+func (c *cache) ExpiredCount() int64 {
+	c.mu.RLock()
+	n := c.expiredCount
+	c.mu.RUnlock()
+	return n
+}
+
 // Sets an (optional) function that is called with the key and value when an
 // item is evicted from the cache. (Including when it is deleted manually, but
 // not when it is overwritten.) Set to nil to disable.
@@ -9,17 +9,33 @@ import (
 	"fmt"
 	"github.com/snail007/gmc/core"
 	"github.com/snail007/gmc/util/cast"
+	"os"
+	"sync"
 	"time"
 )
 
 type (
 	MemCache struct {
 		gcore.Cache
-		cfg *MemCacheConfig
-		c   *MemoryCache
+		cfg     *MemCacheConfig
+		c       *MemoryCache
+		counter counters
+		// snapshotTimer is the timer of periodic snapshots, nil if
+		// SnapshotInterval is 0.
+		snapshotTimer *time.Timer
+		snapshotLock  sync.Mutex
+		snapshotStop  bool
 	}
 	MemCacheConfig struct {
 		CleanupInterval time.Duration
+		// SnapshotFile is the file the items saved to and loaded from on
+		// startup, empty means no snapshot.
+		SnapshotFile string
+		// SnapshotInterval is the interval of periodic snapshots, 0 means
+		// snapshots are only saved by SaveSnapshot or on app shutdown.
+		SnapshotInterval time.Duration
+		// SnapshotCompress enables gzip of the snapshot file.
+		SnapshotCompress bool
 	}
 )
 
@@ -36,6 +52,16 @@ func NewMemCache(cfg interface{}) *MemCache {
 	}
 
 	rc.c = NewMemoryCache(NoExpiration, cfg0.CleanupInterval)
+	if cfg0.SnapshotFile != "" {
+		if err := rc.LoadSnapshot(); err != nil && !os.IsNotExist(err) {
+			logf("[warn] load memory cache snapshot %s fail, error: %s", cfg0.SnapshotFile, err)
+		}
+		if cfg0.SnapshotInterval > 0 {
+			rc.snapshotLock.Lock()
+			rc.snapshotTimer = time.AfterFunc(cfg0.SnapshotInterval, rc.startSnapshot)
+			rc.snapshotLock.Unlock()
+		}
+	}
 	return rc
 }
 
@@ -54,15 +80,19 @@ func (s *MemCache) String() string {
 func (s *MemCache) Get(key string) (string, error) {
 	v, b := s.c.Get(key)
 	if b {
+		s.counter.hit(nil)
 		return gcast.ToString(v), nil
 	}
+	s.counter.hit(ErrKeyNotExists)
 	return "", ErrKeyNotExists
 }
 func (s *MemCache) Set(key string, value string, ttl time.Duration) error {
+	s.counter.set(1)
 	s.c.Set(key, value, ttl)
 	return nil
 }
 func (s *MemCache) Del(key string) error {
+	s.counter.del(1)
 	s.c.Delete(key)
 	return nil
 }
@@ -110,14 +140,27 @@ func (s *MemCache) GetMulti(keys []string) (map[string]string, error) {
 	return d, nil
 }
 func (s *MemCache) SetMulti(values map[string]string, ttl time.Duration) (err error) {
+	s.counter.set(len(values))
 	for k, v := range values {
 		s.c.Set(k, v, ttl)
 	}
 	return nil
 }
 func (s *MemCache) DelMulti(keys []string) (err error) {
+	s.counter.del(len(keys))
 	for _, k := range keys {
 		s.c.Delete(k)
 	}
 	return nil
 }
+
+// Stats returns the counters of the cache, Evictions is the count of expired
+// items removed, Items includes the expired ones not removed yet, Size is
+// -1, because counting it walks all the items.
+func (s *MemCache) Stats() gcore.CacheStats {
+	st := s.counter.stats()
+	st.Evictions = s.c.ExpiredCount()
+	st.Items = int64(s.c.ItemCount())
+	st.Size = -1
+	return st
+}
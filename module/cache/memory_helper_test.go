@@ -7,6 +7,9 @@ package gcache
 
 import (
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -116,3 +119,67 @@ func Test_Multi(t *testing.T) {
 	assert.False(ok)
 
 }
+
+func TestMemCache_Snapshot(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc")
+	defer os.RemoveAll(dir)
+	for _, compress := range []bool{false, true} {
+		cfg := NewMemCacheConfig()
+		cfg.SnapshotFile = filepath.Join(dir, "snapshot.gob")
+		cfg.SnapshotCompress = compress
+		c := NewMemCache(cfg)
+		c.Set("a", "aaa", time.Minute)
+		c.Set("b", "bbb", 0)
+		c.Set("c", "ccc", time.Millisecond)
+		time.Sleep(time.Millisecond * 5)
+		assert.Nil(c.SaveSnapshot())
+
+		c2 := NewMemCache(cfg)
+		v, err := c2.Get("a")
+		assert.Nil(err)
+		assert.Equal("aaa", v)
+		v, err = c2.Get("b")
+		assert.Nil(err)
+		assert.Equal("bbb", v)
+		_, err = c2.Get("c")
+		assert.True(isNotExits(err))
+	}
+}
+
+func TestMemCache_SnapshotInterval(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc")
+	defer os.RemoveAll(dir)
+	cfg := NewMemCacheConfig()
+	cfg.SnapshotFile = filepath.Join(dir, "snapshot.gob")
+	cfg.SnapshotInterval = time.Millisecond * 100
+	c := NewMemCache(cfg)
+	c.Set("a", "aaa", time.Minute)
+	time.Sleep(time.Millisecond * 300)
+	assert.True(Exists(cfg.SnapshotFile))
+
+	// no snapshot is saved after stopped.
+	c.StopSnapshot()
+	assert.Nil(os.Remove(cfg.SnapshotFile))
+	time.Sleep(time.Millisecond * 300)
+	assert.False(Exists(cfg.SnapshotFile))
+}
+
+func TestSaveSnapshots(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc")
+	defer os.RemoveAll(dir)
+	cfg := NewMemCacheConfig()
+	cfg.SnapshotFile = filepath.Join(dir, "snapshot.gob")
+	cfg.SnapshotInterval = time.Millisecond * 50
+	c := NewMemCache(cfg)
+	groupMemory["snapshot_test"] = c
+	defer delete(groupMemory, "snapshot_test")
+	c.Set("a", "aaa", time.Minute)
+	SaveSnapshots()
+	assert.True(Exists(cfg.SnapshotFile))
+	assert.Nil(os.Remove(cfg.SnapshotFile))
+	time.Sleep(time.Millisecond * 200)
+	assert.False(Exists(cfg.SnapshotFile))
+}
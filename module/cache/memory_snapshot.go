@@ -0,0 +1,91 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// SaveSnapshot writes all items to SnapshotFile, the file is replaced
+// atomically, so a crash never leaves a broken snapshot.
+func (s *MemCache) SaveSnapshot() (err error) {
+	if s.cfg.SnapshotFile == "" {
+		return
+	}
+	buf := &bytes.Buffer{}
+	if s.cfg.SnapshotCompress {
+		gz := gzip.NewWriter(buf)
+		if err = s.c.Save(gz); err != nil {
+			return
+		}
+		if err = gz.Close(); err != nil {
+			return
+		}
+	} else if err = s.c.Save(buf); err != nil {
+		return
+	}
+	return writeFileAtomic(s.cfg.SnapshotFile, buf.Bytes())
+}
+
+// LoadSnapshot adds the items in SnapshotFile to the cache, a gzipped
+// snapshot is detected automatically.
+func (s *MemCache) LoadSnapshot() (err error) {
+	f, err := os.Open(s.cfg.SnapshotFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, e := br.Peek(2); e == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(br)
+		if err != nil {
+			return
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return s.c.Load(r)
+}
+
+func (s *MemCache) startSnapshot() {
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+	if s.snapshotStop {
+		return
+	}
+	if err := s.SaveSnapshot(); err != nil {
+		logf("[warn] save memory cache snapshot %s fail, error: %s", s.cfg.SnapshotFile, err)
+	}
+	s.snapshotTimer.Reset(s.cfg.SnapshotInterval)
+}
+
+// StopSnapshot stops the periodic snapshots, it waits for the running one
+// done.
+func (s *MemCache) StopSnapshot() {
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+	s.snapshotStop = true
+	if s.snapshotTimer != nil {
+		s.snapshotTimer.Stop()
+	}
+}
+
+// SaveSnapshots stops the periodic snapshots, and saves the snapshots of all
+// memory caches which have a SnapshotFile, it is called on app shutdown.
+func SaveSnapshots() {
+	for _, c := range groupMemory {
+		c.(*MemCache).StopSnapshot()
+		if err := c.(*MemCache).SaveSnapshot(); err != nil {
+			logf("[warn] save memory cache snapshot fail, error: %s", err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"sort"
+
+	gmetrics "github.com/snail007/gmc/module/metrics"
+)
+
+func init() {
+	gmetrics.Register("cache", gmetrics.CollectorFunc(collectMetrics))
+}
+
+// collectMetrics outputs the stats of the caches which implement
+// gcore.CacheStatsReader, labeled with the type and id of the cache.
+func collectMetrics() []gmetrics.Family {
+	hits := gmetrics.NewFamily("gmc_cache_hits_total", "Number of cache hits.", gmetrics.TypeCounter)
+	misses := gmetrics.NewFamily("gmc_cache_misses_total", "Number of cache misses.", gmetrics.TypeCounter)
+	sets := gmetrics.NewFamily("gmc_cache_sets_total", "Number of cache sets.", gmetrics.TypeCounter)
+	dels := gmetrics.NewFamily("gmc_cache_dels_total", "Number of cache deletes.", gmetrics.TypeCounter)
+	evictions := gmetrics.NewFamily("gmc_cache_evictions_total", "Number of cache items removed by expiration or size quota.", gmetrics.TypeCounter)
+	ratio := gmetrics.NewFamily("gmc_cache_hit_ratio", "Ratio of hits to hits and misses.", gmetrics.TypeGauge)
+	items := gmetrics.NewFamily("gmc_cache_items", "Number of cache items, -1 means unknown.", gmetrics.TypeGauge)
+	size := gmetrics.NewFamily("gmc_cache_size_bytes", "Size of cache items in bytes, -1 means unknown.", gmetrics.TypeGauge)
+	for _, info := range AllStats() {
+		if !info.Stats {
+			continue
+		}
+		hits.Add(float64(info.Hits), "type", info.Type, "id", info.ID)
+		misses.Add(float64(info.Misses), "type", info.Type, "id", info.ID)
+		sets.Add(float64(info.Sets), "type", info.Type, "id", info.ID)
+		dels.Add(float64(info.Dels), "type", info.Type, "id", info.ID)
+		evictions.Add(float64(info.Evictions), "type", info.Type, "id", info.ID)
+		ratio.Add(info.HitRatio, "type", info.Type, "id", info.ID)
+		items.Add(float64(info.Items), "type", info.Type, "id", info.ID)
+		size.Add(float64(info.Size), "type", info.Type, "id", info.ID)
+	}
+	keys := gmetrics.NewFamily("gmc_cache_redis_server_keys", "Number of keys in the db of redis server, shared by the clients of it.", gmetrics.TypeGauge)
+	memory := gmetrics.NewFamily("gmc_cache_redis_server_used_memory_bytes", "Used memory of redis server, shared by the clients of it.", gmetrics.TypeGauge)
+	evicted := gmetrics.NewFamily("gmc_cache_redis_server_evicted_keys", "Number of keys evicted by redis server, shared by the clients of it.", gmetrics.TypeGauge)
+	ids := []string{}
+	for id := range groupRedis {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		c, ok := groupRedis[id].(*RedisCache)
+		if !ok {
+			continue
+		}
+		st, err := c.ServerStats()
+		if err != nil {
+			continue
+		}
+		keys.Add(float64(st.Keys), "id", id, "addr", c.cfg.Addr)
+		memory.Add(float64(st.UsedMemory), "id", id, "addr", c.cfg.Addr)
+		evicted.Add(float64(st.EvictedKeys), "id", id, "addr", c.cfg.Addr)
+	}
+	return []gmetrics.Family{*hits, *misses, *sets, *dels, *evictions, *ratio, *items, *size, *keys, *memory, *evicted}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	gcore "github.com/snail007/gmc/core"
 	"github.com/snail007/gmc/util/cast"
+	"strings"
 	"sync"
 	"time"
 
@@ -50,6 +51,7 @@ type RedisCache struct {
 	pool        *redis.Pool
 	connected   bool
 	connectLock *sync.Mutex
+	counter     counters
 }
 
 func (c *RedisCache) Pool() *redis.Pool {
@@ -84,12 +86,16 @@ func (c *RedisCache) connect() {
 func (c *RedisCache) Get(key string) (val string, err error) {
 	c.connect()
 	val, err = redis.String(c.exec("Get", c.key(key)))
+	if err == nil || err == redis.ErrNil {
+		c.counter.hit(err)
+	}
 	return
 }
 
 // Set value by key
 func (c *RedisCache) Set(key string, val string, ttl time.Duration) (err error) {
 	c.connect()
+	c.counter.set(1)
 	_, err = c.exec("SetEx", c.key(key), int64(ttl/time.Second), val)
 	return
 }
@@ -97,6 +103,7 @@ func (c *RedisCache) Set(key string, val string, ttl time.Duration) (err error)
 // Del value by key
 func (c *RedisCache) Del(key string) (err error) {
 	c.connect()
+	c.counter.del(1)
 	_, err = c.exec("Del", c.key(key))
 	return
 }
@@ -161,7 +168,7 @@ func (c *RedisCache) GetMulti(keys []string) (map[string]string, error) {
 		}
 		values[keys[i]] = gcast.ToString(val)
 	}
-
+	c.counter.hitN(len(values), len(keys)-len(values))
 	return values, nil
 }
 
@@ -171,6 +178,7 @@ func (c *RedisCache) SetMulti(values map[string]string, ttl time.Duration) (err
 	conn := c.pool.Get()
 	defer conn.Close()
 
+	c.counter.set(len(values))
 	// open multi
 	conn.Send("Multi")
 	ttlSec := int64(ttl / time.Second)
@@ -195,7 +203,7 @@ func (c *RedisCache) DelMulti(keys []string) (err error) {
 	for _, key := range keys {
 		args = append(args, c.key(key))
 	}
-
+	c.counter.del(len(keys))
 	_, err = conn.Do("Del", args...)
 	return
 }
@@ -218,6 +226,61 @@ func (c *RedisCache) String() string {
 	return fmt.Sprintf("connection info. url: %s, pwd: %s, dbNum: %d", c.cfg.Addr, pwd, c.cfg.DBNum)
 }
 
+// Stats returns the counters of this client, Items and Size are -1, the
+// redis server is shared by clients, its stats are read by ServerStats.
+func (c *RedisCache) Stats() gcore.CacheStats {
+	st := c.counter.stats()
+	st.Items, st.Size = -1, -1
+	return st
+}
+
+// RedisServerStats is the stats of the redis server of a RedisCache, they are
+// the totals of the server, or the selected db, not only of this client.
+type RedisServerStats struct {
+	// Keys is the DBSIZE of the selected db.
+	Keys int64
+	// UsedMemory is the used_memory of INFO memory.
+	UsedMemory int64
+	// EvictedKeys is the evicted_keys of INFO stats.
+	EvictedKeys int64
+}
+
+// ServerStats returns the stats of the redis server.
+func (c *RedisCache) ServerStats() (st RedisServerStats, err error) {
+	c.connect()
+	conn := c.pool.Get()
+	defer conn.Close()
+	if st.Keys, err = redis.Int64(conn.Do("DBSIZE")); err != nil {
+		return
+	}
+	for _, section := range []string{"memory", "stats"} {
+		var info string
+		if info, err = redis.String(conn.Do("INFO", section)); err != nil {
+			return
+		}
+		m := parseRedisInfo(info)
+		if v, ok := m["used_memory"]; ok {
+			st.UsedMemory = gcast.ToInt64(v)
+		}
+		if v, ok := m["evicted_keys"]; ok {
+			st.EvictedKeys = gcast.ToInt64(v)
+		}
+	}
+	return
+}
+
+// parseRedisInfo parses the `key:value` lines of INFO reply.
+func parseRedisInfo(info string) map[string]string {
+	m := map[string]string{}
+	for _, line := range strings.Split(info, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(kv) == 2 && !strings.HasPrefix(kv[0], "#") {
+			m[kv[0]] = kv[1]
+		}
+	}
+	return m
+}
+
 // Key build
 func (c *RedisCache) key(key string) string {
 	if c.cfg.Prefix != "" {
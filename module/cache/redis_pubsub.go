@@ -0,0 +1,215 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisMessage is a message received by a RedisSubscriber.
+// Pattern is empty if the message matched a channel, not a pattern.
+type RedisMessage struct {
+	Pattern string
+	Channel string
+	Data    []byte
+}
+
+// RedisSubscriberConfig is the options of a RedisSubscriber.
+type RedisSubscriberConfig struct {
+	// Channels and Patterns to subscribe, at least one of them is required.
+	Channels []string
+	Patterns []string
+	// Workers is the count of goroutines calling the handler, default is 1,
+	// messages are handled in order only when it is 1.
+	Workers int
+	// QueueSize is the size of the buffer between receiving and handling,
+	// default is 1024, receiving blocks when the buffer is full.
+	QueueSize int
+	// PingInterval is the interval of pinging the server, the connection is
+	// treated as dead when nothing received in two intervals, default is 30 seconds.
+	PingInterval time.Duration
+	// MaxRetryInterval is the maximum interval of reconnecting, the interval
+	// starts from 1 second and doubles on every failure, default is 30 seconds.
+	MaxRetryInterval time.Duration
+}
+
+// RedisSubscriber receives messages of channels and patterns on a dedicated
+// connection, it reconnects and subscribes again automatically.
+type RedisSubscriber struct {
+	cache   *RedisCache
+	cfg     RedisSubscriberConfig
+	handler func(msg RedisMessage)
+	queue   chan RedisMessage
+	conn    redis.PubSubConn
+	connMu  sync.Mutex
+	closed  chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// Publish posts a message to channel, returns the count of clients received it.
+// Channels are not prefixed by RedisCacheConfig.Prefix.
+func (c *RedisCache) Publish(channel string, message interface{}) (receivers int64, err error) {
+	c.connect()
+	conn := c.pool.Get()
+	defer conn.Close()
+	return redis.Int64(conn.Do("PUBLISH", channel, message))
+}
+
+// Subscribe starts a RedisSubscriber, handler is called in the worker goroutines.
+func (c *RedisCache) Subscribe(cfg RedisSubscriberConfig, handler func(msg RedisMessage)) *RedisSubscriber {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = time.Second * 30
+	}
+	if cfg.MaxRetryInterval <= 0 {
+		cfg.MaxRetryInterval = time.Second * 30
+	}
+	c.connect()
+	s := &RedisSubscriber{
+		cache:   c,
+		cfg:     cfg,
+		handler: handler,
+		queue:   make(chan RedisMessage, cfg.QueueSize),
+		closed:  make(chan struct{}),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	go s.receiveLoop()
+	return s
+}
+
+// Close stops receiving, and waits for the handling messages done.
+func (s *RedisSubscriber) Close() {
+	s.once.Do(func() {
+		close(s.closed)
+		s.connMu.Lock()
+		if s.conn.Conn != nil {
+			s.conn.Close()
+		}
+		s.connMu.Unlock()
+	})
+	s.wg.Wait()
+}
+
+func (s *RedisSubscriber) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *RedisSubscriber) work() {
+	defer s.wg.Done()
+	for msg := range s.queue {
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					s.logf("redis subscriber handler panic: %s", e)
+				}
+			}()
+			s.handler(msg)
+		}()
+	}
+}
+
+func (s *RedisSubscriber) receiveLoop() {
+	defer close(s.queue)
+	retry := time.Second
+	for !s.isClosed() {
+		subscribed, err := s.receive()
+		if s.isClosed() {
+			return
+		}
+		if subscribed {
+			retry = time.Second
+		}
+		s.logf("redis subscriber disconnected, reconnect in %s, error: %v", retry, err)
+		select {
+		case <-s.closed:
+			return
+		case <-time.After(retry):
+		}
+		retry *= 2
+		if retry > s.cfg.MaxRetryInterval {
+			retry = s.cfg.MaxRetryInterval
+		}
+	}
+}
+
+// receive subscribes on a new connection and receives until an error occurred.
+func (s *RedisSubscriber) receive() (subscribed bool, err error) {
+	conn, err := s.cache.pool.Dial()
+	if err != nil {
+		return
+	}
+	psc := redis.PubSubConn{Conn: conn}
+	s.connMu.Lock()
+	if s.isClosed() {
+		s.connMu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conn = psc
+	s.connMu.Unlock()
+	defer psc.Close()
+	if len(s.cfg.Channels) > 0 {
+		if err = psc.Subscribe(redis.Args{}.AddFlat(s.cfg.Channels)...); err != nil {
+			return
+		}
+	}
+	if len(s.cfg.Patterns) > 0 {
+		if err = psc.PSubscribe(redis.Args{}.AddFlat(s.cfg.Patterns)...); err != nil {
+			return
+		}
+	}
+	// a reply of the ping is expected in every interval, or the connection is dead.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(s.cfg.PingInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if psc.Ping("") != nil {
+					return
+				}
+			}
+		}
+	}()
+	for {
+		switch v := psc.ReceiveWithTimeout(s.cfg.PingInterval * 2).(type) {
+		case redis.Message:
+			s.queue <- RedisMessage{Pattern: v.Pattern, Channel: v.Channel, Data: v.Data}
+		case redis.Subscription:
+			subscribed = true
+		case redis.Pong:
+		case error:
+			return subscribed, v
+		}
+	}
+}
+
+func (s *RedisSubscriber) logf(format string, v ...interface{}) {
+	if s.cache.cfg.Logger != nil {
+		s.cache.cfg.Logger.Warnf(format, v...)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisStreamMessage is an entry of a redis stream.
+type RedisStreamMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+// RedisPendingMessage is an entry delivered to a consumer but not acknowledged.
+type RedisPendingMessage struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int64
+}
+
+// XAdd appends an entry to stream, maxLen > 0 trims the stream to about
+// maxLen entries. Stream keys are prefixed by RedisCacheConfig.Prefix.
+func (c *RedisCache) XAdd(stream string, maxLen int64, values map[string]interface{}) (id string, err error) {
+	c.connect()
+	conn := c.pool.Get()
+	defer conn.Close()
+	args := redis.Args{c.key(stream)}
+	if maxLen > 0 {
+		args = args.Add("MAXLEN", "~", maxLen)
+	}
+	args = args.Add("*").AddFlat(values)
+	return redis.String(conn.Do("XADD", args...))
+}
+
+// XGroupCreate creates a consumer group of stream, the stream is created if
+// not exists. start is the ID the group starts from, "$" means new entries
+// only, "0" means all entries. An existing group is not an error.
+func (c *RedisCache) XGroupCreate(stream, group, start string) (err error) {
+	c.connect()
+	conn := c.pool.Get()
+	defer conn.Close()
+	_, err = conn.Do("XGROUP", "CREATE", c.key(stream), group, start, "MKSTREAM")
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		err = nil
+	}
+	return
+}
+
+// XReadGroup reads at most count new entries of stream for consumer in group,
+// it blocks at most block if there is no entry, block 0 means not blocking.
+func (c *RedisCache) XReadGroup(stream, group, consumer string, count int64, block time.Duration) (msgs []RedisStreamMessage, err error) {
+	c.connect()
+	conn := c.pool.Get()
+	defer conn.Close()
+	args := redis.Args{"GROUP", group, consumer}
+	if count > 0 {
+		args = args.Add("COUNT", count)
+	}
+	if block > 0 {
+		args = args.Add("BLOCK", int64(block/time.Millisecond))
+	}
+	args = args.Add("STREAMS", c.key(stream), ">")
+	reply, err := conn.Do("XREADGROUP", args...)
+	if err != nil || reply == nil {
+		return
+	}
+	return parseXRead(reply)
+}
+
+// XAck acknowledges entries of stream in group, returns the count acknowledged.
+func (c *RedisCache) XAck(stream, group string, ids ...string) (n int64, err error) {
+	c.connect()
+	conn := c.pool.Get()
+	defer conn.Close()
+	return redis.Int64(conn.Do("XACK", redis.Args{c.key(stream), group}.AddFlat(ids)...))
+}
+
+// XPending lists at most count pending entries of stream in group which
+// are idle for at least minIdle.
+func (c *RedisCache) XPending(stream, group string, minIdle time.Duration, count int64) (msgs []RedisPendingMessage, err error) {
+	c.connect()
+	conn := c.pool.Get()
+	defer conn.Close()
+	reply, err := redis.Values(conn.Do("XPENDING", c.key(stream), group, "-", "+", count))
+	if err != nil {
+		return
+	}
+	all, err := parseXPending(reply)
+	if err != nil {
+		return
+	}
+	for _, m := range all {
+		if m.Idle >= minIdle {
+			msgs = append(msgs, m)
+		}
+	}
+	return
+}
+
+// XClaim changes the owner of pending entries idle for at least minIdle
+// to consumer, and returns the claimed entries.
+func (c *RedisCache) XClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) (msgs []RedisStreamMessage, err error) {
+	if len(ids) == 0 {
+		return
+	}
+	c.connect()
+	conn := c.pool.Get()
+	defer conn.Close()
+	args := redis.Args{c.key(stream), group, consumer, int64(minIdle / time.Millisecond)}.AddFlat(ids)
+	reply, err := redis.Values(conn.Do("XCLAIM", args...))
+	if err != nil {
+		return
+	}
+	return parseXEntries(reply)
+}
+
+// RedisStreamConsumerConfig is the options of a RedisStreamConsumer.
+type RedisStreamConsumerConfig struct {
+	Stream   string
+	Group    string
+	Consumer string
+	// Count is the maximum entries of one read, default is 10.
+	Count int64
+	// Block is the maximum blocking time of one read, default is 5 seconds.
+	Block time.Duration
+	// ClaimIdle > 0 enables claiming the entries of other consumers which
+	// are pending for at least ClaimIdle, they are checked every ClaimIdle.
+	ClaimIdle time.Duration
+}
+
+// RedisStreamConsumer reads entries of a stream in a consumer group, an
+// entry is acknowledged when the handler returns nil, otherwise it keeps
+// pending, and will be claimed later if ClaimIdle is set.
+type RedisStreamConsumer struct {
+	cache   *RedisCache
+	cfg     RedisStreamConsumerConfig
+	handler func(msg RedisStreamMessage) error
+	closed  chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// Consume creates the group if needed and starts a RedisStreamConsumer.
+func (c *RedisCache) Consume(cfg RedisStreamConsumerConfig, handler func(msg RedisStreamMessage) error) (s *RedisStreamConsumer, err error) {
+	if cfg.Count <= 0 {
+		cfg.Count = 10
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = time.Second * 5
+	}
+	if err = c.XGroupCreate(cfg.Stream, cfg.Group, "$"); err != nil {
+		return
+	}
+	s = &RedisStreamConsumer{
+		cache:   c,
+		cfg:     cfg,
+		handler: handler,
+		closed:  make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return
+}
+
+// Close stops reading, and waits for the handling entries done.
+func (s *RedisStreamConsumer) Close() {
+	s.once.Do(func() { close(s.closed) })
+	s.wg.Wait()
+}
+
+func (s *RedisStreamConsumer) loop() {
+	defer s.wg.Done()
+	lastClaim := time.Now()
+	for {
+		select {
+		case <-s.closed:
+			return
+		default:
+		}
+		if s.cfg.ClaimIdle > 0 && time.Since(lastClaim) >= s.cfg.ClaimIdle {
+			lastClaim = time.Now()
+			s.claim()
+		}
+		msgs, err := s.cache.XReadGroup(s.cfg.Stream, s.cfg.Group, s.cfg.Consumer, s.cfg.Count, s.cfg.Block)
+		if err != nil {
+			s.logf("redis stream %s read fail, error: %s", s.cfg.Stream, err)
+			select {
+			case <-s.closed:
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		s.handle(msgs)
+	}
+}
+
+func (s *RedisStreamConsumer) claim() {
+	pending, err := s.cache.XPending(s.cfg.Stream, s.cfg.Group, s.cfg.ClaimIdle, s.cfg.Count)
+	if err != nil || len(pending) == 0 {
+		return
+	}
+	ids := []string{}
+	for _, p := range pending {
+		ids = append(ids, p.ID)
+	}
+	msgs, err := s.cache.XClaim(s.cfg.Stream, s.cfg.Group, s.cfg.Consumer, s.cfg.ClaimIdle, ids...)
+	if err != nil {
+		s.logf("redis stream %s claim fail, error: %s", s.cfg.Stream, err)
+		return
+	}
+	s.handle(msgs)
+}
+
+func (s *RedisStreamConsumer) handle(msgs []RedisStreamMessage) {
+	for _, msg := range msgs {
+		var err error
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					err = fmt.Errorf("%v", e)
+				}
+			}()
+			err = s.handler(msg)
+		}()
+		if err != nil {
+			s.logf("redis stream %s handle %s fail, error: %s", s.cfg.Stream, msg.ID, err)
+			continue
+		}
+		if _, err = s.cache.XAck(s.cfg.Stream, s.cfg.Group, msg.ID); err != nil {
+			s.logf("redis stream %s ack %s fail, error: %s", s.cfg.Stream, msg.ID, err)
+		}
+	}
+}
+
+func (s *RedisStreamConsumer) logf(format string, v ...interface{}) {
+	if s.cache.cfg.Logger != nil {
+		s.cache.cfg.Logger.Warnf(format, v...)
+	}
+}
+
+// parseXRead parses the reply of XREAD and XREADGROUP of one stream,
+// [[stream, [[id, [field, value, ...]], ...]]].
+func parseXRead(reply interface{}) (msgs []RedisStreamMessage, err error) {
+	streams, err := redis.Values(reply, nil)
+	if err != nil {
+		return
+	}
+	for _, st := range streams {
+		kv, e := redis.Values(st, nil)
+		if e != nil || len(kv) != 2 {
+			return nil, fmt.Errorf("unexpected stream reply: %v", st)
+		}
+		entries, e := redis.Values(kv[1], nil)
+		if e != nil {
+			return nil, e
+		}
+		m, e := parseXEntries(entries)
+		if e != nil {
+			return nil, e
+		}
+		msgs = append(msgs, m...)
+	}
+	return
+}
+
+// parseXEntries parses [[id, [field, value, ...]], ...], deleted entries
+// claimed by XCLAIM are nil and skipped.
+func parseXEntries(entries []interface{}) (msgs []RedisStreamMessage, err error) {
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		kv, e := redis.Values(entry, nil)
+		if e != nil || len(kv) != 2 {
+			return nil, fmt.Errorf("unexpected stream entry: %v", entry)
+		}
+		id, e := redis.String(kv[0], nil)
+		if e != nil {
+			return nil, e
+		}
+		msg := RedisStreamMessage{ID: id, Values: map[string]string{}}
+		if kv[1] != nil {
+			if msg.Values, e = redis.StringMap(kv[1], nil); e != nil {
+				return nil, e
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return
+}
+
+// parseXPending parses the reply of XPENDING in extended form,
+// [[id, consumer, idle, deliveries], ...].
+func parseXPending(reply []interface{}) (msgs []RedisPendingMessage, err error) {
+	for _, entry := range reply {
+		v, e := redis.Values(entry, nil)
+		if e != nil || len(v) != 4 {
+			return nil, fmt.Errorf("unexpected pending entry: %v", entry)
+		}
+		m := RedisPendingMessage{}
+		m.ID, _ = redis.String(v[0], nil)
+		m.Consumer, _ = redis.String(v[1], nil)
+		idle, _ := redis.Int64(v[2], nil)
+		m.Idle = time.Duration(idle) * time.Millisecond
+		m.Deliveries, _ = redis.Int64(v[3], nil)
+		msgs = append(msgs, m)
+	}
+	return
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseXRead(t *testing.T) {
+	assert := assert.New(t)
+	reply := []interface{}{
+		[]interface{}{
+			[]byte("mystream"),
+			[]interface{}{
+				[]interface{}{[]byte("1-0"), []interface{}{[]byte("a"), []byte("1"), []byte("b"), []byte("2")}},
+				[]interface{}{[]byte("2-0"), []interface{}{[]byte("a"), []byte("3")}},
+			},
+		},
+	}
+	msgs, err := parseXRead(reply)
+	assert.Nil(err)
+	assert.Len(msgs, 2)
+	assert.Equal("1-0", msgs[0].ID)
+	assert.Equal(map[string]string{"a": "1", "b": "2"}, msgs[0].Values)
+	assert.Equal("3", msgs[1].Values["a"])
+
+	_, err = parseXRead([]interface{}{[]interface{}{[]byte("mystream")}})
+	assert.NotNil(err)
+}
+
+func TestParseXEntries_Deleted(t *testing.T) {
+	assert := assert.New(t)
+	msgs, err := parseXEntries([]interface{}{
+		nil,
+		[]interface{}{[]byte("1-0"), nil},
+	})
+	assert.Nil(err)
+	assert.Len(msgs, 1)
+	assert.Equal("1-0", msgs[0].ID)
+	assert.Empty(msgs[0].Values)
+}
+
+func TestParseXPending(t *testing.T) {
+	assert := assert.New(t)
+	msgs, err := parseXPending([]interface{}{
+		[]interface{}{[]byte("1-0"), []byte("c1"), int64(1500), int64(2)},
+	})
+	assert.Nil(err)
+	assert.Len(msgs, 1)
+	assert.Equal("c1", msgs[0].Consumer)
+	assert.Equal(1500*time.Millisecond, msgs[0].Idle)
+	assert.Equal(int64(2), msgs[0].Deliveries)
+}
@@ -6,6 +6,8 @@
 package gcache
 
 import (
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -121,3 +123,274 @@ func Test_RedisMulti(t *testing.T) {
 	assert.False(ok)
 
 }
+
+func TestRedisCache_Stats(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewRedisCacheConfig()
+	cfg.Addr = "127.0.0.1:6379"
+	rd := NewRedisCache(cfg)
+	st := rd.Stats()
+	assert.Equal(int64(-1), st.Items)
+	assert.Equal(int64(-1), st.Size)
+	assert.Equal(int64(0), st.Evictions)
+
+	assert.Nil(rd.Set("stats", "a", time.Minute))
+	sst, err := rd.ServerStats()
+	assert.Nil(err)
+	assert.True(sst.Keys > 0)
+	assert.True(sst.UsedMemory > 0)
+	assert.Equal(int64(1), rd.Stats().Sets)
+}
+
+func Test_parseRedisInfo(t *testing.T) {
+	assert := assert.New(t)
+	m := parseRedisInfo("# Memory\r\nused_memory:1024\r\nused_memory_human:1.00K\r\n\r\n# Stats\r\nevicted_keys:3\r\n")
+	assert.Equal("1024", m["used_memory"])
+	assert.Equal("3", m["evicted_keys"])
+	assert.Len(m, 3)
+}
+
+func newTestRedisCache() *RedisCache {
+	cfg := NewRedisCacheConfig()
+	cfg.Addr = "127.0.0.1:6379"
+	cfg.Prefix = fmt.Sprintf("test_%d_", time.Now().UnixNano())
+	return NewRedisCache(cfg)
+}
+
+// publishUntil publishes msg until it is received by a subscriber, the
+// subscriber may be not subscribed yet.
+func publishUntil(rd *RedisCache, channel, msg string) bool {
+	for i := 0; i < 50; i++ {
+		n, err := rd.Publish(channel, msg)
+		if err == nil && n > 0 {
+			return true
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return false
+}
+
+func TestRedisCache_PublishSubscribe(t *testing.T) {
+	assert := assert.New(t)
+	rd := newTestRedisCache()
+	ch := rd.cfg.Prefix + "ch"
+	msgs := make(chan RedisMessage, 10)
+	s := rd.Subscribe(RedisSubscriberConfig{
+		Channels: []string{ch},
+		Patterns: []string{rd.cfg.Prefix + "p.*"},
+	}, func(msg RedisMessage) {
+		msgs <- msg
+	})
+	defer s.Close()
+
+	assert.True(publishUntil(rd, ch, "hello"))
+	select {
+	case msg := <-msgs:
+		assert.Equal("", msg.Pattern)
+		assert.Equal(ch, msg.Channel)
+		assert.Equal("hello", string(msg.Data))
+	case <-time.After(time.Second * 3):
+		t.Fatal("message not received")
+	}
+
+	assert.True(publishUntil(rd, rd.cfg.Prefix+"p.1", "world"))
+	select {
+	case msg := <-msgs:
+		assert.Equal(rd.cfg.Prefix+"p.*", msg.Pattern)
+		assert.Equal(rd.cfg.Prefix+"p.1", msg.Channel)
+		assert.Equal("world", string(msg.Data))
+	case <-time.After(time.Second * 3):
+		t.Fatal("pattern message not received")
+	}
+}
+
+func TestRedisSubscriber_Reconnect(t *testing.T) {
+	assert := assert.New(t)
+	rd := newTestRedisCache()
+	ch := rd.cfg.Prefix + "ch"
+	msgs := make(chan RedisMessage, 10)
+	s := rd.Subscribe(RedisSubscriberConfig{
+		Channels:         []string{ch},
+		MaxRetryInterval: time.Second,
+	}, func(msg RedisMessage) {
+		msgs <- msg
+	})
+	defer s.Close()
+	assert.True(publishUntil(rd, ch, "before"))
+	<-msgs
+
+	conn := rd.pool.Get()
+	_, err := conn.Do("CLIENT", "KILL", "TYPE", "pubsub")
+	conn.Close()
+	assert.Nil(err)
+
+	// the subscriber reconnects after the retry interval.
+	assert.True(publishUntil(rd, ch, "after"))
+	select {
+	case msg := <-msgs:
+		assert.Equal("after", string(msg.Data))
+	case <-time.After(time.Second * 3):
+		t.Fatal("message not received after reconnecting")
+	}
+}
+
+func TestRedisSubscriber_Close(t *testing.T) {
+	assert := assert.New(t)
+	rd := newTestRedisCache()
+	ch := rd.cfg.Prefix + "ch"
+	s := rd.Subscribe(RedisSubscriberConfig{Channels: []string{ch}}, func(msg RedisMessage) {})
+	assert.True(publishUntil(rd, ch, "a"))
+
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatal("Close not returned")
+	}
+	// the receiving goroutine closes the queue when it exits.
+	select {
+	case _, ok := <-s.queue:
+		assert.False(ok)
+	case <-time.After(time.Second * 3):
+		t.Fatal("receive loop not stopped")
+	}
+	// the server drops the closed connection asynchronously.
+	var n int64
+	var err error
+	for i := 0; i < 30; i++ {
+		if n, err = rd.Publish(ch, "b"); err != nil || n == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	assert.Nil(err)
+	assert.Equal(int64(0), n)
+}
+
+func TestRedisCache_Stream(t *testing.T) {
+	assert := assert.New(t)
+	rd := newTestRedisCache()
+	assert.Nil(rd.XGroupCreate("st", "g", "$"))
+	// an existing group is not an error.
+	assert.Nil(rd.XGroupCreate("st", "g", "$"))
+
+	id, err := rd.XAdd("st", 100, map[string]interface{}{"k": "v"})
+	assert.Nil(err)
+	assert.NotEmpty(id)
+
+	msgs, err := rd.XReadGroup("st", "g", "c1", 10, time.Second)
+	assert.Nil(err)
+	assert.Len(msgs, 1)
+	assert.Equal(id, msgs[0].ID)
+	assert.Equal("v", msgs[0].Values["k"])
+
+	pending, err := rd.XPending("st", "g", 0, 10)
+	assert.Nil(err)
+	assert.Len(pending, 1)
+	assert.Equal("c1", pending[0].Consumer)
+	assert.Equal(int64(1), pending[0].Deliveries)
+
+	claimed, err := rd.XClaim("st", "g", "c2", 0, id)
+	assert.Nil(err)
+	assert.Len(claimed, 1)
+	pending, _ = rd.XPending("st", "g", 0, 10)
+	assert.Equal("c2", pending[0].Consumer)
+
+	n, err := rd.XAck("st", "g", id)
+	assert.Nil(err)
+	assert.Equal(int64(1), n)
+	pending, _ = rd.XPending("st", "g", 0, 10)
+	assert.Len(pending, 0)
+
+	// nothing new for the group.
+	msgs, err = rd.XReadGroup("st", "g", "c1", 10, time.Millisecond*100)
+	assert.Nil(err)
+	assert.Len(msgs, 0)
+}
+
+func TestRedisCache_Consume(t *testing.T) {
+	assert := assert.New(t)
+	rd := newTestRedisCache()
+	msgs := make(chan RedisStreamMessage, 10)
+	failed := int32(0)
+	s, err := rd.Consume(RedisStreamConsumerConfig{
+		Stream:    "st",
+		Group:     "g",
+		Consumer:  "c1",
+		Block:     time.Millisecond * 100,
+		ClaimIdle: time.Millisecond * 200,
+	}, func(msg RedisStreamMessage) error {
+		// fails the first delivery, it keeps pending and is claimed later.
+		if msg.Values["k"] == "retry" && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			return fmt.Errorf("fail")
+		}
+		msgs <- msg
+		return nil
+	})
+	assert.Nil(err)
+	defer s.Close()
+
+	id1, _ := rd.XAdd("st", 0, map[string]interface{}{"k": "ok"})
+	id2, _ := rd.XAdd("st", 0, map[string]interface{}{"k": "retry"})
+	got := map[string]string{}
+	for len(got) < 2 {
+		select {
+		case msg := <-msgs:
+			got[msg.ID] = msg.Values["k"]
+		case <-time.After(time.Second * 3):
+			t.Fatalf("messages not consumed, got: %v", got)
+		}
+	}
+	assert.Equal("ok", got[id1])
+	assert.Equal("retry", got[id2])
+	assert.Equal(int32(1), atomic.LoadInt32(&failed))
+
+	// both are acknowledged.
+	var pending []RedisPendingMessage
+	for i := 0; i < 30; i++ {
+		pending, err = rd.XPending("st", "g", 0, 10)
+		if err != nil || len(pending) == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	assert.Nil(err)
+	assert.Len(pending, 0)
+}
+
+func TestRedisStreamConsumer_Close(t *testing.T) {
+	assert := assert.New(t)
+	rd := newTestRedisCache()
+	s, err := rd.Consume(RedisStreamConsumerConfig{
+		Stream:   "st",
+		Group:    "g",
+		Consumer: "c1",
+		Block:    time.Millisecond * 100,
+	}, func(msg RedisStreamMessage) error {
+		return nil
+	})
+	assert.Nil(err)
+
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatal("Close not returned")
+	}
+	// nothing reads the stream after Close.
+	id, err := rd.XAdd("st", 0, map[string]interface{}{"k": "v"})
+	assert.Nil(err)
+	time.Sleep(time.Millisecond * 300)
+	msgs, err := rd.XReadGroup("st", "g", "c2", 10, 0)
+	assert.Nil(err)
+	assert.Len(msgs, 1)
+	assert.Equal(id, msgs[0].ID)
+}
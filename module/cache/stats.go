@@ -0,0 +1,58 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"sync/atomic"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// counters is embedded by the caches to count their operations.
+type counters struct {
+	hits      int64
+	misses    int64
+	sets      int64
+	dels      int64
+	evictions int64
+}
+
+func (s *counters) hit(err error) {
+	if err == nil {
+		atomic.AddInt64(&s.hits, 1)
+	} else {
+		atomic.AddInt64(&s.misses, 1)
+	}
+}
+
+func (s *counters) hitN(hits, misses int) {
+	atomic.AddInt64(&s.hits, int64(hits))
+	atomic.AddInt64(&s.misses, int64(misses))
+}
+
+func (s *counters) set(n int) {
+	atomic.AddInt64(&s.sets, int64(n))
+}
+
+func (s *counters) del(n int) {
+	atomic.AddInt64(&s.dels, int64(n))
+}
+
+func (s *counters) evict(n int) {
+	atomic.AddInt64(&s.evictions, int64(n))
+}
+
+func (s *counters) stats() gcore.CacheStats {
+	return gcore.CacheStats{
+		Hits:      atomic.LoadInt64(&s.hits),
+		Misses:    atomic.LoadInt64(&s.misses),
+		Sets:      atomic.LoadInt64(&s.sets),
+		Dels:      atomic.LoadInt64(&s.dels),
+		Evictions: atomic.LoadInt64(&s.evictions),
+		Items:     -1,
+		Size:      -1,
+	}
+}
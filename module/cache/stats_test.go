@@ -0,0 +1,80 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemCache_Stats(t *testing.T) {
+	assert := assert.New(t)
+	c := NewMemCache(NewMemCacheConfig())
+	c.Set("a", "aaa", time.Minute)
+	c.SetMulti(map[string]string{"b": "b", "c": "c"}, time.Minute)
+	c.Get("a")
+	c.Get("x")
+	c.Del("c")
+	st := c.Stats()
+	assert.Equal(int64(1), st.Hits)
+	assert.Equal(int64(1), st.Misses)
+	assert.Equal(int64(3), st.Sets)
+	assert.Equal(int64(1), st.Dels)
+	assert.Equal(int64(2), st.Items)
+	assert.Equal(int64(-1), st.Size)
+	assert.Equal(0.5, st.HitRatio())
+}
+
+func TestFileCache_Stats(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "gmc")
+	defer os.RemoveAll(dir)
+	cfg := NewFileCacheConfig()
+	cfg.Dir = dir
+	c, err := NewFileCache(cfg)
+	assert.Nil(err)
+	c.Set("a", "aaa", time.Minute)
+	c.Get("a")
+	c.Get("x")
+	st := c.Stats()
+	assert.Equal(int64(1), st.Hits)
+	assert.Equal(int64(1), st.Misses)
+	assert.Equal(int64(1), st.Sets)
+	assert.Equal(int64(1), st.Items)
+	assert.True(st.Size > 0)
+}
+
+func TestStatsHandler(t *testing.T) {
+	assert := assert.New(t)
+	c := NewMemCache(NewMemCacheConfig())
+	c.Set("a", "a", time.Minute)
+	AddCacheU("stats_test", c)
+	defer delete(myCache, "stats_test")
+	w := httptest.NewRecorder()
+	statsHandler(w, httptest.NewRequest("GET", "/debug/cache", nil))
+	assert.Equal("application/json", w.Header().Get("Content-Type"))
+	data := struct {
+		Caches []CacheInfo
+	}{}
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &data))
+	var found *CacheInfo
+	for i, v := range data.Caches {
+		if v.Type == "user" && v.ID == "stats_test" {
+			found = &data.Caches[i]
+		}
+	}
+	assert.NotNil(found)
+	assert.True(found.Stats)
+	assert.Equal(int64(1), found.Items)
+	var _ gcore.CacheStatsReader = c
+}
@@ -0,0 +1,414 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"time"
+
+	gcast "github.com/snail007/gmc/util/cast"
+	gvalidator "github.com/snail007/gmc/util/validator"
+)
+
+const (
+	// the struct tags of binding.
+	tagForm    = "form"
+	tagJSON    = "json"
+	tagParam   = "param"
+	tagDefault = "default"
+)
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Bind fills obj from the route params, url query and request body, then
+// validates it, the body is parsed by its Content-Type, JSON, urlencoded form
+// or multipart form. The fields are matched by `param` tag for route params,
+// `json` tag for JSON body, `form` tag for others, the field name is used if
+// there is no tag. The `default` tag sets the value of fields which are not
+// in request. The error is gvalidator.Errors if any value can not be
+// converted or any rule in `validate` tag fails.
+func (this *Ctx) Bind(obj interface{}) (err error) {
+	if err = this.bind(obj, this.bindParams, this.bindQuery); err != nil {
+		return
+	}
+	name := tagForm
+	switch this.contentType() {
+	case "application/json":
+		name = tagJSON
+		err = this.bindJSON(obj)
+	case "application/x-www-form-urlencoded":
+		err = this.bindForm(obj)
+	case "multipart/form-data":
+		err = this.bindMultipart(obj)
+	}
+	if err != nil {
+		return
+	}
+	return validate(obj, name)
+}
+
+// BindJSON fills obj from the JSON request body, then validates it.
+func (this *Ctx) BindJSON(obj interface{}) (err error) {
+	if err = this.bind(obj, this.bindJSON); err != nil {
+		return
+	}
+	return validate(obj, tagJSON)
+}
+
+// BindQuery fills obj from the url query, then validates it.
+func (this *Ctx) BindQuery(obj interface{}) (err error) {
+	if err = this.bind(obj, this.bindQuery); err != nil {
+		return
+	}
+	return validate(obj, tagForm)
+}
+
+// BindForm fills obj from the urlencoded form body, then validates it.
+func (this *Ctx) BindForm(obj interface{}) (err error) {
+	if err = this.bind(obj, this.bindForm); err != nil {
+		return
+	}
+	return validate(obj, tagForm)
+}
+
+// BindMultipart fills obj from the multipart form body, the uploaded files
+// are set to the fields of type *multipart.FileHeader or
+// []*multipart.FileHeader, then validates it.
+func (this *Ctx) BindMultipart(obj interface{}) (err error) {
+	if err = this.bind(obj, this.bindMultipart); err != nil {
+		return
+	}
+	return validate(obj, tagForm)
+}
+
+// BindParams fills obj from the route params, then validates it.
+func (this *Ctx) BindParams(obj interface{}) (err error) {
+	if err = this.bind(obj, this.bindParams); err != nil {
+		return
+	}
+	return validate(obj, tagParam)
+}
+
+// bind checks obj, sets the default values, and calls fns in order.
+func (this *Ctx) bind(obj interface{}, fns ...func(obj interface{}) error) (err error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: %T is not a pointer to struct", obj)
+	}
+	if err = decodeValues(obj, tagDefault, nil, nil); err != nil {
+		return
+	}
+	for _, fn := range fns {
+		if err = fn(obj); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this *Ctx) contentType() string {
+	ct, _, _ := mime.ParseMediaType(this.request.Header.Get("Content-Type"))
+	return ct
+}
+
+func (this *Ctx) bindJSON(obj interface{}) (err error) {
+	if this.request.Body == nil {
+		return fmt.Errorf("bind: empty request body")
+	}
+	err = json.NewDecoder(this.request.Body).Decode(obj)
+	if e, ok := err.(*json.UnmarshalTypeError); ok {
+		name := e.Field
+		if name == "" {
+			name = e.Value
+		}
+		return gvalidator.Errors{{Field: e.Field, Name: name, Rule: "type"}}
+	}
+	return
+}
+
+func (this *Ctx) bindQuery(obj interface{}) error {
+	return decodeValues(obj, tagForm, this.request.URL.Query(), nil)
+}
+
+func (this *Ctx) bindForm(obj interface{}) (err error) {
+	if err = this.request.ParseForm(); err != nil {
+		return
+	}
+	return decodeValues(obj, tagForm, this.request.PostForm, nil)
+}
+
+func (this *Ctx) bindMultipart(obj interface{}) (err error) {
+	form, err := this.MultipartForm(0)
+	if err != nil {
+		return
+	}
+	return decodeValues(obj, tagForm, form.Value, form.File)
+}
+
+func (this *Ctx) bindParams(obj interface{}) error {
+	values := map[string][]string{}
+	for _, p := range this.param {
+		values[p.Key] = append(values[p.Key], p.Value)
+	}
+	return decodeValues(obj, tagParam, values, nil)
+}
+
+func validate(obj interface{}, tag string) error {
+	return gvalidator.StructWithName(obj, func(field reflect.StructField) string {
+		return gvalidator.TagName(field, tag)
+	})
+}
+
+func decodeValues(obj interface{}, tag string, values map[string][]string, files map[string][]*multipart.FileHeader) error {
+	d := &decoder{tag: tag, values: values, files: files}
+	d.decode(reflect.ValueOf(obj).Elem(), "", "")
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+// decoder sets the fields of struct from values and files, tag is the name of
+// struct tag. If tag is tagDefault, the value in tag is used, and only the
+// zero fields are set.
+type decoder struct {
+	tag    string
+	values map[string][]string
+	files  map[string][]*multipart.FileHeader
+	errs   gvalidator.Errors
+}
+
+// decode sets the fields of struct v, field and name are the prefixes of the
+// struct field path and the name in request.
+func (d *decoder) decode(v reflect.Value, field, name string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous {
+			d.nested(fv, field, name)
+			continue
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		fieldName, fieldKey := field+sf.Name, name+sf.Name
+		if d.tag != tagDefault {
+			if sf.Tag.Get(d.tag) == "-" {
+				continue
+			}
+			fieldKey = name + gvalidator.TagName(sf, d.tag)
+		}
+		ft := sf.Type
+		switch {
+		case ft == fileHeaderType:
+			if fs := d.files[fieldKey]; len(fs) > 0 {
+				fv.Set(reflect.ValueOf(fs[0]))
+			}
+			continue
+		case ft == fileHeadersType:
+			if fs := d.files[fieldKey]; len(fs) > 0 {
+				fv.Set(reflect.ValueOf(fs))
+			}
+			continue
+		case isStruct(ft):
+			d.nested(fv, fieldName+".", fieldKey+".")
+			continue
+		}
+		var vals []string
+		if d.tag == tagDefault {
+			def, ok := sf.Tag.Lookup(tagDefault)
+			if !ok || !isZero(fv) {
+				continue
+			}
+			vals = []string{def}
+			if fv.Kind() == reflect.Slice {
+				vals = strings.Split(def, ",")
+			}
+		} else if vals = d.values[fieldKey]; len(vals) == 0 {
+			continue
+		}
+		if err := setField(fv, vals); err != nil {
+			d.errs = append(d.errs, &gvalidator.FieldError{
+				Field: fieldName,
+				Name:  fieldKey,
+				Rule:  "type",
+				Value: vals[0],
+			})
+		}
+	}
+}
+
+// nested decodes the struct or pointer to struct v, a nil pointer is set only
+// if any field of it is set.
+func (d *decoder) nested(v reflect.Value, field, name string) {
+	switch {
+	case v.Kind() == reflect.Struct:
+		d.decode(v, field, name)
+	case v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct:
+		if !v.IsNil() {
+			d.decode(v.Elem(), field, name)
+			return
+		}
+		if !v.CanSet() {
+			return
+		}
+		e := reflect.New(v.Type().Elem())
+		d.decode(e.Elem(), field, name)
+		if !isZero(e.Elem()) {
+			v.Set(e)
+		}
+	}
+}
+
+// isStruct returns true if the fields of t are decoded one by one, t is a
+// struct or a pointer to struct, but not time.Time or TextUnmarshaler.
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func isZero(v reflect.Value) bool {
+	if !v.CanInterface() {
+		return false
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func setField(v reflect.Value, vals []string) (err error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		e := reflect.New(v.Type().Elem())
+		if err = setField(e.Elem(), vals); err != nil {
+			return
+		}
+		v.Set(e)
+		return
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(vals[0]))
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err = setValue(s.Index(i), val); err != nil {
+				return
+			}
+		}
+		v.Set(s)
+		return
+	}
+	return setValue(v, vals[0])
+}
+
+func setValue(v reflect.Value, s string) (err error) {
+	// time.Time is a TextUnmarshaler of RFC 3339 only, gcast accepts more
+	// layouts.
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok && v.Type() != timeType {
+		return u.UnmarshalText([]byte(s))
+	}
+	if s == "" && v.Kind() != reflect.String {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if s == "on" {
+			b = true
+		} else if b, err = gcast.ToBoolE(s); err != nil {
+			return
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if v.Type() == durationType {
+			var d time.Duration
+			d, err = gcast.ToDurationE(s)
+			n = int64(d)
+		} else {
+			n, err = gcast.ToInt64E(decimal(s))
+		}
+		if err != nil {
+			return
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%s overflows %s", s, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = gcast.ToUint64E(decimal(s)); err != nil {
+			return
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("%s overflows %s", s, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = gcast.ToFloat64E(s); err != nil {
+			return
+		}
+		v.SetFloat(f)
+	case reflect.Struct:
+		if v.Type() != timeType {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var t time.Time
+		if t, err = gcast.ToTimeE(s); err != nil {
+			return
+		}
+		v.Set(reflect.ValueOf(t))
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return
+}
+
+// decimal trims the leading zeros of s, gcast parses 010 as an octal number,
+// but users mean 10.
+func decimal(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	if len(s) > 1 && s[0] == '0' && s[1] != 'x' && s[1] != 'X' {
+		s = strings.TrimLeft(s, "0")
+		if s == "" {
+			s = "0"
+		}
+	}
+	return sign + s
+}
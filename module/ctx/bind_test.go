@@ -0,0 +1,174 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gvalidator "github.com/snail007/gmc/util/validator"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+type Paging struct {
+	Page int `form:"page" default:"1" validate:"gte=1"`
+	Size int `form:"size" default:"20" validate:"max=100"`
+}
+
+type bindUser struct {
+	Paging
+	ID       int64         `param:"id" json:"-" form:"-"`
+	Name     string        `form:"name" json:"name" validate:"required,min=3"`
+	Email    string        `form:"email" json:"email" validate:"omitempty,email"`
+	Role     string        `form:"role" json:"role" default:"user" validate:"oneof=admin user"`
+	Tags     []string      `form:"tag" json:"tags"`
+	Age      *uint8        `form:"age" json:"age"`
+	Active   bool          `form:"active" json:"active"`
+	Birthday time.Time     `form:"birthday" json:"birthday"`
+	Timeout  time.Duration `form:"timeout" json:"timeout"`
+	Address  struct {
+		City string `form:"city" json:"city"`
+	} `form:"address" json:"address"`
+	Extra *struct {
+		Note string `form:"note" validate:"required"`
+	} `form:"extra" json:"extra"`
+}
+
+func TestCtx_Bind_Query(t *testing.T) {
+	assert := assert2.New(t)
+	c := mockCtx("GET", "/user/9?name=alice&tag=a&tag=b&age=018&active=on&birthday=2020-01-02&timeout=3s&address.city=x&size=50", "")
+	c.SetParam(gcore.Params{{Key: "id", Value: "9"}})
+	var u bindUser
+	assert.Nil(c.Bind(&u))
+	assert.Equal(int64(9), u.ID)
+	assert.Equal("alice", u.Name)
+	assert.Equal("user", u.Role)
+	assert.Equal([]string{"a", "b"}, u.Tags)
+	assert.Equal(uint8(18), *u.Age)
+	assert.True(u.Active)
+	assert.Equal(2020, u.Birthday.Year())
+	assert.Equal(3*time.Second, u.Timeout)
+	assert.Equal("x", u.Address.City)
+	assert.Nil(u.Extra)
+	assert.Equal(1, u.Page)
+	assert.Equal(50, u.Size)
+}
+
+func TestCtx_Bind_Errors(t *testing.T) {
+	assert := assert2.New(t)
+	var u bindUser
+	err := mockCtx("GET", "/?name=al&age=300&page=x", "").BindQuery(&u)
+	errs, ok := err.(gvalidator.Errors)
+	assert.True(ok)
+	if assert.Len(errs, 2) {
+		assert.Equal("page", errs[0].Name)
+		assert.Equal("type", errs[0].Rule)
+		assert.Equal("age", errs[1].Name)
+		assert.Equal("type", errs[1].Rule)
+	}
+
+	u = bindUser{}
+	err = mockCtx("GET", "/?name=al&email=foo&role=root", "").BindQuery(&u)
+	assert.Equal(map[string]string{
+		"name":  "name must be at least 3",
+		"email": "email must be a valid email address",
+		"role":  "role must be one of [admin user]",
+	}, err.(gvalidator.Errors).Translate(nil, ""))
+
+	assert.NotNil(mockCtx("GET", "/", "").Bind(u))
+	var n int
+	assert.NotNil(mockCtx("GET", "/", "").Bind(&n))
+}
+
+func TestCtx_Bind_Nested(t *testing.T) {
+	assert := assert2.New(t)
+	var u bindUser
+	err := mockCtx("GET", "/?name=alice&extra.note=", "").BindQuery(&u)
+	assert.Nil(err)
+	assert.Nil(u.Extra)
+	err = mockCtx("GET", "/?name=alice&extra.note=n&page=02", "").BindQuery(&u)
+	assert.Nil(err)
+	assert.Equal("n", u.Extra.Note)
+	assert.Equal(2, u.Page)
+}
+
+func TestCtx_BindForm(t *testing.T) {
+	assert := assert2.New(t)
+	var u bindUser
+	c := mockCtx("POST", "/?page=3", "name=bob&role=admin&size=10")
+	assert.Nil(c.Bind(&u))
+	assert.Equal("bob", u.Name)
+	assert.Equal("admin", u.Role)
+	assert.Equal(3, u.Page)
+	assert.Equal(10, u.Size)
+}
+
+func TestCtx_BindJSON(t *testing.T) {
+	assert := assert2.New(t)
+	var u bindUser
+	c := mockCtx("POST", "/", `{"name":"carol","tags":["x"],"address":{"city":"y"}}`)
+	c.Request().Header.Set("Content-Type", "application/json; charset=utf-8")
+	assert.Nil(c.Bind(&u))
+	assert.Equal("carol", u.Name)
+	assert.Equal("user", u.Role)
+	assert.Equal([]string{"x"}, u.Tags)
+	assert.Equal("y", u.Address.City)
+	assert.Equal(20, u.Size)
+
+	u = bindUser{}
+	c = mockCtx("POST", "/", `{"name":1}`)
+	err := c.BindJSON(&u)
+	errs, ok := err.(gvalidator.Errors)
+	assert.True(ok)
+	assert.Equal("name", errs[0].Name)
+	assert.Equal("type", errs[0].Rule)
+
+	u = bindUser{}
+	err = mockCtx("POST", "/", `{"role":"admin"}`).BindJSON(&u)
+	assert.Equal("name is required", err.Error())
+}
+
+func TestCtx_BindMultipart(t *testing.T) {
+	assert := assert2.New(t)
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	w.WriteField("name", "dave")
+	fw, _ := w.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("hello"))
+	fw, _ = w.CreateFormFile("files", "b.txt")
+	fw.Write([]byte("b"))
+	fw, _ = w.CreateFormFile("files", "c.txt")
+	fw.Write([]byte("c"))
+	w.Close()
+	r := httptest.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	c := NewCtx()
+	c.SetRequest(r)
+	var obj struct {
+		Name  string                  `form:"name" validate:"required"`
+		File  *multipart.FileHeader   `form:"file" validate:"required"`
+		Files []*multipart.FileHeader `form:"files" validate:"len=2"`
+	}
+	assert.Nil(c.Bind(&obj))
+	assert.Equal("dave", obj.Name)
+	assert.Equal("a.txt", obj.File.Filename)
+	assert.Equal("c.txt", obj.Files[1].Filename)
+}
+
+func TestCtx_BindParams(t *testing.T) {
+	assert := assert2.New(t)
+	c := mockCtx("GET", "/", "")
+	c.SetParam(gcore.Params{{Key: "id", Value: "x"}})
+	var obj struct {
+		ID int `param:"id"`
+	}
+	err := c.BindParams(&obj)
+	assert.Equal("id has an invalid value", err.Error())
+}
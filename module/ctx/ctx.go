@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"fmt"
 	gcore "github.com/snail007/gmc/core"
+	gwebsocket "github.com/snail007/gmc/http/websocket"
 	gmap "github.com/snail007/gmc/util/map"
 	"github.com/snail007/gmc/util/paginator"
 	"io"
@@ -329,6 +330,16 @@ func (this *Ctx) IsWebsocket() bool {
 	return false
 }
 
+// WebSocket upgrades the request to a websocket connection by upgrader,
+// default is gwebsocket.NewUpgrader(). It's used in controller methods and
+// api handle functions, the caller should close the connection when done.
+func (this *Ctx) WebSocket(upgrader ...gcore.WebSocketUpgrader) (conn gcore.WebSocketConn, err error) {
+	if len(upgrader) > 0 && upgrader[0] != nil {
+		return upgrader[0].Upgrade(this)
+	}
+	return gwebsocket.Upgrade(this)
+}
+
 // Stop will exit controller method or api handle function at once.
 func (this *Ctx) Stop(msg ...interface{}) {
 	ghttputil.Stop(this.Response(), msg...)
@@ -0,0 +1,194 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+	gencoder "github.com/snail007/gmc/http/encoder"
+)
+
+// ErrNotAcceptable is returned by Negotiate if the client accepts none of the
+// formats.
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// NegotiateFormat returns the format picked by the Accept header of request
+// from formats, the formats are the keys of gcore.Providers.RegisterEncoder,
+// such as json, xml, yaml, msgpack, html and text. Empty formats means all
+// registered encoders in the order of registration. The format matches the
+// most preferred media range wins, by quality, specificity and position in
+// Accept, then by the order of formats. Empty string is returned if none is
+// acceptable.
+func (this *Ctx) NegotiateFormat(formats ...string) string {
+	if len(formats) == 0 {
+		formats = gcore.Providers.Encoders()
+	}
+	format, _ := this.negotiate(formats)
+	return format
+}
+
+// Negotiate writes data with status code in the format picked by
+// NegotiateFormat, and sets the Vary header. The html format is picked only
+// if data is gcore.HTMLData, which renders a template with the View, other
+// formats encode the Data of it. If none is acceptable, it responds 406 and
+// returns ErrNotAcceptable. If data can not be encoded in the picked format,
+// it responds 500 and returns the error, so pass the formats data supports
+// in every call, such as maps are not supported by xml.
+func (this *Ctx) Negotiate(code int, data interface{}, formats ...string) (err error) {
+	htmlData, isHTML := data.(gcore.HTMLData)
+	if v, ok := data.(*gcore.HTMLData); ok && v != nil {
+		htmlData, isHTML = *v, true
+	}
+	if len(formats) == 0 {
+		formats = gcore.Providers.Encoders()
+	}
+	if !isHTML {
+		formats = removeFormat(formats, gencoder.HTML)
+	}
+	this.response.Header().Add("Vary", "Accept")
+	format, encoder := this.negotiate(formats)
+	if encoder == nil {
+		this.SetHeader("Content-Type", "text/plain; charset=utf-8")
+		this.Status(http.StatusNotAcceptable)
+		this.Write(http.StatusText(http.StatusNotAcceptable))
+		return ErrNotAcceptable
+	}
+	if isHTML && format != gencoder.HTML {
+		data = htmlData.Data
+	}
+	// data is encoded before writing the status, so a failure is not sent
+	// as code with a truncated body.
+	var buf bytes.Buffer
+	if err = encoder.Encode(&buf, data); err != nil {
+		this.SetHeader("Content-Type", "text/plain; charset=utf-8")
+		this.Status(http.StatusInternalServerError)
+		this.Write(http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	this.SetHeader("Content-Type", encoder.ContentType())
+	this.Status(code)
+	_, err = this.response.Write(buf.Bytes())
+	return
+}
+
+func (this *Ctx) negotiate(formats []string) (format string, encoder gcore.Encoder) {
+	ranges := parseAccept(this.request.Header.Get("Accept"))
+	var best *acceptRange
+	for _, f := range formats {
+		p := gcore.Providers.Encoder(f)
+		if p == nil {
+			continue
+		}
+		e := p(this)
+		for _, t := range e.MediaTypes() {
+			r := bestRange(ranges, t)
+			if r != nil && r.q > 0 && (best == nil || r.before(best)) {
+				format, encoder, best = f, e, r
+			}
+		}
+	}
+	return
+}
+
+// bestRange returns the most specific range matches mediaType.
+func bestRange(ranges []acceptRange, mediaType string) (r *acceptRange) {
+	for i := range ranges {
+		if ranges[i].match(mediaType) && (r == nil || ranges[i].specificity() > r.specificity()) {
+			r = &ranges[i]
+		}
+	}
+	return
+}
+
+func removeFormat(formats []string, format string) []string {
+	var s []string
+	for _, f := range formats {
+		if f != format {
+			s = append(s, f)
+		}
+	}
+	return s
+}
+
+// acceptRange is a media range in Accept header, such as text/*;q=0.8.
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+	// index is the position in Accept header.
+	index int
+}
+
+// before returns true if r is preferred to r1, by quality, specificity and
+// position in order.
+func (r *acceptRange) before(r1 *acceptRange) bool {
+	if r.q != r1.q {
+		return r.q > r1.q
+	}
+	if r.specificity() != r1.specificity() {
+		return r.specificity() > r1.specificity()
+	}
+	return r.index < r1.index
+}
+
+func (r acceptRange) match(mediaType string) bool {
+	typ, subtype := splitMediaType(mediaType)
+	return (r.typ == "*" || r.typ == typ) && (r.subtype == "*" || r.subtype == subtype)
+}
+
+// specificity is 2 for type/subtype, 1 for type/*, 0 for */*.
+func (r acceptRange) specificity() int {
+	n := 0
+	if r.typ != "*" {
+		n++
+	}
+	if r.subtype != "*" {
+		n++
+	}
+	return n
+}
+
+// parseAccept returns the media ranges in Accept, the ranges of q=0 mean the
+// media types are not acceptable, empty Accept means */*.
+func parseAccept(accept string) (ranges []acceptRange) {
+	if strings.TrimSpace(accept) == "" {
+		return []acceptRange{{typ: "*", subtype: "*", q: 1}}
+	}
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		typ, subtype := splitMediaType(fields[0])
+		if typ == "" {
+			continue
+		}
+		r := acceptRange{typ: typ, subtype: subtype, q: 1, index: len(ranges)}
+		for _, p := range fields[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return
+}
+
+func splitMediaType(s string) (typ, subtype string) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "*" {
+		return "*", "*"
+	}
+	i := strings.Index(s, "/")
+	if i <= 0 || i == len(s)-1 {
+		return "", ""
+	}
+	return s[:i], s[i+1:]
+}
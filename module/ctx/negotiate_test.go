@@ -0,0 +1,99 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	gencoder "github.com/snail007/gmc/http/encoder"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func init() {
+	p := gcore.Providers
+	p.RegisterEncoder(gencoder.JSON, func(ctx gcore.Ctx) gcore.Encoder { return gencoder.NewJSON() })
+	p.RegisterEncoder(gencoder.XML, func(ctx gcore.Ctx) gcore.Encoder { return gencoder.NewXML() })
+	p.RegisterEncoder(gencoder.YAML, func(ctx gcore.Ctx) gcore.Encoder { return gencoder.NewYAML() })
+	p.RegisterEncoder(gencoder.MsgPack, func(ctx gcore.Ctx) gcore.Encoder { return gencoder.NewMsgPack() })
+	p.RegisterEncoder(gencoder.HTML, func(ctx gcore.Ctx) gcore.Encoder { return gencoder.NewHTML(ctx) })
+	p.RegisterEncoder(gencoder.Text, func(ctx gcore.Ctx) gcore.Encoder { return gencoder.NewText() })
+}
+
+func acceptCtx(accept string) *Ctx {
+	c := mockCtx("GET", "/", "")
+	if accept != "" {
+		c.Request().Header.Set("Accept", accept)
+	}
+	return c
+}
+
+func TestCtx_NegotiateFormat(t *testing.T) {
+	assert := assert2.New(t)
+	for _, v := range []struct {
+		accept  string
+		formats []string
+		expect  string
+	}{
+		{"", nil, "json"},
+		{"*/*", nil, "json"},
+		{"application/xml", nil, "xml"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", nil, "html"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", []string{"json", "xml"}, "xml"},
+		{"application/yaml;q=0.5, application/x-msgpack", nil, "msgpack"},
+		{"text/*", nil, "xml"},
+		{"text/*", []string{"html", "text"}, "html"},
+		{"text/*, text/plain", nil, "text"},
+		{"application/json;q=0, */*", nil, "xml"},
+		{"application/xml, application/json", nil, "xml"},
+		{"image/png", nil, ""},
+		{"application/json", []string{"xml", "foo"}, ""},
+	} {
+		assert.Equal(v.expect, acceptCtx(v.accept).NegotiateFormat(v.formats...), v.accept)
+	}
+}
+
+func TestCtx_Negotiate(t *testing.T) {
+	assert := assert2.New(t)
+	data := map[string]interface{}{"a": 1}
+
+	c := acceptCtx("application/json")
+	w := httptest.NewRecorder()
+	c.SetResponse(w)
+	assert.Nil(c.Negotiate(201, data))
+	assert.Equal(201, w.Code)
+	assert.Equal("application/json", w.Header().Get("Content-Type"))
+	assert.Equal("Accept", w.Header().Get("Vary"))
+	assert.Equal(`{"a":1}`+"\n", w.Body.String())
+
+	// html is picked only for gcore.HTMLData.
+	c = acceptCtx("text/html,*/*;q=0.8")
+	w = httptest.NewRecorder()
+	c.SetResponse(w)
+	assert.Nil(c.Negotiate(200, gcore.HTMLData{Template: "index", Data: data}, "yaml", "json"))
+	assert.Equal("a: 1\n", w.Body.String())
+
+	c = acceptCtx("text/plain")
+	w = httptest.NewRecorder()
+	c.SetResponse(w)
+	assert.Equal(ErrNotAcceptable, c.Negotiate(200, data, "json", "xml"))
+	assert.Equal(406, w.Code)
+
+	c = acceptCtx("text/html")
+	w = httptest.NewRecorder()
+	c.SetResponse(w)
+	assert.Equal(ErrNotAcceptable, c.Negotiate(200, data, "html"))
+
+	// maps can not be encoded in xml.
+	c = acceptCtx("application/xml")
+	w = httptest.NewRecorder()
+	c.SetResponse(w)
+	assert.NotNil(c.Negotiate(200, data))
+	assert.Equal(500, w.Code)
+	assert.Equal("text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal("Internal Server Error", w.Body.String())
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+)
+
+// DefaultSSEHeartbeat is the default interval of heartbeat comments of SSE.
+var DefaultSSEHeartbeat = time.Second * 15
+
+type sse struct {
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	lastEventID string
+	mu          sync.Mutex
+	done        chan struct{}
+	once        sync.Once
+}
+
+// SSE starts a server-sent events stream, it sets the response headers and
+// flushes them at once. A heartbeat comment is sent every heartbeat to keep
+// the connection alive, default is DefaultSSEHeartbeat, 0 disables it.
+// The caller should stop sending when Done is closed, the stream is closed
+// by the server when the handler returns.
+func (this *Ctx) SSE(heartbeat ...time.Duration) (s gcore.SSE, err error) {
+	flusher, ok := this.response.(http.Flusher)
+	if w, isWrapper := this.response.(*ghttputil.ResponseWriter); isWrapper {
+		ok = w.CanFlush()
+	}
+	if !ok {
+		err = fmt.Errorf("streaming is not supported by the ResponseWriter")
+		return
+	}
+	lastEventID := this.Header("Last-Event-ID")
+	if lastEventID == "" {
+		// EventSource polyfills send it in query string.
+		lastEventID = this.GET("lastEventId")
+	}
+	h := this.response.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	if this.request.ProtoMajor == 1 {
+		h.Set("Connection", "keep-alive")
+	}
+	this.response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	st := &sse{
+		w:           this.response,
+		flusher:     flusher,
+		lastEventID: lastEventID,
+		done:        make(chan struct{}),
+	}
+	interval := DefaultSSEHeartbeat
+	if len(heartbeat) > 0 {
+		interval = heartbeat[0]
+	}
+	this.Set(gcore.SSEKey, st)
+	go st.watch(this.request, interval)
+	return st, nil
+}
+
+func (s *sse) watch(r *http.Request, heartbeat time.Duration) {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		t := time.NewTicker(heartbeat)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-r.Context().Done():
+			s.Close()
+			return
+		case <-tick:
+			if s.Comment("heartbeat") != nil {
+				s.Close()
+				return
+			}
+		}
+	}
+}
+
+func (s *sse) LastEventID() string {
+	return s.lastEventID
+}
+
+// Send writes event and flushes it.
+func (s *sse) Send(event gcore.SSEEvent) (err error) {
+	var buf bytes.Buffer
+	if event.ID != "" {
+		buf.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + singleLine(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString(fmt.Sprintf("retry: %d\n", event.Retry/time.Millisecond))
+	}
+	for _, line := range strings.Split(strings.Replace(event.Data, "\r\n", "\n", -1), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.Bytes())
+}
+
+// Event sends data as event, event "" means the default "message" event.
+func (s *sse) Event(event, data string) (err error) {
+	return s.Send(gcore.SSEEvent{Event: event, Data: data})
+}
+
+// JSON sends v encoded in json as event.
+func (s *sse) JSON(event string, v interface{}) (err error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	return s.Send(gcore.SSEEvent{Event: event, Data: string(b)})
+}
+
+// Comment sends a comment line, it's ignored by the client.
+func (s *sse) Comment(text string) (err error) {
+	return s.write([]byte(": " + singleLine(text) + "\n\n"))
+}
+
+// Retry tells the client the reconnection time.
+func (s *sse) Retry(d time.Duration) (err error) {
+	return s.write([]byte(fmt.Sprintf("retry: %d\n\n", d/time.Millisecond)))
+}
+
+func (s *sse) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close waits for the writing event done, so nothing is written to the
+// response after it returns.
+func (s *sse) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *sse) write(b []byte) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		return fmt.Errorf("sse stream closed")
+	default:
+	}
+	if _, err = s.w.Write(b); err != nil {
+		return
+	}
+	s.flusher.Flush()
+	return
+}
+
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSE(t *testing.T) {
+	assert := assert.New(t)
+	c := mockCtx("GET", "/events", "")
+	c.Request().Header.Set("Last-Event-ID", "41")
+	s, err := c.SSE(0)
+	assert.Nil(err)
+	defer s.Close()
+	assert.Equal("41", s.LastEventID())
+	assert.Nil(s.Send(gcore.SSEEvent{ID: "42", Event: "tick", Data: "a\nb", Retry: time.Second}))
+	assert.Nil(s.JSON("", map[string]int{"n": 1}))
+	assert.Nil(s.Comment("hi"))
+	w := c.Response().(*httptest.ResponseRecorder)
+	assert.Equal("text/event-stream; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal("no-cache", w.Header().Get("Cache-Control"))
+	assert.True(w.Flushed)
+	assert.Equal("id: 42\nevent: tick\nretry: 1000\ndata: a\ndata: b\n\n"+
+		"data: {\"n\":1}\n\n"+
+		": hi\n\n", w.Body.String())
+}
+
+func TestSSE_Disconnect(t *testing.T) {
+	assert := assert.New(t)
+	c := mockCtx("GET", "/events?lastEventId=7", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	c.SetRequest(c.Request().WithContext(ctx))
+	s, err := c.SSE(time.Millisecond * 10)
+	assert.Nil(err)
+	assert.Equal("7", s.LastEventID())
+	time.Sleep(time.Millisecond * 35)
+	cancel()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("sse is not done after the client disconnected")
+	}
+	assert.NotNil(s.Event("", "x"))
+	st := s.(*sse)
+	st.mu.Lock()
+	body := c.Response().(*httptest.ResponseRecorder).Body.String()
+	st.mu.Unlock()
+	assert.True(strings.HasPrefix(body, ": heartbeat\n\n"))
+}
+
+func TestSSE_NotSupported(t *testing.T) {
+	assert := assert.New(t)
+	c := mockCtx("GET", "/events", "")
+	c.SetResponse(ghttputil.NewResponseWriter(struct{ http.ResponseWriter }{httptest.NewRecorder()}))
+	_, err := c.SSE()
+	assert.NotNil(err)
+}
@@ -7,6 +7,7 @@ package gdb
 
 import (
 	"github.com/snail007/gmc/core"
+	ghealth "github.com/snail007/gmc/module/health"
 	"github.com/snail007/gmc/util/cast"
 	gmap "github.com/snail007/gmc/util/map"
 	"reflect"
@@ -72,6 +73,7 @@ func Init(cfg0 gcore.Config) (err error) {
 				if err != nil {
 					return
 				}
+				ghealth.Register("db.mysql."+id, groupMySQL.DB(id).(gcore.HealthChecker))
 			} else if k == "sqlite3" {
 				db := groupSQLite3.DB(id)
 				if db != nil {
@@ -89,6 +91,7 @@ func Init(cfg0 gcore.Config) (err error) {
 				if err != nil {
 					return
 				}
+				ghealth.Register("db.sqlite3."+id, groupSQLite3.DB(id).(gcore.HealthChecker))
 			}
 		}
 	}
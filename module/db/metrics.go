@@ -0,0 +1,53 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"database/sql"
+	"sort"
+
+	gmetrics "github.com/snail007/gmc/module/metrics"
+)
+
+func init() {
+	gmetrics.Register("db", gmetrics.CollectorFunc(collectMetrics))
+}
+
+// collectMetrics outputs the pool stats of all databases, labeled with the
+// type and id of the database.
+func collectMetrics() []gmetrics.Family {
+	open := gmetrics.NewFamily("gmc_db_open_connections", "Number of established connections.", gmetrics.TypeGauge)
+	inUse := gmetrics.NewFamily("gmc_db_in_use_connections", "Number of connections currently in use.", gmetrics.TypeGauge)
+	idle := gmetrics.NewFamily("gmc_db_idle_connections", "Number of idle connections.", gmetrics.TypeGauge)
+	maxOpen := gmetrics.NewFamily("gmc_db_max_open_connections", "Maximum number of open connections.", gmetrics.TypeGauge)
+	waitCount := gmetrics.NewFamily("gmc_db_wait_count_total", "Total number of connections waited for.", gmetrics.TypeCounter)
+	waitDuration := gmetrics.NewFamily("gmc_db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", gmetrics.TypeCounter)
+	add := func(typ, id string, st sql.DBStats) {
+		open.Add(float64(st.OpenConnections), "type", typ, "id", id)
+		inUse.Add(float64(st.InUse), "type", typ, "id", id)
+		idle.Add(float64(st.Idle), "type", typ, "id", id)
+		maxOpen.Add(float64(st.MaxOpenConnections), "type", typ, "id", id)
+		waitCount.Add(float64(st.WaitCount), "type", typ, "id", id)
+		waitDuration.Add(st.WaitDuration.Seconds(), "type", typ, "id", id)
+	}
+	ids := []string{}
+	for id := range groupMySQL.dbGroup {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		add("mysql", id, groupMySQL.dbGroup[id].Stats())
+	}
+	ids = []string{}
+	for id := range groupSQLite3.dbGroup {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		add("sqlite3", id, groupSQLite3.dbGroup[id].Stats())
+	}
+	return []gmetrics.Family{*open, *inUse, *idle, *maxOpen, *waitCount, *waitDuration}
+}
@@ -7,6 +7,7 @@ package gdb
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/gob"
 	"fmt"
@@ -135,6 +136,11 @@ func (db *MySQLDB) AR() (ar gcore.ActiveRecord) {
 func (db *MySQLDB) Stats() sql.DBStats {
 	return db.ConnPool.Stats()
 }
+
+// HealthCheck implements gcore.HealthChecker, it pings the database.
+func (db *MySQLDB) HealthCheck(ctx context.Context) error {
+	return db.ConnPool.PingContext(ctx)
+}
 func (db *MySQLDB) Begin() (tx *sql.Tx, err error) {
 	return db.ConnPool.Begin()
 }
@@ -7,6 +7,7 @@ package gdb
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"database/sql"
 	"encoding/gob"
@@ -149,6 +150,11 @@ func (db *SQLite3DB) IsEncrypted() bool {
 func (db *SQLite3DB) Stats() sql.DBStats {
 	return db.ConnPool.Stats()
 }
+
+// HealthCheck implements gcore.HealthChecker, it pings the database.
+func (db *SQLite3DB) HealthCheck(ctx context.Context) error {
+	return db.ConnPool.PingContext(ctx)
+}
 func (db *SQLite3DB) Begin() (tx *sql.Tx, err error) {
 	return db.ConnPool.Begin()
 }
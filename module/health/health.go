@@ -0,0 +1,235 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Default is the registry used by the package functions, databases, caches
+// and services of the app are registered in it.
+var Default = New()
+
+// Result is the result of one checker.
+type Result struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Duration is the time used in milliseconds.
+	Duration float64 `json:"duration"`
+}
+
+// Report is the result of all checkers.
+type Report struct {
+	Status string   `json:"status"`
+	Ready  bool     `json:"ready"`
+	Checks []Result `json:"checks"`
+}
+
+// Registry holds the named checkers and the readiness of the app.
+type Registry struct {
+	// Timeout is the max time all checkers can use, default is 5 seconds.
+	Timeout  time.Duration
+	mu       sync.RWMutex
+	checkers map[string]gcore.HealthChecker
+	notReady int32
+}
+
+func New() *Registry {
+	return &Registry{
+		Timeout:  time.Second * 5,
+		checkers: map[string]gcore.HealthChecker{},
+	}
+}
+
+// Register adds checker named name, the checker with same name is replaced.
+func (r *Registry) Register(name string, checker gcore.HealthChecker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkers[name] = checker
+}
+
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.checkers, name)
+}
+
+// Names returns the sorted names of checkers.
+func (r *Registry) Names() (names []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name := range r.checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// SetReady sets the readiness of the app, the app is ready by default, it's
+// set to false when the app is draining, such as graceful stopping and
+// reloading.
+func (r *Registry) SetReady(ready bool) {
+	if ready {
+		atomic.StoreInt32(&r.notReady, 0)
+	} else {
+		atomic.StoreInt32(&r.notReady, 1)
+	}
+}
+
+func (r *Registry) IsReady() bool {
+	return atomic.LoadInt32(&r.notReady) == 0
+}
+
+// Check runs all checkers concurrently, the Status of report is StatusOK if
+// all checkers returned nil.
+func (r *Registry) Check(ctx context.Context) (report Report) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	names := r.Names()
+	r.mu.RLock()
+	checkers := make([]gcore.HealthChecker, len(names))
+	for i, name := range names {
+		checkers[i] = r.checkers[name]
+	}
+	r.mu.RUnlock()
+
+	report = Report{
+		Status: StatusOK,
+		Ready:  r.IsReady(),
+		Checks: make([]Result, len(names)),
+	}
+	g := sync.WaitGroup{}
+	g.Add(len(names))
+	for i := range names {
+		go func(i int) {
+			defer g.Done()
+			report.Checks[i] = check(ctx, names[i], checkers[i])
+		}(i)
+	}
+	g.Wait()
+	for _, v := range report.Checks {
+		if v.Status != StatusOK {
+			report.Status = StatusFail
+			break
+		}
+	}
+	return
+}
+
+func check(ctx context.Context, name string, checker gcore.HealthChecker) (result Result) {
+	start := time.Now()
+	result = Result{Name: name, Status: StatusOK}
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				done <- fmt.Errorf("panic: %v", e)
+			}
+		}()
+		done <- checker.HealthCheck(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	result.Duration = float64(time.Since(start)) / float64(time.Millisecond)
+	return
+}
+
+// HealthHandler outputs the report in JSON, the status code is 503 if any
+// checker fails.
+func (r *Registry) HealthHandler(w http.ResponseWriter, req *http.Request) {
+	report := r.Check(req.Context())
+	code := http.StatusOK
+	if report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeReport(w, code, report)
+}
+
+// ReadyHandler is same as HealthHandler, but the status code is 503 when the
+// app is not ready, so load balancers stop sending traffic to it.
+func (r *Registry) ReadyHandler(w http.ResponseWriter, req *http.Request) {
+	report := r.Check(req.Context())
+	code := http.StatusOK
+	if !report.Ready || report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeReport(w, code, report)
+}
+
+// BindRouter binds HealthHandler and ReadyHandler to `router`, healthPath
+// default is /healthz, readyPath default is /readyz.
+func (r *Registry) BindRouter(router gcore.HTTPRouter, healthPath, readyPath string) {
+	if healthPath == "" {
+		healthPath = "/healthz"
+	}
+	if readyPath == "" {
+		readyPath = "/readyz"
+	}
+	router.HandlerFunc("GET", healthPath, r.HealthHandler)
+	router.HandlerFunc("HEAD", healthPath, r.HealthHandler)
+	router.HandlerFunc("GET", readyPath, r.ReadyHandler)
+	router.HandlerFunc("HEAD", readyPath, r.ReadyHandler)
+}
+
+func writeReport(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Register adds checker to Default.
+func Register(name string, checker gcore.HealthChecker) {
+	Default.Register(name, checker)
+}
+
+// Unregister removes checker from Default.
+func Unregister(name string) {
+	Default.Unregister(name)
+}
+
+// SetReady sets the readiness of Default.
+func SetReady(ready bool) {
+	Default.SetReady(ready)
+}
+
+// IsReady returns the readiness of Default.
+func IsReady() bool {
+	return Default.IsReady()
+}
+
+// Check runs all checkers of Default.
+func Check(ctx context.Context) Report {
+	return Default.Check(ctx)
+}
+
+// BindRouter binds the handlers of Default to router.
+func BindRouter(router gcore.HTTPRouter, healthPath, readyPath string) {
+	Default.BindRouter(router, healthPath, readyPath)
+}
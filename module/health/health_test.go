@@ -0,0 +1,75 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Check(t *testing.T) {
+	assert := assert.New(t)
+	r := New()
+	r.Timeout = time.Millisecond * 50
+	r.Register("b", gcore.HealthCheckerFunc(func(ctx context.Context) error { return nil }))
+	r.Register("a", gcore.HealthCheckerFunc(func(ctx context.Context) error { return fmt.Errorf("down") }))
+	assert.Equal([]string{"a", "b"}, r.Names())
+
+	report := r.Check(context.Background())
+	assert.Equal(StatusFail, report.Status)
+	assert.True(report.Ready)
+	assert.Equal("a", report.Checks[0].Name)
+	assert.Equal(StatusFail, report.Checks[0].Status)
+	assert.Equal("down", report.Checks[0].Error)
+	assert.Equal(StatusOK, report.Checks[1].Status)
+
+	r.Unregister("a")
+	r.Register("panic", gcore.HealthCheckerFunc(func(ctx context.Context) error { panic("oops") }))
+	r.Register("slow", gcore.HealthCheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+	report = r.Check(context.Background())
+	assert.Equal("panic: oops", report.Checks[1].Error)
+	assert.Equal(context.DeadlineExceeded.Error(), report.Checks[2].Error)
+}
+
+func TestRegistry_Handlers(t *testing.T) {
+	assert := assert.New(t)
+	r := New()
+	r.Register("ok", gcore.HealthCheckerFunc(func(ctx context.Context) error { return nil }))
+	do := func(h http.HandlerFunc) (int, Report) {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", "/", nil))
+		var report Report
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w.Code, report
+	}
+	code, report := do(r.ReadyHandler)
+	assert.Equal(http.StatusOK, code)
+	assert.Len(report.Checks, 1)
+
+	r.SetReady(false)
+	assert.False(r.IsReady())
+	code, report = do(r.ReadyHandler)
+	assert.Equal(http.StatusServiceUnavailable, code)
+	assert.False(report.Ready)
+	code, _ = do(r.HealthHandler)
+	assert.Equal(http.StatusOK, code)
+
+	r.SetReady(true)
+	r.Register("fail", gcore.HealthCheckerFunc(func(ctx context.Context) error { return fmt.Errorf("fail") }))
+	code, _ = do(r.HealthHandler)
+	assert.Equal(http.StatusServiceUnavailable, code)
+}
@@ -8,8 +8,12 @@ package gi18n
 import (
 	"bytes"
 	gcore "github.com/snail007/gmc/core"
+	gfs "github.com/snail007/gmc/util/fs"
 	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -79,3 +83,32 @@ func TestMatch(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal("zh-CN", v.String())
 }
+
+func TestInit_SetFS(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "i18n")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "en-us.toml"), []byte(`001="Hi"`), 0644)
+	SetFS(http.Dir("tests"))
+	defer SetFS(nil)
+	for _, v := range []struct {
+		source, en, zh string
+	}{
+		{gfs.SourceDisk, "Hi", "001"},
+		{gfs.SourceEmbed, "Hello", "你好"},
+		{gfs.SourceOverlay, "Hi", "你好"},
+	} {
+		cfg := gcore.Providers.Config("")()
+		cfg.Set("i18n.enable", true)
+		cfg.Set("i18n.dir", dir)
+		cfg.Set("i18n.source", v.source)
+		assert.Nil(Init(cfg))
+		assert.Equal(v.en, I18N.Tr("en-us", "001"), v.source)
+		assert.Equal(v.zh, I18N.Tr("zh-cn", "001"), v.source)
+	}
+	cfg := gcore.Providers.Config("")()
+	cfg.Set("i18n.enable", true)
+	cfg.Set("i18n.source", "none")
+	assert.NotNil(Init(cfg))
+}
@@ -9,14 +9,18 @@ import (
 	"bytes"
 	"encoding/base64"
 	gcore "github.com/snail007/gmc/core"
+	gfs "github.com/snail007/gmc/util/fs"
 	"golang.org/x/text/language"
-	"path/filepath"
+	"net/http"
+	"path"
 	"strings"
 )
 
 var (
 	bindata = map[string][]byte{}
 	I18N    = newI18n()
+	// i18nFS is the embedded locale files set by SetFS.
+	i18nFS http.FileSystem
 )
 
 func SetBinData(data map[string]string) {
@@ -30,6 +34,13 @@ func SetBinData(data map[string]string) {
 	}
 }
 
+// SetFS sets the embedded locale files, such as the result of
+// gfs.FromFS(embedFS, "i18n"). The `source` of [i18n] in app.toml
+// selects disk, embed or overlay of them.
+func SetFS(fs http.FileSystem) {
+	i18nFS = fs
+}
+
 func Init(cfg gcore.Config) (err error) {
 	if cfg.Sub("i18n") == nil {
 		return
@@ -37,7 +48,7 @@ func Init(cfg gcore.Config) (err error) {
 	if len(bindata) > 0 {
 		return initFromBinData(cfg)
 	}
-	return initFromDisk(cfg)
+	return initFromFS(cfg)
 }
 
 func initFromBinData(cfg gcore.Config) (err error) {
@@ -68,14 +79,13 @@ func initFromBinData(cfg gcore.Config) (err error) {
 	return
 }
 
-func initFromDisk(cfg gcore.Config) (err error) {
-	dir := cfg.GetString("i18n.dir")
+func initFromFS(cfg gcore.Config) (err error) {
 	fallbackLang := cfg.GetString("i18n.default")
 	enalbed := cfg.GetBool("i18n.enable")
 	if !enalbed {
 		return
 	}
-	files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	fs, err := gfs.Select(cfg.GetString("i18n.source"), cfg.GetString("i18n.dir"), i18nFS)
 	if err != nil {
 		return
 	}
@@ -83,15 +93,26 @@ func initFromDisk(cfg gcore.Config) (err error) {
 		langs:        map[string]map[string]string{},
 		fallbackLang: fallbackLang,
 	}
+	files, err := gfs.ReadDir(fs, "/")
+	if err != nil {
+		return
+	}
 	for _, f := range files {
+		if f.IsDir() || path.Ext(f.Name()) != ".toml" {
+			continue
+		}
+		var b []byte
+		b, err = gfs.ReadFile(fs, "/"+f.Name())
+		if err != nil {
+			return
+		}
 		c := gcore.Providers.Config("")()
-		c.SetConfigFile(f)
-		err = c.ReadInConfig()
+		c.SetConfigType("toml")
+		err = c.ReadConfig(bytes.NewReader(b))
 		if err != nil {
 			return
 		}
-		lang := filepath.Base(f)
-		lang = strings.TrimSuffix(lang, filepath.Ext(lang))
+		lang := strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
 		if _, e := language.Parse(lang); e != nil {
 			return gcore.Providers.Error("")().New(e)
 		}
@@ -0,0 +1,118 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gmetrics
+
+import (
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// RuntimeCollector outputs the goroutines, threads, memory and gc stats of
+// the Go runtime, it's registered in Default as `go`.
+func RuntimeCollector() Collector {
+	start := float64(time.Now().UnixNano()) / 1e9
+	return CollectorFunc(func() []Family {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		gauge := func(name, help string, v float64) Family {
+			f := NewFamily(name, help, TypeGauge)
+			f.Add(v)
+			return *f
+		}
+		counter := func(name, help string, v float64) Family {
+			f := NewFamily(name, help, TypeCounter)
+			f.Add(v)
+			return *f
+		}
+		info := NewFamily("go_info", "Information about the Go environment.", TypeGauge)
+		info.Add(1, "version", runtime.Version())
+		return []Family{
+			*info,
+			gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())),
+			gauge("go_threads", "Number of OS threads created.", float64(pprof.Lookup("threadcreate").Count())),
+			gauge("go_gomaxprocs", "Value of GOMAXPROCS.", float64(runtime.GOMAXPROCS(0))),
+			gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(m.Alloc)),
+			counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(m.TotalAlloc)),
+			gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(m.Sys)),
+			gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(m.HeapInuse)),
+			gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(m.HeapObjects)),
+			counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(m.NumGC)),
+			counter("go_gc_pause_seconds_total", "Total GC pause time in seconds.", float64(m.PauseTotalNs)/1e9),
+			gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", start),
+		}
+	})
+}
+
+// PoolStatsReader is implemented by gpool.GPool.
+type PoolStatsReader interface {
+	WorkerCount() int
+	Running() int
+	Awaiting() int
+}
+
+// PoolCollector outputs the workers and queued tasks of pool, labeled with
+// pool=name, register one for every pool:
+//
+//	gmetrics.Register("gpool.mail", gmetrics.PoolCollector("mail", pool))
+func PoolCollector(name string, pool PoolStatsReader) Collector {
+	return CollectorFunc(func() []Family {
+		workers := NewFamily("gmc_gpool_workers", "Number of workers in the pool.", TypeGauge)
+		workers.Add(float64(pool.WorkerCount()), "pool", name)
+		running := NewFamily("gmc_gpool_running_workers", "Number of workers running a task.", TypeGauge)
+		running.Add(float64(pool.Running()), "pool", name)
+		queued := NewFamily("gmc_gpool_queued_tasks", "Number of tasks waiting for a worker.", TypeGauge)
+		queued.Add(float64(pool.Awaiting()), "pool", name)
+		return []Family{*workers, *running, *queued}
+	})
+}
+
+// ConnCollector outputs the active connections of server, labeled with
+// server=name.
+func ConnCollector(name string, server interface{ ActiveConnCount() int64 }) Collector {
+	return CollectorFunc(func() []Family {
+		f := NewFamily("gmc_http_active_connections", "Number of active connections.", TypeGauge)
+		f.Add(float64(server.ActiveConnCount()), "server", name)
+		return []Family{*f}
+	})
+}
+
+// HTTPMetrics counts the requests and the latency of every route of a
+// server, Middleware should be added as middleware3 of the server.
+type HTTPMetrics struct {
+	Requests *Counter
+	Duration *Histogram
+	server   string
+}
+
+// NewHTTPMetrics creates the request metrics labeled with server=name.
+func NewHTTPMetrics(name string) *HTTPMetrics {
+	return &HTTPMetrics{
+		Requests: NewCounter("gmc_http_requests_total",
+			"Number of http requests by route and status code.", "server", "method", "route", "code"),
+		Duration: NewHistogram("gmc_http_request_duration_seconds",
+			"Time used by the route handler in seconds.", nil, "server", "method", "route"),
+		server: name,
+	}
+}
+
+// Middleware records the request, the route label is the matched route path
+// of controllers and APIs, it's empty for other requests, such as not found,
+// so the raw paths never blow up the series.
+func (m *HTTPMetrics) Middleware(ctx gcore.Ctx) (isStop bool) {
+	route := ctx.Param().MatchedRoutePath()
+	method := ctx.Request().Method
+	m.Requests.Inc(m.server, method, route, strconv.Itoa(ctx.StatusCode()))
+	m.Duration.Observe(ctx.TimeUsed().Seconds(), m.server, method, route)
+	return false
+}
+
+func (m *HTTPMetrics) Collect() []Family {
+	return append(m.Requests.Collect(), m.Duration.Collect()...)
+}
@@ -0,0 +1,319 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gmetrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefBuckets are the default histogram buckets in seconds, they fit the
+// latency of most http requests.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one line of the Prometheus text format, Name is the full name,
+// such as foo_bucket of histogram foo.
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a metric with its samples, Type is one of TypeCounter, TypeGauge
+// and TypeHistogram.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// NewFamily returns a gauge or counter Family named name.
+func NewFamily(name, help, typ string) *Family {
+	return &Family{Name: name, Help: help, Type: typ}
+}
+
+// Add appends a sample with the name of the family, labels are pairs of label
+// name and value.
+func (f *Family) Add(value float64, labels ...string) {
+	f.Samples = append(f.Samples, Sample{Name: f.Name, Labels: pairs(labels), Value: value})
+}
+
+func pairs(kv []string) (labels []Label) {
+	if len(kv)%2 != 0 {
+		panic(fmt.Sprintf("gmetrics: odd count of label pairs %v", kv))
+	}
+	for i := 0; i < len(kv); i += 2 {
+		labels = append(labels, Label{Name: kv[i], Value: kv[i+1]})
+	}
+	return
+}
+
+// Collector returns the families at scraping time.
+type Collector interface {
+	Collect() []Family
+}
+
+type CollectorFunc func() []Family
+
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+type series struct {
+	mu     sync.Mutex
+	labels []Label
+	value  float64
+	// used by histogram only.
+	counts []uint64
+	count  uint64
+}
+
+// vec holds the series of a metric, keyed by the label values.
+type vec struct {
+	name       string
+	help       string
+	labelNames []string
+	mu         sync.RWMutex
+	series     map[string]*series
+	newSeries  func() *series
+}
+
+func newVec(name, help string, labelNames []string) vec {
+	return vec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		series:     map[string]*series{},
+		newSeries:  func() *series { return &series{} },
+	}
+}
+
+func (v *vec) with(labelValues []string) *series {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("gmetrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.RLock()
+	s := v.series[key]
+	v.mu.RUnlock()
+	if s != nil {
+		return s
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s = v.series[key]; s == nil {
+		s = v.newSeries()
+		for i, name := range v.labelNames {
+			s.labels = append(s.labels, Label{Name: name, Value: labelValues[i]})
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *vec) get(labelValues []string) *series {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.series[strings.Join(labelValues, "\xff")]
+}
+
+// sorted returns the series sorted by label values, so the output is stable.
+func (v *vec) sorted() []*series {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	all := make([]*series, len(keys))
+	for i, k := range keys {
+		all[i] = v.series[k]
+	}
+	v.mu.RUnlock()
+	return all
+}
+
+func (v *vec) collect(typ string) []Family {
+	f := Family{Name: v.name, Help: v.help, Type: typ}
+	for _, s := range v.sorted() {
+		s.mu.Lock()
+		f.Samples = append(f.Samples, Sample{Name: v.name, Labels: s.labels, Value: s.value})
+		s.mu.Unlock()
+	}
+	return []Family{f}
+}
+
+// Counter is a value which only goes up, such as the count of requests.
+type Counter struct {
+	vec
+}
+
+// NewCounter creates a counter, the name should end with _total.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{vec: newVec(name, help, labelNames)}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the series of labelValues, v must not be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("gmetrics: counter %s can not decrease", c.name))
+	}
+	s := c.with(labelValues)
+	s.mu.Lock()
+	s.value += v
+	s.mu.Unlock()
+}
+
+func (c *Counter) Value(labelValues ...string) float64 {
+	s := c.get(labelValues)
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+func (c *Counter) Collect() []Family {
+	return c.collect(TypeCounter)
+}
+
+// Gauge is a value which can go up and down, such as the count of workers.
+type Gauge struct {
+	vec
+}
+
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{vec: newVec(name, help, labelNames)}
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	s := g.with(labelValues)
+	s.mu.Lock()
+	s.value = v
+	s.mu.Unlock()
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	s := g.with(labelValues)
+	s.mu.Lock()
+	s.value += v
+	s.mu.Unlock()
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *Gauge) Value(labelValues ...string) float64 {
+	s := g.get(labelValues)
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+func (g *Gauge) Collect() []Family {
+	return g.collect(TypeGauge)
+}
+
+// Histogram counts the observed values in buckets, such as the latency of
+// requests.
+type Histogram struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogram creates a histogram, buckets are the upper bounds, nil means
+// DefBuckets.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{vec: newVec(name, help, labelNames), buckets: buckets}
+	h.newSeries = func() *series {
+		return &series{counts: make([]uint64, len(buckets))}
+	}
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	s := h.with(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.mu.Lock()
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.count++
+	s.value += v
+	s.mu.Unlock()
+}
+
+// Count returns the count and sum of observed values.
+func (h *Histogram) Count(labelValues ...string) (count uint64, sum float64) {
+	s := h.get(labelValues)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count, s.value
+}
+
+func (h *Histogram) Collect() []Family {
+	f := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, s := range h.sorted() {
+		s.mu.Lock()
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			f.Samples = append(f.Samples, Sample{
+				Name:   h.name + "_bucket",
+				Labels: withLabel(s.labels, "le", formatFloat(upper)),
+				Value:  float64(cumulative),
+			})
+		}
+		f.Samples = append(f.Samples,
+			Sample{Name: h.name + "_bucket", Labels: withLabel(s.labels, "le", "+Inf"), Value: float64(s.count)},
+			Sample{Name: h.name + "_sum", Labels: s.labels, Value: s.value},
+			Sample{Name: h.name + "_count", Labels: s.labels, Value: float64(s.count)},
+		)
+		s.mu.Unlock()
+	}
+	return []Family{f}
+}
+
+func withLabel(labels []Label, name, value string) []Label {
+	l := make([]Label, len(labels), len(labels)+1)
+	copy(l, labels)
+	return append(l, Label{Name: name, Value: value})
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gmetrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pool struct{}
+
+func (pool) WorkerCount() int { return 3 }
+func (pool) Running() int     { return 1 }
+func (pool) Awaiting() int    { return 5 }
+
+func TestCounterAndGauge(t *testing.T) {
+	assert := assert.New(t)
+	c := NewCounter("foo_total", "foo", "code")
+	c.Inc("200")
+	c.Add(2, "200")
+	assert.Equal(float64(3), c.Value("200"))
+	assert.Equal(float64(0), c.Value("500"))
+	assert.Panics(func() { c.Add(-1, "200") })
+	assert.Panics(func() { c.Inc() })
+
+	g := NewGauge("bar", "bar")
+	g.Set(5)
+	g.Inc()
+	g.Dec()
+	g.Add(-2)
+	assert.Equal(float64(3), g.Value())
+}
+
+func TestHistogram(t *testing.T) {
+	assert := assert.New(t)
+	h := NewHistogram("latency_seconds", "latency", []float64{1, 0.1}, "route")
+	h.Observe(0.05, "/")
+	h.Observe(0.5, "/")
+	h.Observe(5, "/")
+	count, sum := h.Count("/")
+	assert.Equal(uint64(3), count)
+	assert.Equal(5.55, sum)
+	f := h.Collect()[0]
+	assert.Equal(TypeHistogram, f.Type)
+	assert.Len(f.Samples, 5)
+	assert.Equal([]Label{{"route", "/"}, {"le", "0.1"}}, f.Samples[0].Labels)
+	assert.Equal(float64(1), f.Samples[0].Value)
+	assert.Equal(float64(2), f.Samples[1].Value)
+	assert.Equal(float64(3), f.Samples[2].Value)
+	assert.Equal("latency_seconds_count", f.Samples[4].Name)
+}
+
+func TestRegistry_WriteTo(t *testing.T) {
+	assert := assert.New(t)
+	r := New()
+	c := NewCounter("requests_total", "Requests\ncount.", "path")
+	c.Inc(`/a"b\`)
+	r.Register("requests", c)
+	r.Register("pool.a", PoolCollector("a", pool{}))
+	r.Register("pool.b", PoolCollector("b", pool{}))
+	r.Register("empty", NewGauge("empty", "empty"))
+	buf := &bytes.Buffer{}
+	n, err := r.WriteTo(buf)
+	assert.Nil(err)
+	assert.Equal(int64(buf.Len()), n)
+	out := buf.String()
+	assert.Contains(out, "# HELP requests_total Requests\\ncount.\n# TYPE requests_total counter\n"+
+		`requests_total{path="/a\"b\\"} 1`+"\n")
+	assert.Contains(out, "gmc_gpool_queued_tasks{pool=\"a\"} 5\ngmc_gpool_queued_tasks{pool=\"b\"} 5\n")
+	assert.Equal(1, strings.Count(out, "# TYPE gmc_gpool_workers gauge"))
+	assert.NotContains(out, "empty")
+	assert.True(strings.Index(out, "gmc_gpool_workers") < strings.Index(out, "requests_total"))
+
+	r.Unregister("pool.b")
+	assert.Len(r.Gather(), 5)
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	assert := assert.New(t)
+	w := httptest.NewRecorder()
+	Default.Handler(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(w.Body.String(), `go_info{version="`)
+	assert.Contains(w.Body.String(), "# TYPE go_goroutines gauge\n")
+}
@@ -0,0 +1,188 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gmetrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// ContentType is the content type of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default is the registry used by the package functions, the runtime metrics
+// are registered in it, the servers, databases and caches of the app are
+// registered in it too.
+var Default = New()
+
+func init() {
+	Default.Register("go", RuntimeCollector())
+}
+
+// Registry holds the named collectors.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+func New() *Registry {
+	return &Registry{
+		collectors: map[string]Collector{},
+	}
+}
+
+// Register adds collector named name, the collector with same name is
+// replaced. Different collectors can output the families with same name, such
+// as the connections of several servers, they are merged in Gather.
+func (r *Registry) Register(name string, c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors[name] = c
+}
+
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.collectors, name)
+}
+
+// Gather collects the families of all collectors sorted by name, the
+// families with same name are merged, the help and type of the first one is
+// used.
+func (r *Registry) Gather() (families []Family) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]Collector, len(names))
+	for i, name := range names {
+		collectors[i] = r.collectors[name]
+	}
+	r.mu.RUnlock()
+
+	index := map[string]int{}
+	for _, c := range collectors {
+		for _, f := range c.Collect() {
+			if i, ok := index[f.Name]; ok {
+				families[i].Samples = append(families[i].Samples, f.Samples...)
+				continue
+			}
+			index[f.Name] = len(families)
+			families = append(families, f)
+		}
+	}
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return
+}
+
+// WriteTo writes the families in the Prometheus text format to w.
+func (r *Registry) WriteTo(w io.Writer) (n int64, err error) {
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range r.Gather() {
+		if len(f.Samples) == 0 {
+			continue
+		}
+		if f.Help != "" {
+			bw.WriteString("# HELP " + f.Name + " " + helpReplacer.Replace(f.Help) + "\n")
+		}
+		bw.WriteString("# TYPE " + f.Name + " " + f.Type + "\n")
+		for _, s := range f.Samples {
+			bw.WriteString(s.Name)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(l.Name + `="` + labelReplacer.Replace(l.Value) + `"`)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatFloat(s.Value) + "\n")
+		}
+	}
+	err = bw.Flush()
+	return cw.n, err
+}
+
+// Handler outputs the metrics in the Prometheus text format.
+func (r *Registry) Handler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	r.WriteTo(w)
+}
+
+// BindRouter binds Handler to `router`, path default is /metrics.
+func (r *Registry) BindRouter(router gcore.HTTPRouter, path string) {
+	if path == "" {
+		path = "/metrics"
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	router.HandlerFunc(http.MethodGet, path, r.Handler)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return
+}
+
+// Register adds collector to Default.
+func Register(name string, c Collector) {
+	Default.Register(name, c)
+}
+
+// Unregister removes collector from Default.
+func Unregister(name string) {
+	Default.Unregister(name)
+}
+
+// Gather collects the families of Default.
+func Gather() []Family {
+	return Default.Gather()
+}
+
+// BindRouter binds the handler of Default to router.
+func BindRouter(router gcore.HTTPRouter, path string) {
+	Default.BindRouter(router, path)
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// APIKeyLookup returns the identity of the API key by its hash, see
+// HashAPIKey, the identity is nil if the key is not found.
+type APIKeyLookup func(hash string) (id *gcore.Identity, err error)
+
+// HashAPIKey returns the hex sha256 of key, the keys are stored and looked
+// up by their hashes, so the stolen store does not leak the keys.
+func HashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// CacheAPIKeyLookup looks up the keys in c, the key of cache is prefix and
+// the hash of API key, the value is the JSON of gcore.Identity, such as
+// `{"subject":"app1","scopes":["user:read"]}`.
+func CacheAPIKeyLookup(c gcore.Cache, prefix string) APIKeyLookup {
+	return func(hash string) (id *gcore.Identity, err error) {
+		ok, err := c.Has(prefix + hash)
+		if err != nil || !ok {
+			return
+		}
+		v, err := c.Get(prefix + hash)
+		if err != nil {
+			return
+		}
+		id = &gcore.Identity{}
+		if err = json.Unmarshal([]byte(v), id); err != nil {
+			return nil, err
+		}
+		return
+	}
+}
+
+// DBAPIKeyLookup looks up the keys in table of db, the columns of table are
+// `key_hash`, `subject`, `scopes` and `roles`, scopes and roles are comma
+// separated.
+func DBAPIKeyLookup(db gcore.Database, table string) APIKeyLookup {
+	return func(hash string) (id *gcore.Identity, err error) {
+		rs, err := db.Query(db.AR().From(table).Where(map[string]interface{}{
+			"key_hash": hash,
+		}).Limit(0, 1))
+		if err != nil || rs.Len() == 0 {
+			return
+		}
+		row := rs.Row()
+		return &gcore.Identity{
+			Subject: row["subject"],
+			Scopes:  splitList(row["scopes"]),
+			Roles:   splitList(row["roles"]),
+		}, nil
+	}
+}
+
+func splitList(s string) (list []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return
+}
+
+// APIKeyConfig is the options of APIKey.
+type APIKeyConfig struct {
+	// Header is the request header of key.
+	Header string
+	// Query is the query parameter of key, empty disables it.
+	Query string
+	// Realm is the realm of WWW-Authenticate header.
+	Realm string
+}
+
+// NewAPIKeyConfig returns the default options of APIKey.
+func NewAPIKeyConfig() *APIKeyConfig {
+	return &APIKeyConfig{
+		Header: "X-API-Key",
+	}
+}
+
+// APIKey authenticates the requests with an API key looked up by lookup.
+type APIKey struct {
+	config *APIKeyConfig
+	lookup APIKeyLookup
+}
+
+// NewAPIKey creates an APIKey by c.
+func NewAPIKey(c *APIKeyConfig, lookup APIKeyLookup) *APIKey {
+	return &APIKey{
+		config: c,
+		lookup: lookup,
+	}
+}
+
+// Name implements Authenticator.
+func (a *APIKey) Name() string {
+	return "apikey"
+}
+
+// Challenge implements Authenticator.
+func (a *APIKey) Challenge() string {
+	return `APIKey realm="` + a.config.Realm + `"`
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKey) Authenticate(ctx gcore.Ctx) (id *gcore.Identity, err error) {
+	key := ctx.Header(a.config.Header)
+	if key == "" && a.config.Query != "" {
+		key = ctx.Request().URL.Query().Get(a.config.Query)
+	}
+	if key == "" {
+		return nil, nil
+	}
+	id, err = a.lookup(HashAPIKey(key))
+	if err != nil {
+		return
+	}
+	if id == nil {
+		return nil, ErrInvalidCredential
+	}
+	id.Method = a.Name()
+	return
+}
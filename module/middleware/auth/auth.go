@@ -0,0 +1,347 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package auth
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gcache "github.com/snail007/gmc/module/cache"
+	gdb "github.com/snail007/gmc/module/db"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+var (
+	// ErrNoCredential is the error of the requests without credential, the
+	// response is 401.
+	ErrNoCredential = errors.New("credential required")
+	// ErrInvalidCredential is the error of the invalid credentials, the
+	// response is 401.
+	ErrInvalidCredential = errors.New("invalid credential")
+	// ErrExpiredCredential is the error of the expired credentials, the
+	// response is 401.
+	ErrExpiredCredential = errors.New("credential expired")
+	// ErrInsufficientScope is the error of the identities without the
+	// required scopes, the response is 403.
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// Authenticator authenticates the requests by a kind of credential.
+type Authenticator interface {
+	// Name is the name of authenticator, it's the Method of identity.
+	Name() string
+	// Challenge is the WWW-Authenticate header of 401 responses.
+	Challenge() string
+	// Authenticate returns the identity of request, nil identity and nil
+	// error means the request has no credential of the authenticator.
+	Authenticate(ctx gcore.Ctx) (id *gcore.Identity, err error)
+}
+
+// Requirement is the authentication required by a group or a route.
+type Requirement struct {
+	// Authenticators is the names of authenticators tried in order, empty
+	// means all.
+	Authenticators []string
+	// Scopes is the scopes the identity must be granted all.
+	Scopes []string
+	// Optional allows the requests without credential, the identity is set
+	// if the request has a valid one.
+	Optional bool
+}
+
+type rule struct {
+	pattern     string
+	requirement *Requirement
+}
+
+// Auth authenticates the requests of the groups and routes required, the
+// identity is stored in Ctx by gcore.IdentityKey, see IdentityOf. The
+// requirement of the first matched route is used, otherwise the one of the
+// longest matched group path, the requests matched neither are skipped.
+type Auth struct {
+	authenticators []Authenticator
+	groups         []rule
+	routes         []rule
+	errorHandler   func(ctx gcore.Ctx, status int, err error)
+}
+
+// New creates an Auth with the authenticators.
+func New(authenticators ...Authenticator) *Auth {
+	return &Auth{
+		authenticators: authenticators,
+	}
+}
+
+// IdentityOf returns the identity of request, nil if it's not
+// authenticated.
+func IdentityOf(ctx gcore.Ctx) *gcore.Identity {
+	if v, ok := ctx.Get(gcore.IdentityKey); ok {
+		return v.(*gcore.Identity)
+	}
+	return nil
+}
+
+// Add adds the authenticators.
+func (a *Auth) Add(authenticators ...Authenticator) *Auth {
+	a.authenticators = append(a.authenticators, authenticators...)
+	return a
+}
+
+// Group requires r for the requests path is path or under it, such as the
+// namespace of a router group, `api.Group("/v1").Router().Namespace()`,
+// `/v1` matches `/v1/user` but not `/v10`.
+func (a *Auth) Group(path string, r *Requirement) *Auth {
+	a.groups = append(a.groups, rule{pattern: path, requirement: r})
+	sort.SliceStable(a.groups, func(i, j int) bool {
+		return len(a.groups[i].pattern) > len(a.groups[j].pattern)
+	})
+	return a
+}
+
+// Route requires r for the requests matched route, a route path with an
+// optional method prefix, such as `DELETE /v1/user/:id`.
+func (a *Auth) Route(route string, r *Requirement) *Auth {
+	a.routes = append(a.routes, rule{pattern: route, requirement: r})
+	return a
+}
+
+// SetErrorHandler sets the handler called if the authentication fails,
+// status is 401 or 403, or 500 for the errors of authenticators. The default
+// one responds the status, with the WWW-Authenticate headers for 401.
+func (a *Auth) SetErrorHandler(fn func(ctx gcore.Ctx, status int, err error)) {
+	a.errorHandler = fn
+}
+
+func (a *Auth) match(r *http.Request) *Requirement {
+	for _, v := range a.routes {
+		if ghttputil.MatchRoute(v.pattern, r.Method, r.URL.Path) {
+			return v.requirement
+		}
+	}
+	for _, v := range a.groups {
+		if ghttputil.MatchPathPrefix(v.pattern, r.URL.Path) {
+			return v.requirement
+		}
+	}
+	return nil
+}
+
+func (a *Auth) authenticatorsOf(r *Requirement) (list []Authenticator) {
+	if len(r.Authenticators) == 0 {
+		return a.authenticators
+	}
+	for _, name := range r.Authenticators {
+		for _, v := range a.authenticators {
+			if v.Name() == name {
+				list = append(list, v)
+			}
+		}
+	}
+	return
+}
+
+// Handle is a middleware1 authenticates the request, it should be added by
+// AddMiddleware1, so the requests not found are not authenticated.
+func (a *Auth) Handle(ctx gcore.Ctx) (isStop bool) {
+	r := a.match(ctx.Request())
+	if r == nil {
+		return false
+	}
+	authenticators := a.authenticatorsOf(r)
+	var id *gcore.Identity
+	var err error
+	for _, v := range authenticators {
+		if id, err = v.Authenticate(ctx); err != nil || id != nil {
+			break
+		}
+	}
+	if err == nil && id == nil && !r.Optional {
+		err = ErrNoCredential
+	}
+	if err != nil {
+		status := http.StatusUnauthorized
+		switch err {
+		case ErrNoCredential, ErrInvalidCredential, ErrExpiredCredential:
+		default:
+			status = http.StatusInternalServerError
+		}
+		a.fail(ctx, authenticators, status, err)
+		return true
+	}
+	if id == nil {
+		return false
+	}
+	ctx.Set(gcore.IdentityKey, id)
+	if !id.HasScope(r.Scopes...) {
+		a.fail(ctx, authenticators, http.StatusForbidden, ErrInsufficientScope)
+		return true
+	}
+	return false
+}
+
+func (a *Auth) fail(ctx gcore.Ctx, authenticators []Authenticator, status int, err error) {
+	if status == http.StatusUnauthorized {
+		for _, v := range authenticators {
+			ctx.Response().Header().Add("WWW-Authenticate", v.Challenge())
+		}
+	}
+	if a.errorHandler != nil {
+		a.errorHandler(ctx, status, err)
+		return
+	}
+	ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+	ctx.WriteHeader(status)
+	if status == http.StatusInternalServerError {
+		ctx.Write(http.StatusText(status))
+		return
+	}
+	ctx.Write(http.StatusText(status) + ", " + err.Error())
+}
+
+// NewFromConfig creates an Auth from section [auth] in app.toml. The cache
+// or db of apikey store must be initialized before calling it.
+func NewFromConfig(c gcore.Config) (a *Auth, err error) {
+	a = New()
+	sub := c.Sub("auth")
+	if sub == nil {
+		return
+	}
+	realm := sub.GetString("realm")
+	if sub.GetBool("jwt.enable") {
+		cfg := NewJWTConfig()
+		cfg.Realm = realm
+		if v := sub.GetString("jwt.header"); v != "" {
+			cfg.Header = v
+		}
+		cfg.Query = sub.GetString("jwt.query")
+		cfg.Issuer = sub.GetString("jwt.issuer")
+		cfg.Audience = sub.GetString("jwt.audience")
+		if sub.IsSet("jwt.leeway") {
+			cfg.Leeway = time.Duration(sub.GetInt("jwt.leeway")) * time.Second
+		}
+		cfg.JWKSFile = sub.GetString("jwt.jwksfile")
+		if sub.IsSet("jwt.jwksreload") {
+			cfg.JWKSReload = time.Duration(sub.GetInt("jwt.jwksreload")) * time.Second
+		}
+		var keys []*JWTKey
+		if keys, err = jwtKeysFromConfig(sub.Get("jwt.keys")); err != nil {
+			return nil, err
+		}
+		var j *JWT
+		if j, err = NewJWT(cfg, keys...); err != nil {
+			return nil, err
+		}
+		a.Add(j)
+	}
+	if sub.GetBool("apikey.enable") {
+		cfg := NewAPIKeyConfig()
+		cfg.Realm = realm
+		if v := sub.GetString("apikey.header"); v != "" {
+			cfg.Header = v
+		}
+		cfg.Query = sub.GetString("apikey.query")
+		var lookup APIKeyLookup
+		if lookup, err = apiKeyLookupFromConfig(sub); err != nil {
+			return nil, err
+		}
+		a.Add(NewAPIKey(cfg, lookup))
+	}
+	if sub.GetBool("basic.enable") {
+		a.Add(NewBasic(realm, BasicUsers(sub.GetStringMapString("basic.users"))))
+	}
+	groups, _ := sub.Get("groups").([]interface{})
+	for _, v := range groups {
+		m := gcast.ToStringMap(v)
+		a.Group(gcast.ToString(m["path"]), requirementFromMap(m))
+	}
+	routes, _ := sub.Get("routes").([]interface{})
+	for _, v := range routes {
+		m := gcast.ToStringMap(v)
+		a.Route(gcast.ToString(m["route"]), requirementFromMap(m))
+	}
+	return
+}
+
+// apiKeyLookupFromConfig returns the lookup of [auth.apikey] store, memory
+// and redis are the cache of cacheid, mysql and sqlite3 are the db of dbid.
+func apiKeyLookupFromConfig(sub gcore.SubConfig) (lookup APIKeyLookup, err error) {
+	cacheID := sub.GetString("apikey.cacheid")
+	if cacheID == "" {
+		cacheID = "default"
+	}
+	dbID := sub.GetString("apikey.dbid")
+	if dbID == "" {
+		dbID = "default"
+	}
+	prefix := sub.GetString("apikey.prefix")
+	table := sub.GetString("apikey.table")
+	store := sub.GetString("apikey.store")
+	switch store {
+	case "memory":
+		if c := gcache.Memory(cacheID); c != nil {
+			lookup = CacheAPIKeyLookup(c, prefix)
+		}
+	case "redis":
+		if c := gcache.Redis(cacheID); c != nil {
+			lookup = CacheAPIKeyLookup(c, prefix)
+		}
+	case "mysql":
+		if db := gdb.DBMySQL(dbID); db != nil {
+			lookup = DBAPIKeyLookup(db, table)
+		}
+	case "sqlite3":
+		if db := gdb.DBSQLite3(dbID); db != nil {
+			lookup = DBAPIKeyLookup(db, table)
+		}
+	default:
+		return nil, gcore.Providers.Error("")().New("unknown auth apikey store: " + store)
+	}
+	if lookup == nil {
+		return nil, gcore.Providers.Error("")().New("auth apikey store " + store + " is not initialized")
+	}
+	return
+}
+
+func requirementFromMap(m map[string]interface{}) *Requirement {
+	return &Requirement{
+		Authenticators: gcast.ToStringSlice(m["authenticators"]),
+		Scopes:         gcast.ToStringSlice(m["scopes"]),
+		Optional:       gcast.ToBool(m["optional"]),
+	}
+}
+
+// jwtKeysFromConfig parses the [[auth.jwt.keys]] items, the key is the
+// `secret` for HMAC, or the PEM `file` for RSA and ECDSA.
+func jwtKeysFromConfig(v interface{}) (keys []*JWTKey, err error) {
+	items, _ := v.([]interface{})
+	for _, item := range items {
+		m := gcast.ToStringMap(item)
+		k := &JWTKey{
+			ID:        gcast.ToString(m["kid"]),
+			Algorithm: gcast.ToString(m["alg"]),
+		}
+		if file := gcast.ToString(m["file"]); file != "" {
+			var b []byte
+			if b, err = ioutil.ReadFile(file); err != nil {
+				return
+			}
+			if k.Key, err = ParsePEMKey(b); err != nil {
+				return
+			}
+		} else if secret := gcast.ToString(m["secret"]); secret != "" {
+			k.Key = []byte(secret)
+		} else {
+			return nil, gcore.Providers.Error("")().New("auth jwt key " + k.ID + " has no secret or file")
+		}
+		keys = append(keys, k)
+	}
+	return
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gcache "github.com/snail007/gmc/module/cache"
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	gerror "github.com/snail007/gmc/module/error"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	providers := gcore.Providers
+	providers.RegisterConfig("", func() gcore.Config {
+		return gconfig.NewConfig()
+	})
+	providers.RegisterError("", func() gcore.Error {
+		return gerror.New()
+	})
+	os.Exit(m.Run())
+}
+
+func mockCtx(method, uri string, header ...string) (gcore.Ctx, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "http://example.com"+uri, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	return gctx.NewCtx().CloneWithHTTP(ghttputil.NewResponseWriter(w), r), w
+}
+
+func mockAuth() *Auth {
+	cache := gcache.NewMemCache(gcache.NewMemCacheConfig())
+	cache.Set("apikey:"+HashAPIKey("key1"), `{"subject":"app1","scopes":["user:read"]}`, time.Minute)
+	j, _ := NewJWT(NewJWTConfig(), &JWTKey{ID: "k1", Key: []byte("secret")})
+	return New(j,
+		NewAPIKey(NewAPIKeyConfig(), CacheAPIKeyLookup(cache, "apikey:")),
+		NewBasic("gmc", BasicUsers(map[string]string{"admin": "123"})),
+	)
+}
+
+func TestAuth_Handle(t *testing.T) {
+	assert := assert.New(t)
+	a := mockAuth()
+	a.Group("/api/", &Requirement{})
+	a.Group("/api/admin/", &Requirement{Authenticators: []string{"basic"}})
+	a.Group("/public/", &Requirement{Optional: true})
+	a.Group("/v1", &Requirement{})
+	a.Route("DELETE /api/user/:id", &Requirement{Scopes: []string{"user:write"}})
+	token, err := SignJWT(map[string]interface{}{
+		"sub":   "u1",
+		"scope": "user:read user:write",
+		"roles": []string{"admin"},
+	}, "HS256", "k1", []byte("secret"))
+	assert.Nil(err)
+
+	for _, v := range []struct {
+		method, uri string
+		header      []string
+		stop        bool
+		status      int
+		subject     string
+	}{
+		{"GET", "/index", nil, false, 200, ""},
+		{"GET", "/public/a", nil, false, 200, ""},
+		{"GET", "/public/a", []string{"X-API-Key", "key1"}, false, 200, "app1"},
+		{"GET", "/public/a", []string{"X-API-Key", "key2"}, true, 401, ""},
+		{"GET", "/api/user", nil, true, 401, ""},
+		{"GET", "/api/user", []string{"Authorization", "Bearer " + token}, false, 200, "u1"},
+		{"GET", "/api/user", []string{"Authorization", "Bearer " + token + "x"}, true, 401, ""},
+		{"GET", "/api/user", []string{"X-API-Key", "key1"}, false, 200, "app1"},
+		{"DELETE", "/api/user/1", []string{"X-API-Key", "key1"}, true, 403, "app1"},
+		{"DELETE", "/api/user/1", []string{"Authorization", "Bearer " + token}, false, 200, "u1"},
+		{"GET", "/api/admin/user", []string{"Authorization", "Bearer " + token}, true, 401, ""},
+		{"GET", "/api/admin/user", []string{"Authorization", "Basic YWRtaW46MTIz"}, false, 200, "admin"},
+		{"GET", "/api/admin/user", []string{"Authorization", "Basic YWRtaW46MTI0"}, true, 401, ""},
+		{"GET", "/v1", nil, true, 401, ""},
+		{"GET", "/v1/user", nil, true, 401, ""},
+		{"GET", "/v10/user", nil, false, 200, ""},
+		{"GET", "/v1admin", nil, false, 200, ""},
+	} {
+		ctx, w := mockCtx(v.method, v.uri, v.header...)
+		assert.Equal(v.stop, a.Handle(ctx), v.method+v.uri)
+		assert.Equal(v.status, w.Code, v.method+v.uri)
+		id := IdentityOf(ctx)
+		if v.subject == "" {
+			assert.Nil(id, v.method+v.uri)
+		} else if assert.NotNil(id, v.method+v.uri) {
+			assert.Equal(v.subject, id.Subject)
+		}
+	}
+
+	ctx, w := mockCtx("GET", "/api/user")
+	a.Handle(ctx)
+	assert.Equal([]string{`Bearer realm=""`, `APIKey realm=""`, `Basic realm="gmc", charset="UTF-8"`}, w.Header()["Www-Authenticate"])
+	assert.Equal("Unauthorized, credential required", w.Body.String())
+	ctx, w = mockCtx("GET", "/api/admin/user")
+	a.Handle(ctx)
+	assert.Equal([]string{`Basic realm="gmc", charset="UTF-8"`}, w.Header()["Www-Authenticate"])
+	ctx, _ = mockCtx("GET", "/api/user", "Authorization", "Bearer "+token)
+	a.Handle(ctx)
+	assert.Equal([]string{"admin"}, IdentityOf(ctx).Roles)
+	assert.Equal("jwt", IdentityOf(ctx).Method)
+}
+
+func TestAuth_ErrorHandler(t *testing.T) {
+	assert := assert.New(t)
+	a := New(NewAPIKey(NewAPIKeyConfig(), func(hash string) (*gcore.Identity, error) {
+		return nil, errors.New("store error")
+	})).Group("/", &Requirement{Scopes: []string{"a"}})
+	ctx, w := mockCtx("GET", "/", "X-API-Key", "key1")
+	assert.True(a.Handle(ctx))
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.Equal("Internal Server Error", w.Body.String())
+
+	var status []int
+	a.SetErrorHandler(func(ctx gcore.Ctx, s int, err error) {
+		status = append(status, s)
+		ctx.WriteHeader(http.StatusTeapot)
+	})
+	ctx, w = mockCtx("GET", "/")
+	assert.True(a.Handle(ctx))
+	assert.Equal(http.StatusTeapot, w.Code)
+	assert.Equal([]int{401}, status)
+}
+
+func TestBasicUsers(t *testing.T) {
+	assert := assert.New(t)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("456"), bcrypt.MinCost)
+	v := BasicUsers(map[string]string{"a": "123", "b": string(hash)})
+	for _, c := range []struct {
+		user, password string
+		ok             bool
+	}{
+		{"a", "123", true},
+		{"a", "456", false},
+		{"b", "456", true},
+		{"b", string(hash), false},
+		{"c", "123", false},
+	} {
+		id, err := v(c.user, c.password)
+		assert.Nil(err)
+		assert.Equal(c.ok, id != nil, c.user+c.password)
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.NewConfig()
+	cfg.SetConfigType("toml")
+	f, err := os.Open("config.toml")
+	assert.Nil(err)
+	defer f.Close()
+	assert.Nil(cfg.ReadConfig(f))
+	a, err := NewFromConfig(cfg)
+	assert.Nil(err)
+	assert.Len(a.authenticators, 0)
+
+	cfg.Set("auth.jwt.enable", true)
+	cfg.Set("auth.jwt.keys", []interface{}{map[string]interface{}{"kid": "k1", "alg": "HS256"}})
+	_, err = NewFromConfig(cfg)
+	assert.NotNil(err)
+	cfg.Set("auth.jwt.keys", []interface{}{map[string]interface{}{"kid": "k1", "alg": "HS256", "secret": "s"}})
+	cfg.Set("auth.apikey.enable", true)
+	_, err = NewFromConfig(cfg)
+	assert.NotNil(err)
+	cfg.Set("auth.apikey.enable", false)
+	cfg.Set("auth.basic.enable", true)
+	cfg.Set("auth.basic.users", map[string]interface{}{"admin": "123"})
+	cfg.Set("auth.groups", []interface{}{map[string]interface{}{"path": "/api/", "authenticators": []interface{}{"basic"}}})
+	cfg.Set("auth.routes", []interface{}{map[string]interface{}{"route": "GET /user/:id", "scopes": []interface{}{"a"}}})
+	a, err = NewFromConfig(cfg)
+	assert.Nil(err)
+	assert.Len(a.authenticators, 2)
+	ctx, w := mockCtx("GET", "/api/a", "Authorization", "Basic YWRtaW46MTIz")
+	assert.False(a.Handle(ctx))
+	assert.Equal("admin", IdentityOf(ctx).Subject)
+	ctx, w = mockCtx("GET", "/user/1", "Authorization", "Basic YWRtaW46MTIz")
+	assert.True(a.Handle(ctx))
+	assert.Equal(http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicValidator returns the identity of the user and password, the
+// identity is nil if they mismatch.
+type BasicValidator func(user, password string) (id *gcore.Identity, err error)
+
+// BasicUsers validates the users in users, the key is the user name, the
+// value is the password, or its bcrypt hash starts with `$2`.
+func BasicUsers(users map[string]string) BasicValidator {
+	return func(user, password string) (id *gcore.Identity, err error) {
+		p, ok := users[user]
+		if !ok {
+			return
+		}
+		if strings.HasPrefix(p, "$2") {
+			if bcrypt.CompareHashAndPassword([]byte(p), []byte(password)) != nil {
+				return
+			}
+		} else if subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			return
+		}
+		return &gcore.Identity{Subject: user}, nil
+	}
+}
+
+// Basic authenticates the requests with HTTP Basic authentication.
+type Basic struct {
+	realm    string
+	validate BasicValidator
+}
+
+// NewBasic creates a Basic, realm is the realm of WWW-Authenticate header.
+func NewBasic(realm string, validate BasicValidator) *Basic {
+	return &Basic{
+		realm:    realm,
+		validate: validate,
+	}
+}
+
+// Name implements Authenticator.
+func (b *Basic) Name() string {
+	return "basic"
+}
+
+// Challenge implements Authenticator.
+func (b *Basic) Challenge() string {
+	return `Basic realm="` + b.realm + `", charset="UTF-8"`
+}
+
+// Authenticate implements Authenticator.
+func (b *Basic) Authenticate(ctx gcore.Ctx) (id *gcore.Identity, err error) {
+	user, password, ok := ctx.Request().BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	id, err = b.validate(user, password)
+	if err != nil {
+		return
+	}
+	if id == nil {
+		return nil, ErrInvalidCredential
+	}
+	id.Method = b.Name()
+	return
+}
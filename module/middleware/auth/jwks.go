@@ -0,0 +1,110 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses a JSON Web Key Set, the `oct`, `RSA` and `EC` keys are
+// supported, the keys of `use` other than `sig` are skipped.
+func ParseJWKS(data []byte) (keys []*JWTKey, err error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return
+	}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key := &JWTKey{ID: k.Kid, Algorithm: k.Alg}
+		switch k.Kty {
+		case "oct":
+			key.Key, err = base64.RawURLEncoding.DecodeString(k.K)
+		case "RSA":
+			var n, e *big.Int
+			if n, err = decodeBigInt(k.N); err == nil {
+				if e, err = decodeBigInt(k.E); err == nil {
+					key.Key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+				}
+			}
+		case "EC":
+			curve, ok := map[string]elliptic.Curve{
+				"P-256": elliptic.P256(),
+				"P-384": elliptic.P384(),
+				"P-521": elliptic.P521(),
+			}[k.Crv]
+			if !ok {
+				return nil, gcore.Providers.Error("")().New("unsupported jwk crv: " + k.Crv)
+			}
+			var x, y *big.Int
+			if x, err = decodeBigInt(k.X); err == nil {
+				if y, err = decodeBigInt(k.Y); err == nil {
+					key.Key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+				}
+			}
+		default:
+			return nil, gcore.Providers.Error("")().New("unsupported jwk kty: " + k.Kty)
+		}
+		if err != nil {
+			return nil, gcore.Providers.Error("")().New("parse jwk " + k.Kid + " error: " + err.Error())
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// ParsePEMKey parses a PEM encoded RSA or ECDSA public key, or a
+// certificate.
+func ParsePEMKey(data []byte) (key interface{}, err error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, gcore.Providers.Error("")().New("no pem data found")
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
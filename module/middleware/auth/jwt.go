@@ -0,0 +1,399 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+var jwtHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// JWTKey is a key of JWT, Key is a []byte secret for HS256, HS384 and
+// HS512, a *rsa.PublicKey for RS256, RS384 and RS512, or a
+// *ecdsa.PublicKey for ES256, ES384 and ES512, the private keys can be used
+// too.
+type JWTKey struct {
+	// ID is the `kid` of key, the token with a kid is only verified by the
+	// key of the same ID, the one without kid is verified by all the keys.
+	ID string
+	// Algorithm limits the `alg` of token, empty means any algorithm of the
+	// key type.
+	Algorithm string
+	Key       interface{}
+}
+
+func (k *JWTKey) verify(alg string, signed, sig []byte) bool {
+	if len(alg) != 5 || (k.Algorithm != "" && k.Algorithm != alg) {
+		return false
+	}
+	hash, ok := jwtHashes[alg[2:]]
+	if !ok {
+		return false
+	}
+	switch alg[:2] {
+	case "HS":
+		secret, ok := k.Key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write(signed)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case "RS":
+		var pub *rsa.PublicKey
+		switch v := k.Key.(type) {
+		case *rsa.PublicKey:
+			pub = v
+		case *rsa.PrivateKey:
+			pub = &v.PublicKey
+		default:
+			return false
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest(hash, signed), sig) == nil
+	case "ES":
+		var pub *ecdsa.PublicKey
+		switch v := k.Key.(type) {
+		case *ecdsa.PublicKey:
+			pub = v
+		case *ecdsa.PrivateKey:
+			pub = &v.PublicKey
+		default:
+			return false
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, digest(hash, signed), r, s)
+	}
+	return false
+}
+
+func digest(hash crypto.Hash, b []byte) []byte {
+	h := hash.New()
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// SignJWT returns a JWT of claims signed by key with alg, the same
+// algorithms as JWTKey are supported, RS* and ES* require a private key.
+func SignJWT(claims map[string]interface{}, alg, kid string, key interface{}) (token string, err error) {
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	if len(alg) != 5 || jwtHashes[alg[2:]] == 0 {
+		return "", gcore.Providers.Error("")().New("unsupported jwt alg: " + alg)
+	}
+	hash := jwtHashes[alg[2:]]
+	var sig []byte
+	switch v := key.(type) {
+	case []byte:
+		if alg[:2] == "HS" {
+			mac := hmac.New(hash.New, v)
+			mac.Write([]byte(signed))
+			sig = mac.Sum(nil)
+		}
+	case *rsa.PrivateKey:
+		if alg[:2] == "RS" {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, v, hash, digest(hash, []byte(signed)))
+		}
+	case *ecdsa.PrivateKey:
+		if alg[:2] == "ES" {
+			var r, s *big.Int
+			r, s, err = ecdsa.Sign(rand.Reader, v, digest(hash, []byte(signed)))
+			if err == nil {
+				size := (v.Curve.Params().BitSize + 7) / 8
+				sig = make([]byte, 2*size)
+				rb, sb := r.Bytes(), s.Bytes()
+				copy(sig[size-len(rb):size], rb)
+				copy(sig[2*size-len(sb):], sb)
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+	if sig == nil {
+		return "", gcore.Providers.Error("")().New("the key mismatches jwt alg: " + alg)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// JWTConfig is the options of JWT.
+type JWTConfig struct {
+	// Header is the request header of token, the value is `Bearer <token>`.
+	Header string
+	// Query is the query parameter of token, empty disables it.
+	Query string
+	// Issuer is the required `iss` of token, empty means any.
+	Issuer string
+	// Audience is the required `aud` of token, empty means any.
+	Audience string
+	// Leeway is the clock skew allowed in checking `exp` and `nbf`.
+	Leeway time.Duration
+	// JWKSFile is a JSON Web Key Set file, its keys are added to the keys,
+	// and it's reloaded if modified, checked every JWKSReload.
+	JWKSFile   string
+	JWKSReload time.Duration
+	// Realm is the realm of WWW-Authenticate header.
+	Realm string
+}
+
+// NewJWTConfig returns the default options of JWT.
+func NewJWTConfig() *JWTConfig {
+	return &JWTConfig{
+		Header:     "Authorization",
+		Leeway:     time.Minute,
+		JWKSReload: time.Minute,
+	}
+}
+
+// JWT authenticates the requests with a bearer JSON Web Token signed by
+// HMAC, RSA or ECDSA. The `sub` claim is the Subject of identity, the
+// `scope` claim, a space separated string, or the `scp` and `scopes` claims,
+// arrays, are the Scopes, the `roles` claim is the Roles.
+type JWT struct {
+	config    *JWTConfig
+	keys      []*JWTKey
+	jwksKeys  []*JWTKey
+	jwksMod   time.Time
+	jwksCheck time.Time
+	lock      sync.RWMutex
+}
+
+// NewJWT creates a JWT by c, the JWKSFile of c is loaded if set.
+func NewJWT(c *JWTConfig, keys ...*JWTKey) (j *JWT, err error) {
+	j = &JWT{
+		config: c,
+		keys:   keys,
+	}
+	if c.JWKSFile != "" {
+		if err = j.loadJWKS(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// Name implements Authenticator.
+func (j *JWT) Name() string {
+	return "jwt"
+}
+
+// Challenge implements Authenticator.
+func (j *JWT) Challenge() string {
+	return `Bearer realm="` + j.config.Realm + `"`
+}
+
+// AddKey adds the keys, it's used to rotate keys with RemoveKey.
+func (j *JWT) AddKey(keys ...*JWTKey) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.keys = append(append([]*JWTKey{}, j.keys...), keys...)
+}
+
+// RemoveKey removes the keys of the IDs.
+func (j *JWT) RemoveKey(ids ...string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	var keys []*JWTKey
+	for _, k := range j.keys {
+		removed := false
+		for _, id := range ids {
+			if k.ID == id {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			keys = append(keys, k)
+		}
+	}
+	j.keys = keys
+}
+
+// loadJWKS loads JWKSFile if it's modified.
+func (j *JWT) loadJWKS() (err error) {
+	info, err := os.Stat(j.config.JWKSFile)
+	if err != nil {
+		return
+	}
+	j.lock.RLock()
+	modified := !info.ModTime().Equal(j.jwksMod)
+	j.lock.RUnlock()
+	if !modified {
+		return
+	}
+	b, err := ioutil.ReadFile(j.config.JWKSFile)
+	if err != nil {
+		return
+	}
+	keys, err := ParseJWKS(b)
+	if err != nil {
+		return
+	}
+	j.lock.Lock()
+	j.jwksKeys = keys
+	j.jwksMod = info.ModTime()
+	j.lock.Unlock()
+	return
+}
+
+func (j *JWT) reloadJWKS() {
+	if j.config.JWKSFile == "" {
+		return
+	}
+	j.lock.Lock()
+	check := time.Since(j.jwksCheck) >= j.config.JWKSReload
+	if check {
+		j.jwksCheck = time.Now()
+	}
+	j.lock.Unlock()
+	if check {
+		// keep the loaded keys if the file is broken in writing.
+		j.loadJWKS()
+	}
+}
+
+func (j *JWT) token(ctx gcore.Ctx) string {
+	if v := ctx.Header(j.config.Header); len(v) > 7 && strings.EqualFold(v[:7], "Bearer ") {
+		return strings.TrimSpace(v[7:])
+	}
+	if j.config.Query != "" {
+		return ctx.Request().URL.Query().Get(j.config.Query)
+	}
+	return ""
+}
+
+// Authenticate implements Authenticator.
+func (j *JWT) Authenticate(ctx gcore.Ctx) (id *gcore.Identity, err error) {
+	token := j.token(ctx)
+	if token == "" {
+		return nil, nil
+	}
+	claims, err := j.Verify(token)
+	if err != nil {
+		return
+	}
+	id = &gcore.Identity{
+		Subject: gcast.ToString(claims["sub"]),
+		Method:  j.Name(),
+		Roles:   gcast.ToStringSlice(claims["roles"]),
+		Claims:  claims,
+	}
+	if s, ok := claims["scope"].(string); ok {
+		id.Scopes = strings.Fields(s)
+	} else if v, ok := claims["scp"]; ok {
+		id.Scopes = gcast.ToStringSlice(v)
+	} else {
+		id.Scopes = gcast.ToStringSlice(claims["scopes"])
+	}
+	return
+}
+
+// Verify verifies the signature and the claims of token, and returns the
+// claims.
+func (j *JWT) Verify(token string) (claims map[string]interface{}, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredential
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || decodeSegment(parts[0], &header) != nil || decodeSegment(parts[1], &claims) != nil {
+		return nil, ErrInvalidCredential
+	}
+	j.reloadJWKS()
+	j.lock.RLock()
+	keys := append(append([]*JWTKey{}, j.keys...), j.jwksKeys...)
+	j.lock.RUnlock()
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if header.Kid != "" && k.ID != header.Kid {
+			continue
+		}
+		if k.verify(header.Alg, signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidCredential
+	}
+	now := time.Now()
+	leeway := j.config.Leeway
+	if v, ok := claims["exp"]; ok && now.After(time.Unix(gcast.ToInt64(v), 0).Add(leeway)) {
+		return nil, ErrExpiredCredential
+	}
+	if v, ok := claims["nbf"]; ok && now.Add(leeway).Before(time.Unix(gcast.ToInt64(v), 0)) {
+		return nil, ErrInvalidCredential
+	}
+	if j.config.Issuer != "" && gcast.ToString(claims["iss"]) != j.config.Issuer {
+		return nil, ErrInvalidCredential
+	}
+	if j.config.Audience != "" {
+		aud := gcast.ToStringSlice(claims["aud"])
+		if s, ok := claims["aud"].(string); ok {
+			aud = []string{s}
+		}
+		found := false
+		for _, v := range aud {
+			if v == j.config.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrInvalidCredential
+		}
+	}
+	return
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
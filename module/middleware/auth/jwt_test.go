@@ -0,0 +1,195 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWT_Algorithms(t *testing.T) {
+	assert := assert.New(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.Nil(err)
+	j, err := NewJWT(NewJWTConfig(),
+		&JWTKey{ID: "hs", Key: []byte("secret")},
+		&JWTKey{ID: "rs", Algorithm: "RS512", Key: &rsaKey.PublicKey},
+		&JWTKey{ID: "es", Key: &ecKey.PublicKey},
+	)
+	assert.Nil(err)
+	claims := map[string]interface{}{"sub": "u1"}
+	for _, v := range []struct {
+		alg, kid string
+		key      interface{}
+		ok       bool
+	}{
+		{"HS256", "hs", []byte("secret"), true},
+		{"HS512", "", []byte("secret"), true},
+		{"HS256", "hs", []byte("other"), false},
+		{"HS256", "rs", []byte("secret"), false},
+		{"RS512", "rs", rsaKey, true},
+		{"RS256", "rs", rsaKey, false},
+		{"ES384", "es", ecKey, true},
+		{"ES384", "", ecKey, true},
+	} {
+		token, err := SignJWT(claims, v.alg, v.kid, v.key)
+		assert.Nil(err)
+		c, err := j.Verify(token)
+		if v.ok {
+			assert.Nil(err, v.alg+v.kid)
+			assert.Equal("u1", c["sub"])
+		} else {
+			assert.Equal(ErrInvalidCredential, err, v.alg+v.kid)
+		}
+	}
+	// alg none and the public key as a HMAC secret are rejected.
+	token, _ := SignJWT(claims, "HS256", "rs", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+	_, err = j.Verify(token)
+	assert.Equal(ErrInvalidCredential, err)
+	h := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	c := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"u1"}`))
+	_, err = j.Verify(h + "." + c + ".")
+	assert.Equal(ErrInvalidCredential, err)
+	_, err = j.Verify("a.b")
+	assert.Equal(ErrInvalidCredential, err)
+
+	_, err = SignJWT(claims, "XX256", "", []byte("secret"))
+	assert.NotNil(err)
+	_, err = SignJWT(claims, "RS256", "", []byte("secret"))
+	assert.NotNil(err)
+}
+
+func TestJWT_Claims(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewJWTConfig()
+	cfg.Issuer = "gmc"
+	cfg.Audience = "api"
+	cfg.Leeway = 0
+	j, _ := NewJWT(cfg, &JWTKey{Key: []byte("secret")})
+	now := time.Now().Unix()
+	for _, v := range []struct {
+		claims map[string]interface{}
+		err    error
+	}{
+		{map[string]interface{}{"iss": "gmc", "aud": "api", "exp": now + 60}, nil},
+		{map[string]interface{}{"iss": "gmc", "aud": []string{"web", "api"}, "nbf": now - 1}, nil},
+		{map[string]interface{}{"iss": "gmc", "aud": "api", "exp": now - 60}, ErrExpiredCredential},
+		{map[string]interface{}{"iss": "gmc", "aud": "api", "nbf": now + 60}, ErrInvalidCredential},
+		{map[string]interface{}{"iss": "x", "aud": "api"}, ErrInvalidCredential},
+		{map[string]interface{}{"iss": "gmc", "aud": "web"}, ErrInvalidCredential},
+	} {
+		token, _ := SignJWT(v.claims, "HS256", "", []byte("secret"))
+		_, err := j.Verify(token)
+		assert.Equal(v.err, err, v.claims)
+	}
+}
+
+func TestJWT_Rotation(t *testing.T) {
+	assert := assert.New(t)
+	j, _ := NewJWT(NewJWTConfig(), &JWTKey{ID: "k1", Key: []byte("s1")})
+	t1, _ := SignJWT(map[string]interface{}{}, "HS256", "k1", []byte("s1"))
+	t2, _ := SignJWT(map[string]interface{}{}, "HS256", "k2", []byte("s2"))
+	_, err := j.Verify(t2)
+	assert.NotNil(err)
+	j.AddKey(&JWTKey{ID: "k2", Key: []byte("s2")})
+	_, err = j.Verify(t1)
+	assert.Nil(err)
+	_, err = j.Verify(t2)
+	assert.Nil(err)
+	j.RemoveKey("k1")
+	_, err = j.Verify(t1)
+	assert.NotNil(err)
+	_, err = j.Verify(t2)
+	assert.Nil(err)
+}
+
+func jwkInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func TestJWT_JWKS(t *testing.T) {
+	assert := assert.New(t)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	dir, err := ioutil.TempDir("", "jwks")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "jwks.json")
+	write := func(keys ...map[string]interface{}) {
+		b, _ := json.Marshal(map[string]interface{}{"keys": keys})
+		assert.Nil(ioutil.WriteFile(file, b, 0644))
+	}
+	write(map[string]interface{}{"kty": "RSA", "kid": "rs", "alg": "RS256", "n": jwkInt(rsaKey.N), "e": jwkInt(big.NewInt(int64(rsaKey.E)))},
+		map[string]interface{}{"kty": "EC", "kid": "es", "crv": "P-256", "x": jwkInt(ecKey.X), "y": jwkInt(ecKey.Y)},
+		map[string]interface{}{"kty": "oct", "kid": "enc", "use": "enc", "k": "c2VjcmV0"})
+	cfg := NewJWTConfig()
+	cfg.JWKSFile = file
+	cfg.JWKSReload = 0
+	j, err := NewJWT(cfg)
+	assert.Nil(err)
+	assert.Len(j.jwksKeys, 2)
+	rs, _ := SignJWT(map[string]interface{}{}, "RS256", "rs", rsaKey)
+	es, _ := SignJWT(map[string]interface{}{}, "ES256", "es", ecKey)
+	hs, _ := SignJWT(map[string]interface{}{}, "HS256", "hs", []byte("secret"))
+	_, err = j.Verify(rs)
+	assert.Nil(err)
+	_, err = j.Verify(es)
+	assert.Nil(err)
+	_, err = j.Verify(hs)
+	assert.NotNil(err)
+
+	write(map[string]interface{}{"kty": "oct", "kid": "hs", "k": "c2VjcmV0"})
+	os.Chtimes(file, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	_, err = j.Verify(hs)
+	assert.Nil(err)
+	_, err = j.Verify(rs)
+	assert.NotNil(err)
+
+	// the broken file keeps the loaded keys.
+	assert.Nil(ioutil.WriteFile(file, []byte("{"), 0644))
+	os.Chtimes(file, time.Now().Add(2*time.Second), time.Now().Add(2*time.Second))
+	_, err = j.Verify(hs)
+	assert.Nil(err)
+
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-1"}]}`))
+	assert.NotNil(err)
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"OKP"}]}`))
+	assert.NotNil(err)
+	cfg.JWKSFile = filepath.Join(dir, "none.json")
+	_, err = NewJWT(cfg)
+	assert.NotNil(err)
+}
+
+func TestParsePEMKey(t *testing.T) {
+	assert := assert.New(t)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	key, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}))
+	assert.Nil(err)
+	assert.IsType(&ecdsa.PublicKey{}, key)
+	key, err = ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)}))
+	assert.Nil(err)
+	assert.IsType(&rsa.PublicKey{}, key)
+	_, err = ParsePEMKey([]byte("none"))
+	assert.NotNil(err)
+}
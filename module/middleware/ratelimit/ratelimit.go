@@ -0,0 +1,225 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcache "github.com/snail007/gmc/module/cache"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+// KeyFunc returns the key which the request is limited by,
+// an empty key skips limiting the request.
+type KeyFunc func(ctx gcore.Ctx) string
+
+type Config struct {
+	Rule
+	// KeyFunc defaults to KeyByClientIP.
+	KeyFunc KeyFunc
+	// Store defaults to a CacheStore on a new gcache.MemCache.
+	Store Store
+	// Prefix is prepended to all keys.
+	Prefix string
+	// StatusCode and Message are the response of limited requests.
+	StatusCode int
+	Message    string
+	// OnLimited replaces the default response of limited requests.
+	OnLimited func(ctx gcore.Ctx, r Result)
+	// DisableHeaders stops sending RateLimit-* headers on allowed requests.
+	DisableHeaders bool
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Rule: Rule{
+			Algorithm: TokenBucket,
+			Limit:     60,
+			Window:    time.Minute,
+		},
+		Prefix:     "ratelimit",
+		StatusCode: http.StatusTooManyRequests,
+		Message:    http.StatusText(http.StatusTooManyRequests),
+	}
+}
+
+func (c *Config) check() error {
+	if c.Limit <= 0 || c.Window <= 0 {
+		return gcore.Providers.Error("")().New("ratelimit limit and window must be greater than zero")
+	}
+	if c.Algorithm != TokenBucket && c.Algorithm != SlidingWindow {
+		return gcore.Providers.Error("")().New("unknown ratelimit algorithm: " + c.Algorithm)
+	}
+	return nil
+}
+
+// KeyByClientIP limits requests by ctx.ClientIP().
+func KeyByClientIP(ctx gcore.Ctx) string {
+	return ctx.ClientIP()
+}
+
+// KeyByRoute limits requests by the matched route path, so it should be
+// added as middleware1, for middleware0 the request path is used.
+func KeyByRoute(ctx gcore.Ctx) string {
+	if p := ctx.Param().MatchedRoutePath(); p != "" {
+		return p
+	}
+	return ctx.Request().URL.Path
+}
+
+// KeyByHeader limits requests by the value of request header `name`,
+// requests without the header are not limited.
+func KeyByHeader(name string) KeyFunc {
+	return func(ctx gcore.Ctx) string {
+		return ctx.Header(name)
+	}
+}
+
+// KeyByRouteAndClientIP limits every client on every route separately.
+func KeyByRouteAndClientIP(ctx gcore.Ctx) string {
+	return KeyByRoute(ctx) + "|" + ctx.ClientIP()
+}
+
+// New creates a rate limit middleware.
+func New(cfg *Config) gcore.Middleware {
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = KeyByClientIP
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewCacheStore(gcache.NewMemCache(gcache.NewMemCacheConfig()))
+	}
+	if cfg.StatusCode == 0 {
+		cfg.StatusCode = http.StatusTooManyRequests
+	}
+	if err := cfg.check(); err != nil {
+		panic(err)
+	}
+	return func(ctx gcore.Ctx) (isStop bool) {
+		key := cfg.KeyFunc(ctx)
+		if key == "" {
+			return false
+		}
+		if cfg.Prefix != "" {
+			key = cfg.Prefix + ":" + key
+		}
+		r, err := cfg.Store.Take(key, &cfg.Rule)
+		if err != nil {
+			// fail open, a broken store should not take the site down.
+			ctx.Logger().Warnf("ratelimit store error: %s", err)
+			return false
+		}
+		if r.Allowed && cfg.DisableHeaders {
+			return false
+		}
+		setHeaders(ctx, r)
+		if r.Allowed {
+			return false
+		}
+		ctx.SetHeader("Retry-After", seconds(r.RetryAfter))
+		if cfg.OnLimited != nil {
+			cfg.OnLimited(ctx, r)
+		} else {
+			ctx.WriteHeader(cfg.StatusCode)
+			ctx.Write(cfg.Message)
+		}
+		return true
+	}
+}
+
+// NewFromConfig creates a rate limit middleware from section [ratelimit] in app.toml.
+// The cache of store `memory` or `redis` must be initialized before calling it.
+func NewFromConfig(c gcore.Config) (m gcore.Middleware, err error) {
+	cfg := NewConfig()
+	sub := c.Sub("ratelimit")
+	if sub == nil {
+		return New(cfg), nil
+	}
+	if v := sub.GetString("algorithm"); v != "" {
+		cfg.Algorithm = v
+	}
+	if sub.IsSet("limit") {
+		cfg.Limit = sub.GetInt64("limit")
+	}
+	if sub.IsSet("window") {
+		cfg.Window = time.Duration(sub.GetInt("window")) * time.Second
+	}
+	cfg.Burst = sub.GetInt64("burst")
+	if sub.IsSet("prefix") {
+		cfg.Prefix = sub.GetString("prefix")
+	}
+	if sub.IsSet("statuscode") {
+		cfg.StatusCode = sub.GetInt("statuscode")
+	}
+	if sub.IsSet("message") {
+		cfg.Message = sub.GetString("message")
+	}
+	cfg.DisableHeaders = sub.GetBool("disableheaders")
+	cfg.KeyFunc = keyFunc(sub.GetString("keyby"))
+	if err = cfg.check(); err != nil {
+		return nil, err
+	}
+	if cfg.Store, err = storeFromConfig(sub); err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
+}
+
+// storeFromConfig returns the store of [ratelimit], memory and redis are
+// the cache of cacheid, empty means a new memory cache.
+func storeFromConfig(sub gcore.SubConfig) (store Store, err error) {
+	id := sub.GetString("cacheid")
+	if id == "" {
+		id = "default"
+	}
+	typ := sub.GetString("store")
+	switch typ {
+	case "":
+		return nil, nil
+	case "memory":
+		if c := gcache.Memory(id); c != nil {
+			store = NewCacheStore(c)
+		}
+	case "redis":
+		if c := gcache.Redis(id); c != nil {
+			store = NewRedisStore(c, "")
+		}
+	default:
+		return nil, gcore.Providers.Error("")().New("unknown ratelimit store: " + typ)
+	}
+	if store == nil {
+		return nil, gcore.Providers.Error("")().New("ratelimit store " + typ + " cache " + id + " is not initialized")
+	}
+	return
+}
+
+func keyFunc(keyBy string) KeyFunc {
+	switch {
+	case keyBy == "route":
+		return KeyByRoute
+	case keyBy == "route_ip":
+		return KeyByRouteAndClientIP
+	case strings.HasPrefix(keyBy, "header:"):
+		return KeyByHeader(strings.TrimPrefix(keyBy, "header:"))
+	default:
+		return KeyByClientIP
+	}
+}
+
+func setHeaders(ctx gcore.Ctx, r Result) {
+	ctx.SetHeader("RateLimit-Limit", gcast.ToString(r.Limit))
+	ctx.SetHeader("RateLimit-Remaining", gcast.ToString(r.Remaining))
+	ctx.SetHeader("RateLimit-Reset", seconds(r.Reset))
+}
+
+// seconds rounds up `d` to whole seconds as the headers require.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%d", int64(math.Ceil(d.Seconds())))
+}
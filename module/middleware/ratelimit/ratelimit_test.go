@@ -0,0 +1,194 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gcache "github.com/snail007/gmc/module/cache"
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	gerror "github.com/snail007/gmc/module/error"
+	glog "github.com/snail007/gmc/module/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	providers := gcore.Providers
+	providers.RegisterConfig("", func() gcore.Config {
+		return gconfig.NewConfig()
+	})
+	providers.RegisterError("", func() gcore.Error {
+		return gerror.New()
+	})
+	providers.RegisterLogger("", func(ctx gcore.Ctx, prefix string) gcore.Logger {
+		return glog.NewLogger(prefix)
+	})
+	os.Exit(m.Run())
+}
+
+func mockCtx(ip string) (gcore.Ctx, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com/hello", nil)
+	r.RemoteAddr = ip + ":1234"
+	return gctx.NewCtx().CloneWithHTTP(ghttputil.NewResponseWriter(w), r), w
+}
+
+func TestTokenBucket(t *testing.T) {
+	assert := assert.New(t)
+	rule := &Rule{Algorithm: TokenBucket, Limit: 2, Window: time.Second}
+	r, tokens, last, _ := tokenBucket(rule, 0, 0, 1000)
+	assert.True(r.Allowed)
+	assert.Equal(int64(1), r.Remaining)
+	r, tokens, last, _ = tokenBucket(rule, tokens, last, 1000)
+	assert.True(r.Allowed)
+	r, tokens, last, _ = tokenBucket(rule, tokens, last, 1000)
+	assert.False(r.Allowed)
+	assert.Equal(500*time.Millisecond, r.RetryAfter)
+	r, _, _, _ = tokenBucket(rule, tokens, last, 1500)
+	assert.True(r.Allowed)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	assert := assert.New(t)
+	rule := &Rule{Algorithm: SlidingWindow, Limit: 2, Window: time.Second}
+	r, start, packed, _ := slidingWindow(rule, 0, 0, 1000)
+	assert.True(r.Allowed)
+	r, start, packed, _ = slidingWindow(rule, start, packed, 1100)
+	assert.True(r.Allowed)
+	r, start, packed, _ = slidingWindow(rule, start, packed, 1200)
+	assert.False(r.Allowed)
+	assert.Equal(800*time.Millisecond, r.RetryAfter)
+	// the previous window still counts 2*0.5 at the middle of the next one.
+	r, start, packed, _ = slidingWindow(rule, start, packed, 2500)
+	assert.True(r.Allowed)
+	r, _, _, _ = slidingWindow(rule, start, packed, 2500)
+	assert.False(r.Allowed)
+}
+
+func TestMiddleware(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewConfig()
+	cfg.Limit = 2
+	cfg.Store = NewCacheStore(gcache.NewMemCache(gcache.NewMemCacheConfig()))
+	m := New(cfg)
+	for i := 0; i < 2; i++ {
+		ctx, w := mockCtx("10.0.0.1")
+		assert.False(m(ctx))
+		assert.Equal("2", w.Header().Get("RateLimit-Limit"))
+	}
+	ctx, w := mockCtx("10.0.0.1")
+	assert.True(m(ctx))
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.Equal("30", w.Header().Get("Retry-After"))
+	assert.Equal("0", w.Header().Get("RateLimit-Remaining"))
+
+	ctx, _ = mockCtx("10.0.0.2")
+	assert.False(m(ctx))
+}
+
+func TestMiddleware_KeyByHeader(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewConfig()
+	cfg.Limit = 1
+	cfg.KeyFunc = KeyByHeader("X-API-Key")
+	called := false
+	cfg.OnLimited = func(ctx gcore.Ctx, r Result) {
+		called = true
+		ctx.WriteHeader(http.StatusServiceUnavailable)
+	}
+	m := New(cfg)
+	ctx, _ := mockCtx("10.0.0.1")
+	assert.False(m(ctx))
+	ctx, _ = mockCtx("10.0.0.1")
+	assert.False(m(ctx))
+	ctx, w := mockCtx("10.0.0.1")
+	ctx.Request().Header.Set("X-API-Key", "abc")
+	assert.False(m(ctx))
+	ctx, w = mockCtx("10.0.0.1")
+	ctx.Request().Header.Set("X-API-Key", "abc")
+	assert.True(m(ctx))
+	assert.True(called)
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	c := gcore.Providers.Config("")()
+	c.Set("ratelimit.algorithm", SlidingWindow)
+	c.Set("ratelimit.limit", 1)
+	c.Set("ratelimit.window", 10)
+	c.Set("ratelimit.statuscode", 503)
+	c.Set("ratelimit.message", "slow down")
+	m, err := NewFromConfig(c)
+	assert.Nil(err)
+	ctx, _ := mockCtx("10.0.0.1")
+	assert.False(m(ctx))
+	ctx, w := mockCtx("10.0.0.1")
+	assert.True(m(ctx))
+	assert.Equal(503, w.Code)
+	assert.Equal("slow down", w.Body.String())
+}
+
+func TestNewFromConfig_Error(t *testing.T) {
+	assert := assert.New(t)
+	for k, v := range map[string]interface{}{
+		"ratelimit.limit":     0,
+		"ratelimit.window":    -1,
+		"ratelimit.algorithm": "none",
+		"ratelimit.store":     "file",
+	} {
+		c := gcore.Providers.Config("")()
+		c.Set(k, v)
+		m, err := NewFromConfig(c)
+		assert.NotNil(err, k)
+		assert.Nil(m, k)
+	}
+}
+
+func TestNewFromConfig_Store(t *testing.T) {
+	assert := assert.New(t)
+	// no cache initialized.
+	for _, store := range []string{"memory", "redis"} {
+		c := gcore.Providers.Config("")()
+		c.Set("ratelimit.store", store)
+		_, err := NewFromConfig(c)
+		assert.NotNil(err, store)
+	}
+	cfg := gcore.Providers.Config("")()
+	cfg.Set("cache", map[string]interface{}{
+		"memory": []interface{}{map[string]interface{}{"enable": true, "id": "default"}},
+		"redis":  []interface{}{map[string]interface{}{"enable": true, "id": "default", "address": "127.0.0.1:6379"}},
+	})
+	assert.Nil(gcache.Init(cfg))
+	for _, store := range []string{"memory", "redis"} {
+		c := gcore.Providers.Config("")()
+		c.Set("ratelimit.store", store)
+		m, err := NewFromConfig(c)
+		assert.Nil(err, store)
+		assert.NotNil(m, store)
+		// cache group not found.
+		c.Set("ratelimit.cacheid", "none")
+		_, err = NewFromConfig(c)
+		assert.NotNil(err, store)
+	}
+	c := gcore.Providers.Config("")()
+	c.Set("ratelimit.store", "memory")
+	c.Set("ratelimit.limit", 1)
+	m, err := NewFromConfig(c)
+	assert.Nil(err)
+	ctx, _ := mockCtx("10.0.0.2")
+	assert.False(m(ctx))
+	ctx, w := mockCtx("10.0.0.2")
+	assert.True(m(ctx))
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+}
@@ -0,0 +1,287 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	gcore "github.com/snail007/gmc/core"
+	gcache "github.com/snail007/gmc/module/cache"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+const (
+	// TokenBucket refills `limit` tokens every `window`, the bucket holds
+	// at most `burst` tokens.
+	TokenBucket = "token_bucket"
+	// SlidingWindow allows `limit` requests in any `window`, it weights the
+	// previous fixed window by the elapsed part of the current one.
+	SlidingWindow = "sliding_window"
+)
+
+// Rule is the limit applied to one key.
+type Rule struct {
+	Algorithm string
+	Limit     int64
+	Window    time.Duration
+	Burst     int64
+}
+
+func (r *Rule) burst() int64 {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// Result is the outcome of one Store.Take call.
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps the limiter state of all keys.
+type Store interface {
+	Take(key string, rule *Rule) (Result, error)
+}
+
+// CacheStore keeps the limiter state in a gcore.Cache, such as gcache.MemCache.
+// The state of one key is read and written under a lock of this process,
+// so it only works correctly for one node.
+type CacheStore struct {
+	cache gcore.Cache
+	locks [64]sync.Mutex
+}
+
+// NewCacheStore creates a Store on top of `c`.
+func NewCacheStore(c gcore.Cache) *CacheStore {
+	return &CacheStore{cache: c}
+}
+
+func (s *CacheStore) lock(key string) *sync.Mutex {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &s.locks[h%uint32(len(s.locks))]
+}
+
+// Take consumes one request of `key`.
+func (s *CacheStore) Take(key string, rule *Rule) (r Result, err error) {
+	l := s.lock(key)
+	l.Lock()
+	defer l.Unlock()
+	var a, b float64
+	v, err := s.cache.Get(key)
+	if err != nil && err != gcache.ErrKeyNotExists {
+		return
+	}
+	err = nil
+	if v != "" {
+		arr := strings.SplitN(v, ":", 2)
+		if len(arr) == 2 {
+			a, b = gcast.ToFloat64(arr[0]), gcast.ToFloat64(arr[1])
+		}
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	var ttl time.Duration
+	switch rule.Algorithm {
+	case SlidingWindow:
+		r, a, b, ttl = slidingWindow(rule, a, b, now)
+	default:
+		r, a, b, ttl = tokenBucket(rule, a, b, now)
+	}
+	err = s.cache.Set(key, fmt.Sprintf("%v:%v", a, b), ttl)
+	return
+}
+
+// tokenBucket takes one token, `tokens` and `last` is the saved state,
+// `now` is in milliseconds.
+func tokenBucket(rule *Rule, tokens, last float64, now int64) (r Result, newTokens, newLast float64, ttl time.Duration) {
+	capacity := float64(rule.burst())
+	rate := float64(rule.Limit) / float64(rule.Window/time.Millisecond)
+	if last == 0 {
+		tokens = capacity
+	} else if elapsed := float64(now) - last; elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+	r.Limit = rule.burst()
+	if tokens >= 1 {
+		tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = msDuration((1 - tokens) / rate)
+	}
+	r.Remaining = int64(tokens)
+	r.Reset = msDuration((capacity - tokens) / rate)
+	ttl = msDuration(capacity/rate) + time.Second
+	return r, tokens, float64(now), ttl
+}
+
+// slidingWindow counts one request, `start` is the start of the saved
+// window in milliseconds, `packed` holds the counts of the window before
+// it and of itself, see packCounts.
+func slidingWindow(rule *Rule, start, packed float64, now int64) (r Result, newStart, newPacked float64, ttl time.Duration) {
+	window := int64(rule.Window / time.Millisecond)
+	prev, cur := unpackCounts(packed, rule.Limit)
+	current := now - now%window
+	switch {
+	case int64(start) == current:
+	case int64(start) == current-window:
+		prev, cur = cur, 0
+	default:
+		prev, cur = 0, 0
+	}
+	elapsed := float64(now - current)
+	weight := 1 - elapsed/float64(window)
+	count := float64(prev)*weight + float64(cur)
+	r.Limit = rule.Limit
+	if count+1 <= float64(rule.Limit) {
+		cur++
+		count++
+		r.Allowed = true
+	} else if cur+1 > rule.Limit || prev == 0 {
+		r.RetryAfter = time.Duration(float64(window)-elapsed) * time.Millisecond
+	} else {
+		need := 1 - float64(rule.Limit-cur-1)/float64(prev)
+		r.RetryAfter = msDuration(need*float64(window) - elapsed)
+	}
+	r.Remaining = int64(float64(rule.Limit) - count)
+	if r.Remaining < 0 {
+		r.Remaining = 0
+	}
+	r.Reset = time.Duration(window-now%window) * time.Millisecond
+	ttl = rule.Window * 2
+	return r, float64(current), packCounts(prev, cur, rule.Limit), ttl
+}
+
+// packCounts keeps two counters in one number, both of them never
+// exceed limit+1.
+func packCounts(prev, cur, limit int64) float64 {
+	return float64(prev*(limit+2) + cur)
+}
+
+func unpackCounts(packed float64, limit int64) (prev, cur int64) {
+	p := int64(packed)
+	return p / (limit + 2), p % (limit + 2)
+}
+
+func msDuration(ms float64) time.Duration {
+	if ms < 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
+
+// RedisStore keeps the limiter state in redis, all nodes sharing the redis
+// server share the same limits, every Take is one atomic lua script.
+type RedisStore struct {
+	cache  *gcache.RedisCache
+	prefix string
+}
+
+// NewRedisStore creates a Store on top of `c`, `prefix` is prepended to all keys.
+func NewRedisStore(c *gcache.RedisCache, prefix string) *RedisStore {
+	return &RedisStore{cache: c, prefix: prefix}
+}
+
+var (
+	tokenBucketScript = redis.NewScript(1, `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil then
+  tokens = capacity
+elseif now > last then
+  tokens = math.min(capacity, tokens + (now - last) * rate)
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "last", now)
+redis.call("PEXPIRE", KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+	slidingWindowScript = redis.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local current = now - now % window
+local state = redis.call("HMGET", KEYS[1], "start", "prev", "cur")
+local start = tonumber(state[1]) or 0
+local prev = tonumber(state[2]) or 0
+local cur = tonumber(state[3]) or 0
+if start == current - window then
+  prev, cur = cur, 0
+elseif start ~= current then
+  prev, cur = 0, 0
+end
+local count = prev * (1 - (now - current) / window) + cur
+local allowed = 0
+if count + 1 <= limit then
+  cur = cur + 1
+  allowed = 1
+end
+redis.call("HMSET", KEYS[1], "start", current, "prev", prev, "cur", cur)
+redis.call("PEXPIRE", KEYS[1], window * 2)
+return {allowed, prev, cur}
+`)
+)
+
+// Take consumes one request of `key`.
+func (s *RedisStore) Take(key string, rule *Rule) (r Result, err error) {
+	conn := s.cache.Pool().Get()
+	defer conn.Close()
+	if s.prefix != "" {
+		key = s.prefix + ":" + key
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	var reply []interface{}
+	switch rule.Algorithm {
+	case SlidingWindow:
+		window := int64(rule.Window / time.Millisecond)
+		reply, err = redis.Values(slidingWindowScript.Do(conn, key, rule.Limit, window, now))
+		if err != nil {
+			return
+		}
+		prev, cur := gcast.ToInt64(reply[1]), gcast.ToInt64(reply[2])
+		if gcast.ToInt64(reply[0]) == 1 {
+			cur--
+		}
+		// replay the script decision locally to compute the headers.
+		current := float64(now - now%window)
+		r, _, _, _ = slidingWindow(rule, current, packCounts(prev, cur, rule.Limit), now)
+	default:
+		capacity := float64(rule.burst())
+		rate := float64(rule.Limit) / float64(rule.Window/time.Millisecond)
+		ttl := int64(capacity/rate) + 1000
+		reply, err = redis.Values(tokenBucketScript.Do(conn, key, capacity, rate, now, ttl))
+		if err != nil {
+			return
+		}
+		tokens := gcast.ToFloat64(reply[1])
+		if gcast.ToInt64(reply[0]) == 1 {
+			tokens++
+		}
+		r, _, _, _ = tokenBucket(rule, tokens, float64(now), now)
+	}
+	return
+}
@@ -0,0 +1,408 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package rbac
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gdb "github.com/snail007/gmc/module/db"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+var (
+	// ErrUnauthenticated is the error of the requests without identity, the
+	// response is 401.
+	ErrUnauthenticated = errors.New("authentication required")
+	// ErrForbidden is the error of the identities without the permission, the
+	// response is 403.
+	ErrForbidden = errors.New("permission denied")
+)
+
+// Role is a named set of permissions, the permissions of the inherited roles
+// are granted too. A permission can be a wildcard, such as `*` and `post:*`.
+type Role struct {
+	Name        string
+	Permissions []string
+	Inherits    []string
+}
+
+// Policy requires Permission for the requests to Resource, Resource is a
+// route with an optional method prefix, such as `DELETE /post/:id` and
+// `/admin/*`, or a controller method, such as `Admin.*` and `*.Delete`. A
+// trailing `/*` of route matches the path and all the paths under it, such
+// as `/admin`, `/admin/user` and `/admin/user/1`.
+// Condition is the name of a condition added by AddCondition, it's checked
+// in addition to the permission, empty means none.
+type Policy struct {
+	Resource   string
+	Permission string
+	Condition  string
+}
+
+// Condition checks the attributes of request and identity, id is nil if the
+// request is not authenticated.
+type Condition func(ctx gcore.Ctx, id *gcore.Identity) bool
+
+// Enforcer checks the permissions of the identities stored in Ctx by the
+// auth middleware against the policies of requests. All the policies
+// matched a request must pass, the requests matched none are allowed.
+type Enforcer struct {
+	lock         sync.RWMutex
+	roles        map[string]*Role
+	policies     []*Policy
+	conditions   map[string]Condition
+	errorHandler func(ctx gcore.Ctx, status int, err error)
+}
+
+// New creates an Enforcer.
+func New() *Enforcer {
+	return &Enforcer{
+		roles:      map[string]*Role{},
+		conditions: map[string]Condition{},
+	}
+}
+
+// SetRole adds or replaces the role of same name.
+func (e *Enforcer) SetRole(r *Role) *Enforcer {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.roles[r.Name] = r
+	return e
+}
+
+// AddPolicy adds the policies.
+func (e *Enforcer) AddPolicy(p ...*Policy) *Enforcer {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.policies = append(e.policies, p...)
+	return e
+}
+
+// AddCondition adds the condition of name used by policies.
+func (e *Enforcer) AddCondition(name string, c Condition) *Enforcer {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.conditions[name] = c
+	return e
+}
+
+// Load replaces all the roles and policies, such as the ones of LoadDB, so
+// they can be reloaded while serving.
+func (e *Enforcer) Load(roles []*Role, policies []*Policy) {
+	m := map[string]*Role{}
+	for _, r := range roles {
+		m[r.Name] = r
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.roles = m
+	e.policies = policies
+}
+
+// SetErrorHandler sets the handler called if the authorization fails, status
+// is 401 or 403. The default one responds the status.
+func (e *Enforcer) SetErrorHandler(fn func(ctx gcore.Ctx, status int, err error)) {
+	e.errorHandler = fn
+}
+
+// Permissions returns the permissions of roles, including the inherited
+// ones.
+func (e *Enforcer) Permissions(roles ...string) (permissions []string) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	visited := map[string]bool{}
+	var walk func(names []string)
+	walk = func(names []string) {
+		for _, name := range names {
+			r, ok := e.roles[name]
+			if !ok || visited[name] {
+				continue
+			}
+			visited[name] = true
+			permissions = append(permissions, r.Permissions...)
+			walk(r.Inherits)
+		}
+	}
+	walk(roles)
+	return
+}
+
+// Can returns true if any role of id is granted permission, false if id is
+// nil.
+func (e *Enforcer) Can(id *gcore.Identity, permission string) bool {
+	if id == nil {
+		return false
+	}
+	for _, p := range e.Permissions(id.Roles...) {
+		if matchPermission(p, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPermission reports whether the granted permission p covers
+// permission, `*` in p matches any characters, including `:`.
+func matchPermission(p, permission string) bool {
+	if !strings.Contains(p, "*") {
+		return p == permission
+	}
+	parts := strings.Split(p, "*")
+	if !strings.HasPrefix(permission, parts[0]) {
+		return false
+	}
+	permission = permission[len(parts[0]):]
+	last := len(parts) - 1
+	for _, part := range parts[1:last] {
+		i := strings.Index(permission, part)
+		if i < 0 {
+			return false
+		}
+		permission = permission[i+len(part):]
+	}
+	return strings.HasSuffix(permission, parts[last])
+}
+
+// Authorize checks the policies matched the request of ctx, the error is
+// ErrUnauthenticated or ErrForbidden if it fails.
+func (e *Enforcer) Authorize(ctx gcore.Ctx) error {
+	policies := e.match(ctx)
+	if len(policies) == 0 {
+		return nil
+	}
+	id := identityOf(ctx)
+	for _, p := range policies {
+		if p.Permission != "" {
+			if id == nil {
+				return ErrUnauthenticated
+			}
+			if !e.Can(id, p.Permission) {
+				return ErrForbidden
+			}
+		}
+		if p.Condition != "" {
+			e.lock.RLock()
+			c := e.conditions[p.Condition]
+			e.lock.RUnlock()
+			// unknown conditions fail closed.
+			if c == nil || !c(ctx, id) {
+				if id == nil {
+					return ErrUnauthenticated
+				}
+				return ErrForbidden
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Enforcer) match(ctx gcore.Ctx) (policies []*Policy) {
+	r := ctx.Request()
+	controller, method := controllerOf(ctx)
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	for _, p := range e.policies {
+		if isRoute(p.Resource) {
+			if matchRoute(p.Resource, r.Method, r.URL.Path) {
+				policies = append(policies, p)
+			}
+		} else if method != "" && matchController(p.Resource, controller, method) {
+			policies = append(policies, p)
+		}
+	}
+	return
+}
+
+// matchRoute matches the request to route resource, a trailing `/*` is a
+// catch-all also matches the bare prefix, so no path under it is skipped.
+func matchRoute(resource, method, p string) bool {
+	resource = strings.TrimSpace(resource)
+	if strings.HasSuffix(resource, "/*") {
+		prefix := strings.TrimSuffix(resource, "/*")
+		return ghttputil.MatchRoute(prefix, method, p) || ghttputil.MatchRoute(prefix+"/*path", method, p)
+	}
+	return ghttputil.MatchRoute(resource, method, p)
+}
+
+// isRoute reports whether the resource is a route, such as `/post/:id` and
+// `DELETE /post/:id`.
+func isRoute(resource string) bool {
+	if i := strings.Index(resource, " "); i >= 0 {
+		resource = strings.TrimSpace(resource[i+1:])
+	}
+	return strings.HasPrefix(resource, "/")
+}
+
+func matchController(resource, controller, method string) bool {
+	c, m := resource, "*"
+	if i := strings.LastIndex(resource, "."); i >= 0 {
+		c, m = resource[:i], resource[i+1:]
+	}
+	ok1, _ := path.Match(c, controller)
+	ok2, _ := path.Match(m, method)
+	return ok1 && ok2
+}
+
+// controllerOf returns the names of controller and method of the request,
+// empty if the route is not bound to a controller.
+func controllerOf(ctx gcore.Ctx) (controller, method string) {
+	method = ctx.ControllerMethod()
+	if method == "" {
+		return
+	}
+	var router gcore.HTTPRouter
+	if s := ctx.WebServer(); s != nil {
+		router = s.Router()
+	} else if s := ctx.APIServer(); s != nil {
+		router = s.Router()
+	}
+	if router != nil {
+		controller, _ = router.RouteController(ctx.FullPath())
+	}
+	return
+}
+
+func identityOf(ctx gcore.Ctx) *gcore.Identity {
+	if v, ok := ctx.Get(gcore.IdentityKey); ok {
+		return v.(*gcore.Identity)
+	}
+	return nil
+}
+
+// Handle is a middleware1 authorizes the request, it should be added by
+// AddMiddleware1 after the auth middleware. It sets the
+// gcore.PermissionChecker of request, so templates can call
+// `{{if can "post:edit" .}}` to hide the UI elements, the argument is the
+// view data, which is $ inside range and with, and should be passed to
+// partials, such as `{{template "nav" $}}`.
+func (e *Enforcer) Handle(ctx gcore.Ctx) (isStop bool) {
+	ctx.Set(gcore.PermissionCheckerKey, gcore.PermissionChecker(func(permission string) bool {
+		return e.Can(identityOf(ctx), permission)
+	}))
+	err := e.Authorize(ctx)
+	if err == nil {
+		return false
+	}
+	status := http.StatusForbidden
+	if err == ErrUnauthenticated {
+		status = http.StatusUnauthorized
+	}
+	if e.errorHandler != nil {
+		e.errorHandler(ctx, status, err)
+		return true
+	}
+	ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+	ctx.WriteHeader(status)
+	ctx.Write(http.StatusText(status) + ", " + err.Error())
+	return true
+}
+
+// LoadDB loads the roles and policies from the tables of db. The columns of
+// roleTable are `name`, `permissions` and `inherits`, permissions and
+// inherits are comma separated. The columns of policyTable are `resource`,
+// `permission` and `condition`.
+func LoadDB(db gcore.Database, roleTable, policyTable string) (roles []*Role, policies []*Policy, err error) {
+	rs, err := db.Query(db.AR().From(roleTable))
+	if err != nil {
+		return
+	}
+	for _, row := range rs.Rows() {
+		roles = append(roles, &Role{
+			Name:        row["name"],
+			Permissions: splitList(row["permissions"]),
+			Inherits:    splitList(row["inherits"]),
+		})
+	}
+	rs, err = db.Query(db.AR().From(policyTable))
+	if err != nil {
+		return
+	}
+	for _, row := range rs.Rows() {
+		policies = append(policies, &Policy{
+			Resource:   row["resource"],
+			Permission: row["permission"],
+			Condition:  row["condition"],
+		})
+	}
+	return
+}
+
+func splitList(s string) (list []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return
+}
+
+// NewFromConfig creates an Enforcer from section [rbac] in app.toml. The
+// roles and policies of db store are loaded in addition to the ones in
+// app.toml, the db must be initialized before calling it.
+func NewFromConfig(c gcore.Config) (e *Enforcer, err error) {
+	e = New()
+	sub := c.Sub("rbac")
+	if sub == nil {
+		return
+	}
+	var roles []*Role
+	var policies []*Policy
+	items, _ := sub.Get("roles").([]interface{})
+	for _, v := range items {
+		m := gcast.ToStringMap(v)
+		roles = append(roles, &Role{
+			Name:        gcast.ToString(m["name"]),
+			Permissions: gcast.ToStringSlice(m["permissions"]),
+			Inherits:    gcast.ToStringSlice(m["inherits"]),
+		})
+	}
+	items, _ = sub.Get("policies").([]interface{})
+	for _, v := range items {
+		m := gcast.ToStringMap(v)
+		policies = append(policies, &Policy{
+			Resource:   gcast.ToString(m["resource"]),
+			Permission: gcast.ToString(m["permission"]),
+			Condition:  gcast.ToString(m["condition"]),
+		})
+	}
+	if store := sub.GetString("store"); store != "" {
+		dbID := sub.GetString("dbid")
+		if dbID == "" {
+			dbID = "default"
+		}
+		var db gcore.Database
+		switch store {
+		case "mysql":
+			if v := gdb.DBMySQL(dbID); v != nil {
+				db = v
+			}
+		case "sqlite3":
+			if v := gdb.DBSQLite3(dbID); v != nil {
+				db = v
+			}
+		default:
+			return nil, gcore.Providers.Error("")().New("unknown rbac store: " + store)
+		}
+		if db == nil {
+			return nil, gcore.Providers.Error("")().New("rbac store " + store + " is not initialized")
+		}
+		var dbRoles []*Role
+		var dbPolicies []*Policy
+		if dbRoles, dbPolicies, err = LoadDB(db, sub.GetString("roletable"), sub.GetString("policytable")); err != nil {
+			return nil, err
+		}
+		roles = append(roles, dbRoles...)
+		policies = append(policies, dbPolicies...)
+	}
+	e.Load(roles, policies)
+	return
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package rbac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	gerror "github.com/snail007/gmc/module/error"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	providers := gcore.Providers
+	providers.RegisterConfig("", func() gcore.Config {
+		return gconfig.NewConfig()
+	})
+	providers.RegisterError("", func() gcore.Error {
+		return gerror.New()
+	})
+	os.Exit(m.Run())
+}
+
+func mockCtx(method, uri string, id *gcore.Identity) (gcore.Ctx, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "http://example.com"+uri, nil)
+	ctx := gctx.NewCtx().CloneWithHTTP(ghttputil.NewResponseWriter(w), r)
+	if id != nil {
+		ctx.Set(gcore.IdentityKey, id)
+	}
+	return ctx, w
+}
+
+func mockEnforcer() *Enforcer {
+	e := New()
+	e.SetRole(&Role{Name: "viewer", Permissions: []string{"post:read"}})
+	e.SetRole(&Role{Name: "editor", Permissions: []string{"post:edit"}, Inherits: []string{"viewer", "editor"}})
+	e.SetRole(&Role{Name: "admin", Permissions: []string{"*"}})
+	e.AddPolicy(
+		&Policy{Resource: "/post/*", Permission: "post:read"},
+		&Policy{Resource: "PUT /post/:id", Permission: "post:edit"},
+		&Policy{Resource: "/admin/*", Permission: "admin:access"},
+	)
+	return e
+}
+
+func TestMatchPermission(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(matchPermission("*", "post:edit"))
+	assert.True(matchPermission("post:*", "post:edit"))
+	assert.True(matchPermission("post:*:own", "post:edit:own"))
+	assert.True(matchPermission("post:edit", "post:edit"))
+	assert.False(matchPermission("post:*", "user:edit"))
+	assert.False(matchPermission("post:*:own", "post:edit:all"))
+	assert.False(matchPermission("post:edit", "post:delete"))
+}
+
+func TestEnforcer_Permissions(t *testing.T) {
+	assert := assert.New(t)
+	e := mockEnforcer()
+	assert.ElementsMatch([]string{"post:edit", "post:read"}, e.Permissions("editor"))
+	assert.Empty(e.Permissions("none"))
+	assert.True(e.Can(&gcore.Identity{Roles: []string{"editor"}}, "post:read"))
+	assert.True(e.Can(&gcore.Identity{Roles: []string{"admin"}}, "user:delete"))
+	assert.False(e.Can(&gcore.Identity{Roles: []string{"viewer"}}, "post:edit"))
+	assert.False(e.Can(nil, "post:read"))
+}
+
+func TestEnforcer_Handle(t *testing.T) {
+	assert := assert.New(t)
+	e := mockEnforcer()
+	viewer := &gcore.Identity{Subject: "u1", Roles: []string{"viewer"}}
+	editor := &gcore.Identity{Subject: "u2", Roles: []string{"editor"}}
+	for _, v := range []struct {
+		method, uri string
+		id          *gcore.Identity
+		status      int
+	}{
+		{http.MethodGet, "/index", nil, http.StatusOK},
+		{http.MethodGet, "/post/1", nil, http.StatusUnauthorized},
+		{http.MethodGet, "/post/1", viewer, http.StatusOK},
+		{http.MethodPut, "/post/1", viewer, http.StatusForbidden},
+		{http.MethodPut, "/post/1", editor, http.StatusOK},
+		{http.MethodGet, "/admin/user", editor, http.StatusForbidden},
+		{http.MethodGet, "/admin", nil, http.StatusUnauthorized},
+		{http.MethodGet, "/admin", editor, http.StatusForbidden},
+		{http.MethodGet, "/admin/", editor, http.StatusForbidden},
+		{http.MethodGet, "/admin/user/1", editor, http.StatusForbidden},
+		{http.MethodGet, "/post", nil, http.StatusUnauthorized},
+		{http.MethodGet, "/adminx", editor, http.StatusOK},
+	} {
+		ctx, w := mockCtx(v.method, v.uri, v.id)
+		isStop := e.Handle(ctx)
+		assert.Equal(v.status != http.StatusOK, isStop, v.method+" "+v.uri)
+		assert.Equal(v.status, w.Code, v.method+" "+v.uri)
+	}
+}
+
+func TestEnforcer_Condition(t *testing.T) {
+	assert := assert.New(t)
+	e := mockEnforcer()
+	e.AddPolicy(&Policy{Resource: "DELETE /post/:id", Permission: "post:edit", Condition: "owner"})
+	e.AddPolicy(&Policy{Resource: "/secret", Condition: "unknown"})
+	e.AddCondition("owner", func(ctx gcore.Ctx, id *gcore.Identity) bool {
+		return id != nil && id.Subject == ctx.Request().URL.Query().Get("owner")
+	})
+	editor := &gcore.Identity{Subject: "u2", Roles: []string{"editor"}}
+	ctx, _ := mockCtx(http.MethodDelete, "/post/1?owner=u2", editor)
+	assert.Nil(e.Authorize(ctx))
+	ctx, _ = mockCtx(http.MethodDelete, "/post/1?owner=u3", editor)
+	assert.Equal(ErrForbidden, e.Authorize(ctx))
+	ctx, _ = mockCtx(http.MethodGet, "/secret", editor)
+	assert.Equal(ErrForbidden, e.Authorize(ctx))
+}
+
+func TestEnforcer_PermissionChecker(t *testing.T) {
+	assert := assert.New(t)
+	e := mockEnforcer()
+	ctx, _ := mockCtx(http.MethodGet, "/index", &gcore.Identity{Roles: []string{"viewer"}})
+	assert.False(e.Handle(ctx))
+	v, ok := ctx.Get(gcore.PermissionCheckerKey)
+	assert.True(ok)
+	can := v.(gcore.PermissionChecker)
+	assert.True(can("post:read"))
+	assert.False(can("post:edit"))
+}
+
+func TestEnforcer_ErrorHandler(t *testing.T) {
+	assert := assert.New(t)
+	e := mockEnforcer()
+	var status int
+	e.SetErrorHandler(func(ctx gcore.Ctx, s int, err error) {
+		status = s
+		ctx.WriteHeader(http.StatusTeapot)
+	})
+	ctx, w := mockCtx(http.MethodGet, "/post/1", nil)
+	assert.True(e.Handle(ctx))
+	assert.Equal(http.StatusUnauthorized, status)
+	assert.Equal(http.StatusTeapot, w.Code)
+}
+
+func TestMatchRoute(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(matchRoute("/admin/*", "GET", "/admin"))
+	assert.True(matchRoute("/admin/*", "GET", "/admin/"))
+	assert.True(matchRoute("/admin/*", "GET", "/admin/user/1"))
+	assert.False(matchRoute("/admin/*", "GET", "/adminx"))
+	assert.False(matchRoute("/admin/*", "GET", "/"))
+	assert.True(matchRoute("DELETE /post/*", "DELETE", "/post"))
+	assert.True(matchRoute("DELETE /post/*", "DELETE", "/post/1/comment"))
+	assert.False(matchRoute("DELETE /post/*", "GET", "/post/1"))
+	assert.True(matchRoute("/*", "GET", "/"))
+	assert.True(matchRoute("/*", "GET", "/a/b"))
+	assert.True(matchRoute("/post/:id", "GET", "/post/1"))
+	assert.False(matchRoute("/post/:id", "GET", "/post/1/comment"))
+}
+
+func TestMatchController(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(matchController("Admin.*", "Admin", "Index"))
+	assert.True(matchController("*.Delete", "Post", "Delete"))
+	assert.True(matchController("Admin", "Admin", "Index"))
+	assert.False(matchController("Admin.*", "Post", "Index"))
+	assert.False(isRoute("Admin.*"))
+	assert.True(isRoute("DELETE /post/:id"))
+	assert.True(isRoute("/post/*"))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	c := gconfig.NewConfig()
+	c.SetConfigType("toml")
+	err := c.ReadConfig(strings.NewReader(`
+[rbac]
+store=""
+[[rbac.roles]]
+name="editor"
+permissions=["post:*"]
+[[rbac.policies]]
+resource="PUT /post/:id"
+permission="post:edit"
+`))
+	assert.Nil(err)
+	e, err := NewFromConfig(c)
+	assert.Nil(err)
+	ctx, _ := mockCtx(http.MethodPut, "/post/1", &gcore.Identity{Roles: []string{"editor"}})
+	assert.Nil(e.Authorize(ctx))
+	ctx, _ = mockCtx(http.MethodPut, "/post/1", &gcore.Identity{Roles: []string{"viewer"}})
+	assert.Equal(ErrForbidden, e.Authorize(ctx))
+
+	c = gconfig.NewConfig()
+	c.Set("rbac.store", "redis")
+	_, err = NewFromConfig(c)
+	assert.NotNil(err)
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gtrace
+
+import (
+	"fmt"
+	"net/http"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+type serverSpanKey struct{}
+
+// StartRequest is a middleware0 starts a server span for the request, the
+// parent is the traceparent header of it if any. The request context holds
+// the span, so it's available to handlers by SpanFromContext, StartChild and
+// TraceIDFromContext with ctx.Request().Context().
+func StartRequest(ctx gcore.Ctx) (isStop bool) {
+	r := ctx.Request()
+	parent := r.Context()
+	if sc, ok := Extract(r.Header); ok {
+		parent = ContextWithRemote(parent, sc)
+	}
+	c, span := Start(parent, "HTTP "+r.Method, KindServer)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.RequestURI())
+	span.SetAttribute("http.host", r.Host)
+	span.SetAttribute("net.peer.ip", ctx.ClientIP())
+	if ua := r.UserAgent(); ua != "" {
+		span.SetAttribute("http.user_agent", ua)
+	}
+	ctx.SetRequest(r.WithContext(c))
+	ctx.Set(serverSpanKey{}, span)
+	return false
+}
+
+// EndRequest is a middleware3 ends the span started by StartRequest, the
+// span is named by the matched route path if any, and its status is error
+// if the status code is 5xx.
+func EndRequest(ctx gcore.Ctx) (isStop bool) {
+	v, ok := ctx.Get(serverSpanKey{})
+	if !ok {
+		return false
+	}
+	span := v.(*Span)
+	code := ctx.StatusCode()
+	if route := ctx.Param().MatchedRoutePath(); route != "" {
+		span.SetName(ctx.Request().Method + " " + route)
+		span.SetAttribute("http.route", route)
+	}
+	span.SetAttribute("http.status_code", code)
+	if code >= 500 {
+		span.SetStatus(StatusError, http.StatusText(code))
+	}
+	span.End()
+	return false
+}
+
+type transport struct {
+	rt http.RoundTripper
+}
+
+// Transport wraps rt, it starts a client span for the requests whose context
+// holds a span, and sets the traceparent header, so the trace continues in
+// the called service. rt nil means http.DefaultTransport.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{rt: rt}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	ctx, span := StartChild(req.Context(), "HTTP "+req.Method, KindClient)
+	if span == nil {
+		return t.rt.RoundTrip(req)
+	}
+	defer span.End()
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.String())
+	// a RoundTripper must not modify the request.
+	r := req.WithContext(ctx)
+	r.Header = make(http.Header, len(req.Header)+2)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	Inject(ctx, r.Header)
+	resp, err = t.rt.RoundTrip(r)
+	if err != nil {
+		span.SetError(err)
+		return
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		span.SetStatus(StatusError, fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)))
+	}
+	return
+}
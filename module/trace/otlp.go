@@ -0,0 +1,179 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gtrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OTLPExporter exports spans to an OpenTelemetry collector with OTLP/HTTP in
+// JSON encoding.
+type OTLPExporter struct {
+	// Endpoint is the full url, such as http://127.0.0.1:4318/v1/traces.
+	Endpoint string
+	// Headers are added to every request, such as an authorization token.
+	Headers map[string]string
+	Timeout time.Duration
+	// Client is used to send requests, it's created with Timeout if nil.
+	Client *http.Client
+}
+
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	return &OTLPExporter{
+		Endpoint: endpoint,
+		Timeout:  time.Second * 10,
+	}
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttr(key string, v interface{}) (a otlpAttribute) {
+	a.Key = key
+	switch val := v.(type) {
+	case string:
+		a.Value.StringValue = &val
+	case bool:
+		a.Value.BoolValue = &val
+	case int:
+		s := strconv.Itoa(val)
+		a.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		a.Value.IntValue = &s
+	case float64:
+		a.Value.DoubleValue = &val
+	default:
+		s := fmt.Sprintf("%v", val)
+		a.Value.StringValue = &s
+	}
+	return
+}
+
+// encode groups spans by service name.
+func (e *OTLPExporter) encode(spans []SpanData) otlpRequest {
+	req := otlpRequest{}
+	index := map[string]int{}
+	for _, d := range spans {
+		i, ok := index[d.ServiceName]
+		if !ok {
+			rs := otlpResourceSpans{}
+			rs.Resource.Attributes = []otlpAttribute{otlpAttr("service.name", d.ServiceName)}
+			ss := otlpScopeSpans{}
+			ss.Scope.Name = "github.com/snail007/gmc"
+			rs.ScopeSpans = []otlpScopeSpans{ss}
+			i = len(req.ResourceSpans)
+			index[d.ServiceName] = i
+			req.ResourceSpans = append(req.ResourceSpans, rs)
+		}
+		s := otlpSpan{
+			TraceID:           d.TraceID.String(),
+			SpanID:            d.SpanID.String(),
+			Name:              d.Name,
+			Kind:              d.Kind,
+			StartTimeUnixNano: strconv.FormatInt(d.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(d.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: d.Status, Message: d.StatusMessage},
+		}
+		if d.ParentSpanID.IsValid() {
+			s.ParentSpanID = d.ParentSpanID.String()
+		}
+		keys := make([]string, 0, len(d.Attributes))
+		for k := range d.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s.Attributes = append(s.Attributes, otlpAttr(k, d.Attributes[k]))
+		}
+		ss := &req.ResourceSpans[i].ScopeSpans[0]
+		ss.Spans = append(ss.Spans, s)
+	}
+	return req
+}
+
+// Export implements Exporter.
+func (e *OTLPExporter) Export(spans []SpanData) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: e.Timeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("otlp collector responded %d, %s", resp.StatusCode, b)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gtrace
+
+import (
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+// The values are same as OTLP.
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+type StatusCode int
+
+// The values are same as OTLP.
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// SpanData is a finished span passed to Exporter.
+type SpanData struct {
+	ServiceName   string
+	Name          string
+	Kind          SpanKind
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]interface{}
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span is an operation of a trace, such as a request or a SQL query. All
+// methods of a nil *Span do nothing, so the spans which are not started can
+// be used safely.
+type Span struct {
+	mu     sync.Mutex
+	tracer *Tracer
+	sc     SpanContext
+	data   SpanData
+	ended  bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName changes the name of the span, such as using the matched route
+// after routing.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.ended {
+		s.data.Name = name
+	}
+	s.mu.Unlock()
+}
+
+// SetAttribute sets an attribute of the span, value should be a string, bool,
+// int, int64 or float64, others are exported as string.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.ended {
+		s.data.Attributes[key] = value
+	}
+	s.mu.Unlock()
+}
+
+func (s *Span) SetStatus(code StatusCode, msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.ended {
+		s.data.Status, s.data.StatusMessage = code, msg
+	}
+	s.mu.Unlock()
+}
+
+// SetError sets the status of span to StatusError if err is not nil.
+func (s *Span) SetError(err error) {
+	if err != nil {
+		s.SetStatus(StatusError, err.Error())
+	}
+}
+
+// End finishes the span and queues it to the exporter if it's sampled, the
+// calls after the first one do nothing, so do the setters after End, as the
+// exporter reads the span data in another goroutine.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if s.sc.IsSampled() {
+		s.tracer.export(data)
+	}
+}
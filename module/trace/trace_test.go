@@ -0,0 +1,254 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gtrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type memExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *memExporter) Export(spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	assert := assert.New(t)
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(tp)
+	assert.Nil(err)
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal("00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(sc.IsSampled())
+	assert.True(sc.Remote)
+	assert.Equal(tp, sc.Traceparent())
+
+	// future versions may append fields.
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	assert.Nil(err)
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+	} {
+		_, err = ParseTraceparent(v)
+		assert.NotNil(err, v)
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	assert := assert.New(t)
+	h := http.Header{}
+	Inject(context.Background(), h)
+	assert.Empty(h)
+
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	h.Set(TracestateHeader, "a=1")
+	sc, ok := Extract(h)
+	assert.True(ok)
+	assert.Equal("a=1", sc.TraceState)
+
+	tracer := NewTracer(NewConfig())
+	ctx, span := tracer.Start(ContextWithRemote(context.Background(), sc), "child", KindServer)
+	assert.Equal(sc.TraceID, span.SpanContext().TraceID)
+	assert.NotEqual(sc.SpanID, span.SpanContext().SpanID)
+	// the sampled flag of parent is followed.
+	assert.False(span.SpanContext().IsSampled())
+	assert.Equal(sc.TraceID.String(), TraceIDFromContext(ctx))
+
+	h2 := http.Header{}
+	Inject(ctx, h2)
+	assert.Equal(span.SpanContext().Traceparent(), h2.Get(TraceparentHeader))
+	assert.Equal("a=1", h2.Get(TracestateHeader))
+}
+
+func TestTracer_Sample(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConfig()
+	c.SampleRatio = 0
+	_, span := NewTracer(c).Start(nil, "a", KindInternal)
+	assert.False(span.SpanContext().IsSampled())
+	c.SampleRatio = 0.5
+	tracer := NewTracer(c)
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		_, span := tracer.Start(context.Background(), "a", KindInternal)
+		if span.SpanContext().IsSampled() {
+			sampled++
+		}
+	}
+	assert.True(sampled > 400 && sampled < 600, sampled)
+}
+
+func TestTracer_Export(t *testing.T) {
+	assert := assert.New(t)
+	e := &memExporter{}
+	c := NewConfig()
+	c.Exporter = e
+	c.BatchSize = 2
+	tracer := NewTracer(c)
+	ctx, root := tracer.Start(context.Background(), "root", KindServer)
+	_, child := tracer.StartChild(ctx, "child", KindInternal)
+	child.SetAttribute("k", "v")
+	child.SetError(fmt.Errorf("fail"))
+	child.End()
+	child.End()
+	// the setters after End are ignored.
+	child.SetName("changed")
+	child.SetAttribute("k", "changed")
+	child.SetStatus(StatusOK, "")
+	root.End()
+	_, none := tracer.StartChild(context.Background(), "none", KindInternal)
+	assert.Nil(none)
+	none.SetAttribute("k", "v")
+	none.End()
+	tracer.Flush()
+	e.mu.Lock()
+	assert.Len(e.spans, 2)
+	assert.Equal("child", e.spans[0].Name)
+	assert.Equal(root.SpanContext().SpanID, e.spans[0].ParentSpanID)
+	assert.Equal(StatusError, e.spans[0].Status)
+	assert.Equal("v", e.spans[0].Attributes["k"])
+	e.mu.Unlock()
+	tracer.Stop()
+	tracer.Stop()
+	tracer.Flush()
+}
+
+func TestOTLPExporter(t *testing.T) {
+	assert := assert.New(t)
+	var body map[string]interface{}
+	var auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+	}))
+	defer collector.Close()
+	e := NewOTLPExporter(collector.URL + "/v1/traces")
+	e.Headers = map[string]string{"Authorization": "token"}
+	c := NewConfig()
+	c.ServiceName = "demo"
+	c.Exporter = e
+	tracer := NewTracer(c)
+	ctx, root := tracer.Start(context.Background(), "GET /", KindServer)
+	_, child := tracer.Start(ctx, "db.query", KindClient)
+	child.SetAttribute("db.statement", "select 1")
+	child.SetAttribute("rows", 1)
+	child.End()
+	root.End()
+	tracer.Stop()
+
+	assert.Equal("token", auth)
+	rs := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	attrs := rs["resource"].(map[string]interface{})["attributes"].([]interface{})
+	assert.Equal(map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "demo"}}, attrs[0])
+	spans := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	assert.Len(spans, 2)
+	s0 := spans[0].(map[string]interface{})
+	assert.Equal(child.SpanContext().SpanID.String(), s0["spanId"])
+	assert.Equal(root.SpanContext().SpanID.String(), s0["parentSpanId"])
+	assert.Equal(root.SpanContext().TraceID.String(), s0["traceId"])
+	assert.Equal(float64(KindClient), s0["kind"])
+	assert.Contains(s0["attributes"], map[string]interface{}{"key": "rows", "value": map[string]interface{}{"intValue": "1"}})
+	_, ok := spans[1].(map[string]interface{})["parentSpanId"]
+	assert.False(ok)
+
+	collector.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad"))
+	})
+	assert.Contains(fmt.Sprint(e.Export([]SpanData{{Start: time.Now(), End: time.Now()}})), "400, bad")
+}
+
+func TestTransport(t *testing.T) {
+	assert := assert.New(t)
+	var tp string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tp = r.Header.Get(TraceparentHeader)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer backend.Close()
+	e := &memExporter{}
+	c := NewConfig()
+	c.Exporter = e
+	old := SetDefault(NewTracer(c))
+	defer SetDefault(old)
+
+	client := &http.Client{Transport: Transport(nil)}
+	resp, err := client.Get(backend.URL)
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal("", tp)
+
+	ctx, root := Start(context.Background(), "root", KindServer)
+	req, _ := http.NewRequest("GET", backend.URL+"/a", nil)
+	resp, err = client.Do(req.WithContext(ctx))
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Empty(req.Header)
+	root.End()
+	Flush()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if assert.Len(e.spans, 2) {
+		span := e.spans[0]
+		assert.Equal(KindClient, span.Kind)
+		assert.Equal(SpanContext{TraceID: span.TraceID, SpanID: span.SpanID, Flags: flagSampled}.Traceparent(), tp)
+		assert.Equal(404, span.Attributes["http.status_code"])
+		assert.Equal(StatusError, span.Status)
+	}
+}
+
+type fakeCache struct {
+	gcore.Cache
+}
+
+func (fakeCache) Get(key string) (string, error) {
+	return "", fmt.Errorf("miss")
+}
+
+func TestCache(t *testing.T) {
+	assert := assert.New(t)
+	e := &memExporter{}
+	c := NewConfig()
+	c.Exporter = e
+	tracer := NewTracer(c)
+	old := SetDefault(tracer)
+	defer SetDefault(old)
+	ctx, root := Start(context.Background(), "root", KindServer)
+	_, err := Cache(ctx, fakeCache{}, "memory").Get("foo")
+	assert.NotNil(err)
+	root.End()
+	tracer.Stop()
+	if assert.Len(e.spans, 2) {
+		assert.Equal("cache.get", e.spans[0].Name)
+		assert.Equal("foo", e.spans[0].Attributes["cache.key"])
+		assert.Equal("memory", e.spans[0].Attributes["cache.system"])
+		assert.Equal("miss", e.spans[0].StatusMessage)
+	}
+}
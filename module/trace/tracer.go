@@ -0,0 +1,312 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gtrace
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+var (
+	defaultTracer = NewTracer(NewConfig())
+	defaultMu     sync.RWMutex
+)
+
+// Exporter sends the finished spans to a tracing backend.
+type Exporter interface {
+	Export(spans []SpanData) error
+}
+
+type ExporterFunc func(spans []SpanData) error
+
+func (f ExporterFunc) Export(spans []SpanData) error {
+	return f(spans)
+}
+
+type Config struct {
+	ServiceName string
+	// Exporter nil means the spans are only used for propagation, and are
+	// never exported.
+	Exporter Exporter
+	// SampleRatio is the ratio of new traces to be sampled, 0 to 1. The traces
+	// from requests with traceparent follow the sampled flag of it.
+	SampleRatio float64
+	// BatchSize is the max count of spans exported at once.
+	BatchSize int
+	// FlushInterval is the max time a span waits to be exported.
+	FlushInterval time.Duration
+	// QueueSize is the max count of spans waiting, the spans are dropped when
+	// the queue is full, so a slow backend never blocks the app.
+	QueueSize int
+	Logger    gcore.Logger
+}
+
+func NewConfig() *Config {
+	return &Config{
+		ServiceName:   "gmc",
+		SampleRatio:   1,
+		BatchSize:     512,
+		FlushInterval: time.Second * 5,
+		QueueSize:     2048,
+	}
+}
+
+// Tracer starts spans and exports them in batches.
+type Tracer struct {
+	cfg     *Config
+	queue   chan SpanData
+	flushCh chan chan bool
+	stopCh  chan bool
+	doneCh  chan bool
+	once    sync.Once
+}
+
+func NewTracer(cfg *Config) *Tracer {
+	t := &Tracer{cfg: cfg}
+	if cfg.Exporter == nil {
+		return t
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second * 5
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 2048
+	}
+	t.queue = make(chan SpanData, cfg.QueueSize)
+	t.flushCh = make(chan chan bool)
+	t.stopCh = make(chan bool)
+	t.doneCh = make(chan bool)
+	go t.loop()
+	return t
+}
+
+// Start starts a span as the child of the span or remote span context in ctx,
+// or a new trace if there is not, the returned context holds the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID, sc.Flags, sc.TraceState = parent.TraceID, parent.Flags, parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		if t.sampled(sc.TraceID) {
+			sc.Flags = flagSampled
+		}
+	}
+	span := &Span{
+		tracer: t,
+		sc:     sc,
+		data: SpanData{
+			ServiceName:  t.cfg.ServiceName,
+			Name:         name,
+			Kind:         kind,
+			TraceID:      sc.TraceID,
+			SpanID:       sc.SpanID,
+			ParentSpanID: parent.SpanID,
+			Start:        time.Now(),
+			Attributes:   map[string]interface{}{},
+		},
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// StartChild is same as Start, but the span is only started when ctx holds a
+// span, otherwise ctx and a nil span are returned, it's used to trace the
+// operations inside requests, such as SQL queries, without creating a trace
+// for every background query.
+func (t *Tracer) StartChild(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if SpanFromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return t.Start(ctx, name, kind)
+}
+
+// sampled decides by the lower 8 bytes of trace id, so all services using
+// the same ratio make the same decision.
+func (t *Tracer) sampled(id TraceID) bool {
+	switch {
+	case t.cfg.SampleRatio >= 1:
+		return true
+	case t.cfg.SampleRatio <= 0:
+		return false
+	}
+	bound := uint64(t.cfg.SampleRatio * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:])>>1 < bound
+}
+
+func (t *Tracer) export(data SpanData) {
+	if t.queue == nil {
+		return
+	}
+	select {
+	case t.queue <- data:
+	default:
+		if t.cfg.Logger != nil {
+			t.cfg.Logger.Warnf("trace queue is full, span %s dropped", data.Name)
+		}
+	}
+}
+
+func (t *Tracer) loop() {
+	defer close(t.doneCh)
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+	var batch []SpanData
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.cfg.Exporter.Export(batch); err != nil && t.cfg.Logger != nil {
+			t.cfg.Logger.Warnf("export %d spans fail, error: %s", len(batch), err)
+		}
+		batch = nil
+	}
+	drain := func() {
+		for {
+			select {
+			case d := <-t.queue:
+				batch = append(batch, d)
+				if len(batch) >= t.cfg.BatchSize {
+					flush()
+				}
+			default:
+				flush()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case d := <-t.queue:
+			batch = append(batch, d)
+			if len(batch) >= t.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case ch := <-t.flushCh:
+			drain()
+			close(ch)
+		case <-t.stopCh:
+			drain()
+			return
+		}
+	}
+}
+
+// Flush exports all queued spans and waits for it.
+func (t *Tracer) Flush() {
+	if t.queue == nil {
+		return
+	}
+	ch := make(chan bool)
+	select {
+	case t.flushCh <- ch:
+		<-ch
+	case <-t.doneCh:
+	}
+}
+
+// Stop exports all queued spans and stops the exporting goroutine, the spans
+// ended after it are dropped.
+func (t *Tracer) Stop() {
+	if t.queue == nil {
+		return
+	}
+	t.once.Do(func() {
+		close(t.stopCh)
+		<-t.doneCh
+	})
+}
+
+// NewFromConfig creates a tracer from section [trace] in app.toml, it's nil
+// if tracing is not enabled.
+func NewFromConfig(cfg gcore.Config, logger gcore.Logger) *Tracer {
+	if !cfg.GetBool("trace.enable") {
+		return nil
+	}
+	c := NewConfig()
+	c.Logger = logger
+	if v := cfg.GetString("trace.servicename"); v != "" {
+		c.ServiceName = v
+	}
+	if cfg.IsSet("trace.sampleratio") {
+		c.SampleRatio = cfg.GetFloat64("trace.sampleratio")
+	}
+	if v := cfg.GetInt("trace.batchsize"); v > 0 {
+		c.BatchSize = v
+	}
+	if v := cfg.GetInt("trace.flushinterval"); v > 0 {
+		c.FlushInterval = time.Duration(v) * time.Second
+	}
+	if v := cfg.GetInt("trace.queuesize"); v > 0 {
+		c.QueueSize = v
+	}
+	if endpoint := cfg.GetString("trace.endpoint"); endpoint != "" {
+		e := NewOTLPExporter(endpoint)
+		e.Headers = cfg.GetStringMapString("trace.headers")
+		if v := cfg.GetInt("trace.timeout"); v > 0 {
+			e.Timeout = time.Duration(v) * time.Second
+		}
+		c.Exporter = e
+	}
+	return NewTracer(c)
+}
+
+// Init replaces the default tracer with the one created from section [trace]
+// in app.toml, the previous one is stopped, nothing changes if tracing is not
+// enabled.
+func Init(cfg gcore.Config, logger gcore.Logger) {
+	if t := NewFromConfig(cfg, logger); t != nil {
+		SetDefault(t).Stop()
+	}
+}
+
+// Default returns the default tracer, it never exports spans until Init or
+// SetDefault is called.
+func Default() *Tracer {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultTracer
+}
+
+// SetDefault sets the default tracer, and returns the previous one.
+func SetDefault(t *Tracer) (old *Tracer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	old, defaultTracer = defaultTracer, t
+	return
+}
+
+// Start starts a span with the default tracer.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind)
+}
+
+// StartChild starts a child span with the default tracer.
+func StartChild(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return Default().StartChild(ctx, name, kind)
+}
+
+// Flush exports all queued spans of the default tracer.
+func Flush() {
+	Default().Flush()
+}
+
+// Stop stops the default tracer.
+func Stop() {
+	Default().Stop()
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gtrace
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+type tracedDB struct {
+	gcore.Database
+	ctx    context.Context
+	system string
+}
+
+// DB returns a database which starts a child span of ctx for every query,
+// system is the type of database, such as mysql, it's the db.system
+// attribute of spans. Nothing is traced if ctx holds no span.
+//
+//	db := gtrace.DB(c.Request().Context(), gdb.DB(), "mysql")
+func DB(ctx context.Context, db gcore.Database, system string) gcore.Database {
+	return &tracedDB{Database: db, ctx: ctx, system: system}
+}
+
+func (db *tracedDB) trace(name, sqlStr string, fn func() (gcore.ResultSet, error)) (rs gcore.ResultSet, err error) {
+	_, span := StartChild(db.ctx, name, KindClient)
+	span.SetAttribute("db.system", db.system)
+	span.SetAttribute("db.statement", sqlStr)
+	rs, err = fn()
+	span.SetError(err)
+	span.End()
+	return
+}
+
+func (db *tracedDB) Exec(ar gcore.ActiveRecord) (gcore.ResultSet, error) {
+	return db.trace("db.exec", ar.SQL(), func() (gcore.ResultSet, error) { return db.Database.Exec(ar) })
+}
+
+func (db *tracedDB) ExecSQL(sqlStr string, values ...interface{}) (gcore.ResultSet, error) {
+	return db.trace("db.exec", sqlStr, func() (gcore.ResultSet, error) { return db.Database.ExecSQL(sqlStr, values...) })
+}
+
+func (db *tracedDB) ExecTx(ar gcore.ActiveRecord, tx *sql.Tx) (gcore.ResultSet, error) {
+	return db.trace("db.exec", ar.SQL(), func() (gcore.ResultSet, error) { return db.Database.ExecTx(ar, tx) })
+}
+
+func (db *tracedDB) ExecSQLTx(tx *sql.Tx, sqlStr string, values ...interface{}) (gcore.ResultSet, error) {
+	return db.trace("db.exec", sqlStr, func() (gcore.ResultSet, error) { return db.Database.ExecSQLTx(tx, sqlStr, values...) })
+}
+
+func (db *tracedDB) Query(ar gcore.ActiveRecord) (gcore.ResultSet, error) {
+	return db.trace("db.query", ar.SQL(), func() (gcore.ResultSet, error) { return db.Database.Query(ar) })
+}
+
+func (db *tracedDB) QuerySQL(sqlStr string, values ...interface{}) (gcore.ResultSet, error) {
+	return db.trace("db.query", sqlStr, func() (gcore.ResultSet, error) { return db.Database.QuerySQL(sqlStr, values...) })
+}
+
+type tracedCache struct {
+	gcore.Cache
+	ctx    context.Context
+	system string
+}
+
+// Cache returns a cache which starts a child span of ctx for every call,
+// system is the type of cache, such as redis, it's the cache.system attribute
+// of spans. Nothing is traced if ctx holds no span.
+//
+//	c := gtrace.Cache(ctx.Request().Context(), gcache.Redis(), "redis")
+func Cache(ctx context.Context, c gcore.Cache, system string) gcore.Cache {
+	return &tracedCache{Cache: c, ctx: ctx, system: system}
+}
+
+func (c *tracedCache) start(op string, key string) *Span {
+	_, span := StartChild(c.ctx, "cache."+op, KindClient)
+	span.SetAttribute("cache.system", c.system)
+	if key != "" {
+		span.SetAttribute("cache.key", key)
+	}
+	return span
+}
+
+func end(span *Span, err error) {
+	span.SetError(err)
+	span.End()
+}
+
+func (c *tracedCache) Has(key string) (ok bool, err error) {
+	span := c.start("has", key)
+	defer func() { end(span, err) }()
+	return c.Cache.Has(key)
+}
+
+func (c *tracedCache) Clear() (err error) {
+	span := c.start("clear", "")
+	defer func() { end(span, err) }()
+	return c.Cache.Clear()
+}
+
+func (c *tracedCache) Get(key string) (v string, err error) {
+	span := c.start("get", key)
+	defer func() { end(span, err) }()
+	return c.Cache.Get(key)
+}
+
+func (c *tracedCache) Set(key string, value string, ttl time.Duration) (err error) {
+	span := c.start("set", key)
+	defer func() { end(span, err) }()
+	return c.Cache.Set(key, value, ttl)
+}
+
+func (c *tracedCache) Del(key string) (err error) {
+	span := c.start("del", key)
+	defer func() { end(span, err) }()
+	return c.Cache.Del(key)
+}
+
+func (c *tracedCache) GetMulti(keys []string) (v map[string]string, err error) {
+	span := c.start("get_multi", "")
+	span.SetAttribute("cache.keys", len(keys))
+	defer func() { end(span, err) }()
+	return c.Cache.GetMulti(keys)
+}
+
+func (c *tracedCache) SetMulti(values map[string]string, ttl time.Duration) (err error) {
+	span := c.start("set_multi", "")
+	span.SetAttribute("cache.keys", len(values))
+	defer func() { end(span, err) }()
+	return c.Cache.SetMulti(values, ttl)
+}
+
+func (c *tracedCache) DelMulti(keys []string) (err error) {
+	span := c.start("del_multi", "")
+	span.SetAttribute("cache.keys", len(keys))
+	defer func() { end(span, err) }()
+	return c.Cache.DelMulti(keys)
+}
+
+func (c *tracedCache) Incr(key string) (v int64, err error) {
+	span := c.start("incr", key)
+	defer func() { end(span, err) }()
+	return c.Cache.Incr(key)
+}
+
+func (c *tracedCache) Decr(key string) (v int64, err error) {
+	span := c.start("decr", key)
+	defer func() { end(span, err) }()
+	return c.Cache.Decr(key)
+}
+
+func (c *tracedCache) IncrN(key string, n int64) (v int64, err error) {
+	span := c.start("incr", key)
+	defer func() { end(span, err) }()
+	return c.Cache.IncrN(key, n)
+}
+
+func (c *tracedCache) DecrN(key string, n int64) (v int64, err error) {
+	span := c.start("decr", key)
+	defer func() { end(span, err) }()
+	return c.Cache.DecrN(key, n)
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gfs
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// SourceDisk reads files from the dir on disk.
+	SourceDisk = "disk"
+	// SourceEmbed reads files from the embedded file system.
+	SourceEmbed = "embed"
+	// SourceOverlay reads files from the dir on disk, then from the
+	// embedded file system, the disk files override the embedded ones.
+	SourceOverlay = "overlay"
+)
+
+// Select returns the file system of source, which is one of SourceDisk,
+// SourceEmbed and SourceOverlay. Empty source means SourceEmbed if embedded
+// is not nil, otherwise SourceDisk.
+func Select(source, dir string, embedded http.FileSystem) (fs http.FileSystem, err error) {
+	if source == "" {
+		source = SourceDisk
+		if embedded != nil {
+			source = SourceEmbed
+		}
+	}
+	switch source {
+	case SourceDisk:
+		return http.Dir(dir), nil
+	case SourceEmbed:
+		if embedded == nil {
+			return nil, fmt.Errorf("no embedded file system set")
+		}
+		return embedded, nil
+	case SourceOverlay:
+		if embedded == nil {
+			return http.Dir(dir), nil
+		}
+		return Overlay(http.Dir(dir), embedded), nil
+	}
+	return nil, fmt.Errorf("unknown file system source: %s", source)
+}
+
+// Overlay returns a file system of layers, a file is opened from the first
+// layer it exists in, the files of a directory are merged from all layers.
+func Overlay(layers ...http.FileSystem) http.FileSystem {
+	return overlayFS(layers)
+}
+
+type overlayFS []http.FileSystem
+
+func (o overlayFS) Open(name string) (http.File, error) {
+	var dirs []http.File
+	for _, fs := range o {
+		f, err := fs.Open(name)
+		if err != nil {
+			continue
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			continue
+		}
+		if !fi.IsDir() {
+			if len(dirs) > 0 {
+				f.Close()
+				continue
+			}
+			return f, nil
+		}
+		dirs = append(dirs, f)
+	}
+	if len(dirs) == 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return &overlayDir{File: dirs[0], layers: dirs}, nil
+}
+
+// overlayDir is a directory exists in more than one layer.
+type overlayDir struct {
+	http.File
+	layers []http.File
+	read   bool
+}
+
+func (d *overlayDir) Readdir(count int) (files []os.FileInfo, err error) {
+	if d.read {
+		if count > 0 {
+			return nil, io.EOF
+		}
+		return
+	}
+	d.read = true
+	seen := map[string]bool{}
+	for _, l := range d.layers {
+		items, e := l.Readdir(-1)
+		if e != nil {
+			continue
+		}
+		for _, fi := range items {
+			if !seen[fi.Name()] {
+				seen[fi.Name()] = true
+				files = append(files, fi)
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	if count > 0 && len(files) == 0 {
+		return nil, io.EOF
+	}
+	return
+}
+
+func (d *overlayDir) Close() (err error) {
+	for _, l := range d.layers {
+		if e := l.Close(); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Sub returns the file system of dir in fs.
+func Sub(fs http.FileSystem, dir string) http.FileSystem {
+	return subFS{fs: fs, dir: path.Clean("/" + dir)}
+}
+
+type subFS struct {
+	fs  http.FileSystem
+	dir string
+}
+
+func (s subFS) Open(name string) (http.File, error) {
+	return s.fs.Open(path.Join(s.dir, path.Clean("/"+name)))
+}
+
+// ReadFile reads the file name in fs.
+func ReadFile(fs http.FileSystem, name string) (b []byte, err error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// ReadDir returns the files of dir in fs, in lexical order. It returns nil
+// if dir does not exist.
+func ReadDir(fs http.FileSystem, dir string) (files []os.FileInfo, err error) {
+	f, err := fs.Open(path.Clean("/" + dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.IsDir() {
+		return
+	}
+	files, err = f.Readdir(-1)
+	if err != nil {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	return
+}
+
+// Walk calls fn for the files in root of fs recursively, in lexical order,
+// name is the slash separated path relative to root. It returns nil if
+// root does not exist.
+func Walk(fs http.FileSystem, root string, fn func(name string, info os.FileInfo) error) error {
+	return walk(fs, path.Clean("/"+root), "", fn)
+}
+
+func walk(fs http.FileSystem, root, dir string, fn func(name string, info os.FileInfo) error) (err error) {
+	files, err := ReadDir(fs, path.Join(root, dir))
+	if err != nil {
+		return
+	}
+	for _, v := range files {
+		name := strings.TrimPrefix(path.Join(dir, v.Name()), "/")
+		if v.IsDir() {
+			err = walk(fs, root, name, fn)
+		} else {
+			err = fn(name, v)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gfs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "gfs")
+	assert.Nil(t, err)
+	for k, v := range files {
+		file := filepath.Join(dir, k)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(t, ioutil.WriteFile(file, []byte(v), 0644))
+	}
+	return dir
+}
+
+func walkAll(t *testing.T, fs http.FileSystem) map[string]string {
+	files := map[string]string{}
+	assert.Nil(t, Walk(fs, "/", func(name string, info os.FileInfo) error {
+		b, err := ReadFile(fs, name)
+		files[name] = string(b)
+		return err
+	}))
+	return files
+}
+
+func TestOverlay(t *testing.T) {
+	assert := assert.New(t)
+	disk := mockDir(t, map[string]string{"a.txt": "disk", "sub/b.txt": "disk"})
+	defer os.RemoveAll(disk)
+	embed := mockDir(t, map[string]string{"a.txt": "embed", "c.txt": "embed", "sub/d.txt": "embed"})
+	defer os.RemoveAll(embed)
+	fs := Overlay(http.Dir(disk), http.Dir(embed))
+	assert.Equal(map[string]string{
+		"a.txt":     "disk",
+		"c.txt":     "embed",
+		"sub/b.txt": "disk",
+		"sub/d.txt": "embed",
+	}, walkAll(t, fs))
+	_, err := fs.Open("/none.txt")
+	assert.True(os.IsNotExist(err))
+
+	files, err := ReadDir(Sub(fs, "sub"), "/")
+	assert.Nil(err)
+	assert.Len(files, 2)
+	assert.Equal("b.txt", files[0].Name())
+	files, err = ReadDir(fs, "/none")
+	assert.Nil(err)
+	assert.Len(files, 0)
+}
+
+func TestSelect(t *testing.T) {
+	assert := assert.New(t)
+	disk := mockDir(t, map[string]string{"a.txt": "disk"})
+	defer os.RemoveAll(disk)
+	embed := mockDir(t, map[string]string{"a.txt": "embed", "b.txt": "embed"})
+	defer os.RemoveAll(embed)
+	for _, v := range []struct {
+		source   string
+		embedded http.FileSystem
+		expect   map[string]string
+	}{
+		{"", nil, map[string]string{"a.txt": "disk"}},
+		{"", http.Dir(embed), map[string]string{"a.txt": "embed", "b.txt": "embed"}},
+		{SourceDisk, http.Dir(embed), map[string]string{"a.txt": "disk"}},
+		{SourceEmbed, http.Dir(embed), map[string]string{"a.txt": "embed", "b.txt": "embed"}},
+		{SourceOverlay, http.Dir(embed), map[string]string{"a.txt": "disk", "b.txt": "embed"}},
+		{SourceOverlay, nil, map[string]string{"a.txt": "disk"}},
+	} {
+		fs, err := Select(v.source, disk, v.embedded)
+		assert.Nil(err)
+		assert.Equal(v.expect, walkAll(t, fs), v.source)
+	}
+	_, err := Select(SourceEmbed, disk, nil)
+	assert.NotNil(err)
+	_, err = Select("foo", disk, nil)
+	assert.NotNil(err)
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+//go:build go1.16
+// +build go1.16
+
+package gfs
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+// FromFS converts fsys to http.FileSystem, such as an embed.FS of
+// `//go:embed`, if dir is not empty, the files in dir of fsys are used.
+func FromFS(fsys fs.FS, dir ...string) (http.FileSystem, error) {
+	if len(dir) > 0 && dir[0] != "" && dir[0] != "." {
+		sub, err := fs.Sub(fsys, dir[0])
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+	return http.FS(fsys), nil
+}
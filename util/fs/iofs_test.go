@@ -0,0 +1,33 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+//go:build go1.16
+// +build go1.16
+
+package gfs
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromFS(t *testing.T) {
+	assert := assert.New(t)
+	fsys := fstest.MapFS{
+		"static/a.txt":     {Data: []byte("a")},
+		"static/js/b.js":   {Data: []byte("b")},
+		"views/index.html": {Data: []byte("index")},
+	}
+	fs, err := FromFS(fsys, "static")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"a.txt": "a", "js/b.js": "b"}, walkAll(t, fs))
+	fs, err = FromFS(fsys)
+	assert.Nil(err)
+	assert.Len(walkAll(t, fs), 3)
+	_, err = FromFS(fsys, "../x")
+	assert.NotNil(err)
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	gtrace "github.com/snail007/gmc/module/trace"
 	gproxy "github.com/snail007/gmc/util/proxy"
 	"io"
 	"io/ioutil"
@@ -39,6 +40,7 @@ type HTTPClient struct {
 	dns             string
 	connWrap        func(net.Conn) (conn net.Conn, err error)
 	jar             http.CookieJar
+	ctx             context.Context
 }
 
 // NewHTTPClient new a HTTPClient, all request shared one http.Client object, keep cookies, keepalive etc.
@@ -54,6 +56,24 @@ func (s *HTTPClient) SetConnWrap(fn func(c net.Conn) (conn net.Conn, err error))
 	return
 }
 
+// WithContext returns a copy of the client whose requests are sent with ctx,
+// they are canceled when ctx is done, and if ctx holds a span of gtrace, such
+// as ctx.Request().Context() in a traced request, a client span is started
+// and the traceparent header is sent.
+func (s *HTTPClient) WithContext(ctx context.Context) *HTTPClient {
+	c := *s
+	c.ctx = ctx
+	return &c
+}
+
+func (s *HTTPClient) newRequest(method, u string, body io.Reader) (req *http.Request, err error) {
+	req, err = http.NewRequest(method, u, body)
+	if err != nil || s.ctx == nil {
+		return
+	}
+	return req.WithContext(s.ctx), nil
+}
+
 // SetProxyFromEnv sets if using http_proxy or all_proxy from system environment to send request.
 func (s *HTTPClient) SetProxyFromEnv(set bool) {
 	s.setProxyFromEnv = set
@@ -140,7 +160,7 @@ func (s *HTTPClient) Get(u string, timeout time.Duration, header map[string]stri
 	if err != nil {
 		return
 	}
-	req, err := http.NewRequest("GET", u, nil)
+	req, err := s.newRequest("GET", u, nil)
 	if err != nil {
 		return
 	}
@@ -180,7 +200,7 @@ func (s *HTTPClient) PostOfReader(u string, r io.Reader, timeout time.Duration,
 			resp.Body.Close()
 		}
 	}()
-	req, err := http.NewRequest("POST", u, r)
+	req, err := s.newRequest("POST", u, r)
 	if err != nil {
 		return
 	}
@@ -252,7 +272,7 @@ func (s *HTTPClient) UploadReader(u, fieldName string, filename string, reader i
 			return
 		}
 	}()
-	req, err := http.NewRequest("POST", u, r)
+	req, err := s.newRequest("POST", u, r)
 	if err != nil {
 		return
 	}
@@ -405,7 +425,11 @@ func (s *HTTPClient) getProxyURL() (proxyURL *url.URL) {
 func (s *HTTPClient) newClient(timeout time.Duration) (client *http.Client, err error) {
 	client = &http.Client{}
 	client.Jar = s.jar
-	client.Transport, err = s.newTransport(timeout)
+	tr, err := s.newTransport(timeout)
+	if err != nil {
+		return
+	}
+	client.Transport = gtrace.Transport(tr)
 	return
 }
 
@@ -0,0 +1,19 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+/*
+systemd package does integrate your program with systemd, without any dependency.
+
+Listeners accepts the sockets passed by systemd socket activation through
+LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES, grouped by FileDescriptorName= of
+the socket units.
+
+Notify sends status messages to systemd through NOTIFY_SOCKET, such as READY=1,
+it's required by units of Type=notify. WatchdogInterval returns the interval
+of WATCHDOG=1 messages when WatchdogSec= is set.
+
+All functions are no-op when the program is not started by systemd.
+*/
+package gsystemd
@@ -0,0 +1,136 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gsystemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Ready tells systemd the startup is finished.
+	Ready = "READY=1"
+	// Reloading tells systemd the program is reloading its configuration.
+	Reloading = "RELOADING=1"
+	// Stopping tells systemd the program is beginning its shutdown.
+	Stopping = "STOPPING=1"
+	// Watchdog updates the watchdog timestamp.
+	Watchdog = "WATCHDOG=1"
+
+	// listenFdsStart is the first file descriptor passed by systemd.
+	listenFdsStart = 3
+)
+
+var (
+	listenersOnce sync.Once
+	listeners     map[string][]net.Listener
+	listenersErr  error
+)
+
+// Listeners returns the sockets passed by systemd socket activation, the key
+// is the FileDescriptorName= of the socket unit, default is the unit name.
+// The LISTEN_* environment variables are unset, so the child processes don't
+// inherit them, and the result is cached, it's safe to call more than once.
+func Listeners() (map[string][]net.Listener, error) {
+	listenersOnce.Do(func() {
+		listeners, listenersErr = listenFds(listenFdsStart)
+	})
+	return listeners, listenersErr
+}
+
+func listenFds(start int) (ls map[string][]net.Listener, err error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	ls = map[string][]net.Listener{}
+	pid, e := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if e != nil || pid != os.Getpid() {
+		return
+	}
+	n, e := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if e != nil || n <= 0 {
+		return
+	}
+	var names []string
+	if v := os.Getenv("LISTEN_FDNAMES"); v != "" {
+		names = strings.Split(v, ":")
+	}
+	for i := 0; i < n; i++ {
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(start+i), name)
+		l, e := net.FileListener(f)
+		// FileListener dups the fd, the original one is not needed any more.
+		f.Close()
+		if e != nil {
+			err = fmt.Errorf("systemd fd %d is not a listener, %s", start+i, e)
+			return
+		}
+		ls[name] = append(ls[name], l)
+	}
+	return
+}
+
+// Notify sends state to systemd, such as Ready, several states can be sent at
+// once, separated by "\n". sent is false if NOTIFY_SOCKET is not set, that
+// means the program is not started by systemd or the unit is not Type=notify.
+func Notify(state string) (sent bool, err error) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+	if strings.HasPrefix(addr, "@") {
+		// abstract namespace socket
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// MainPID returns the state tells systemd pid is the main process, it's used
+// when the program forks itself as a new main process.
+func MainPID(pid int) string {
+	return fmt.Sprintf("MAINPID=%d", pid)
+}
+
+// WatchdogInterval returns the watchdog timeout of the service, 0 means
+// watchdog is not enabled. Watchdog should be sent at about half of it.
+func WatchdogInterval() (interval time.Duration, err error) {
+	v := os.Getenv("WATCHDOG_USEC")
+	if v == "" {
+		return
+	}
+	if p := os.Getenv("WATCHDOG_PID"); p != "" {
+		pid, e := strconv.Atoi(p)
+		if e != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %s", p)
+		}
+		if pid != os.Getpid() {
+			return
+		}
+	}
+	usec, e := strconv.ParseInt(v, 10, 64)
+	if e != nil || usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %s", v)
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gsystemd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(t *testing.T) {
+	assert := assert.New(t)
+	os.Unsetenv("NOTIFY_SOCKET")
+	sent, err := Notify(Ready)
+	assert.False(sent)
+	assert.Nil(err)
+
+	dir, _ := ioutil.TempDir("", "gmc-systemd")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if !assert.Nil(err) {
+		return
+	}
+	defer conn.Close()
+	os.Setenv("NOTIFY_SOCKET", path)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	sent, err = Notify(Ready + "\n" + MainPID(10))
+	assert.True(sent)
+	assert.Nil(err)
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _ := conn.Read(buf)
+	assert.Equal("READY=1\nMAINPID=10", string(buf[:n]))
+}
+
+func TestListenFds(t *testing.T) {
+	assert := assert.New(t)
+	ls, err := listenFds(listenFdsStart)
+	assert.Nil(err)
+	assert.Empty(ls)
+
+	l1, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l1.Close()
+	f1, _ := l1.(*net.TCPListener).File()
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+	os.Setenv("LISTEN_FDNAMES", "web")
+	ls, err = listenFds(int(f1.Fd()))
+	assert.Nil(err)
+	if assert.Len(ls["web"], 1) {
+		assert.Equal(l1.Addr().String(), ls["web"][0].Addr().String())
+		ls["web"][0].Close()
+	}
+	assert.Equal("", os.Getenv("LISTEN_FDS"))
+
+	// not for this process.
+	os.Setenv("LISTEN_PID", "1")
+	os.Setenv("LISTEN_FDS", "1")
+	ls, err = listenFds(listenFdsStart)
+	assert.Nil(err)
+	assert.Empty(ls)
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	assert := assert.New(t)
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+	d, err := WatchdogInterval()
+	assert.Nil(err)
+	assert.Equal(time.Duration(0), d)
+
+	os.Setenv("WATCHDOG_USEC", "3000000")
+	d, err = WatchdogInterval()
+	assert.Nil(err)
+	assert.Equal(time.Second*3, d)
+
+	os.Setenv("WATCHDOG_PID", "1")
+	d, _ = WatchdogInterval()
+	assert.Equal(time.Duration(0), d)
+
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("WATCHDOG_USEC", "abc")
+	_, err = WatchdogInterval()
+	assert.NotNil(err)
+}
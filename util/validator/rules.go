@@ -0,0 +1,215 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gvalidator
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	messages = map[string]string{
+		"type":     "{field} has an invalid value",
+		"required": "{field} is required",
+		"min":      "{field} must be at least {param}",
+		"max":      "{field} must be at most {param}",
+		"len":      "{field} must be {param} in length",
+		"eq":       "{field} must be equal to {param}",
+		"ne":       "{field} must not be equal to {param}",
+		"gt":       "{field} must be greater than {param}",
+		"gte":      "{field} must be at least {param}",
+		"lt":       "{field} must be less than {param}",
+		"lte":      "{field} must be at most {param}",
+		"oneof":    "{field} must be one of [{param}]",
+		"email":    "{field} must be a valid email address",
+		"url":      "{field} must be a valid URL",
+		"ip":       "{field} must be a valid IP address",
+		"alpha":    "{field} can only contain letters",
+		"alphanum": "{field} can only contain letters and numbers",
+		"numeric":  "{field} must be a number",
+	}
+	messagesMu sync.RWMutex
+
+	emailRegexp    = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+	alphaRegexp    = regexp.MustCompile(`^[a-zA-Z]+$`)
+	alphanumRegexp = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	numericRegexp  = regexp.MustCompile(`^[-+]?[0-9]+(?:\.[0-9]+)?$`)
+)
+
+// RegisterMessage sets the builtin message of rule, {field} and {param} in
+// msg are replaced, the messages in i18n take precedence.
+func RegisterMessage(rule, msg string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	messages[rule] = msg
+}
+
+// Message returns the builtin message of rule.
+func Message(rule string) string {
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+	if msg, ok := messages[rule]; ok {
+		return msg
+	}
+	return "{field} is invalid"
+}
+
+func init() {
+	RegisterRule("required", func(v reflect.Value, _ string) bool {
+		return !isZero(v)
+	})
+	registerRule("min", sizeRule(func(size, n float64) bool { return size >= n }), checkNumber)
+	registerRule("max", sizeRule(func(size, n float64) bool { return size <= n }), checkNumber)
+	registerRule("len", sizeRule(func(size, n float64) bool { return size == n }), checkNumber)
+	registerRule("gt", sizeRule(func(size, n float64) bool { return size > n }), checkNumber)
+	registerRule("gte", sizeRule(func(size, n float64) bool { return size >= n }), checkNumber)
+	registerRule("lt", sizeRule(func(size, n float64) bool { return size < n }), checkNumber)
+	registerRule("lte", sizeRule(func(size, n float64) bool { return size <= n }), checkNumber)
+	RegisterRule("eq", func(v reflect.Value, param string) bool {
+		return equal(v, param)
+	})
+	RegisterRule("ne", func(v reflect.Value, param string) bool {
+		return !equal(v, param)
+	})
+	RegisterRule("oneof", func(v reflect.Value, param string) bool {
+		for _, p := range strings.Fields(param) {
+			if equal(v, p) {
+				return true
+			}
+		}
+		return false
+	})
+	RegisterRule("email", stringRule(emailRegexp.MatchString))
+	RegisterRule("alpha", stringRule(alphaRegexp.MatchString))
+	RegisterRule("alphanum", stringRule(alphanumRegexp.MatchString))
+	RegisterRule("numeric", stringRule(numericRegexp.MatchString))
+	RegisterRule("ip", stringRule(func(s string) bool {
+		return net.ParseIP(s) != nil
+	}))
+	RegisterRule("url", stringRule(func(s string) bool {
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	}))
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Struct:
+		if !v.CanInterface() {
+			return false
+		}
+		return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+	}
+	return false
+}
+
+// size returns the length of strings, slices and maps, or the value of
+// numbers.
+func size(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// sizeRule compares the size of value with the param, nil pointers are
+// valid, use required to reject them. The param is checked by checkNumber.
+func sizeRule(cmp func(size, n float64) bool) RuleFunc {
+	return func(v reflect.Value, param string) bool {
+		v = indirect(v)
+		if !v.IsValid() {
+			return true
+		}
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false
+		}
+		s, ok := size(v)
+		return ok && cmp(s, n)
+	}
+}
+
+// checkNumber checks the param of sizeRule.
+func checkNumber(param string) error {
+	if _, err := strconv.ParseFloat(param, 64); err != nil {
+		return fmt.Errorf("invalid param %q, a number expected", param)
+	}
+	return nil
+}
+
+// stringRule checks strings with fn, empty strings are valid, use required to
+// reject them.
+func stringRule(fn func(s string) bool) RuleFunc {
+	return func(v reflect.Value, _ string) bool {
+		v = indirect(v)
+		if !v.IsValid() {
+			return true
+		}
+		if v.Kind() != reflect.String {
+			return false
+		}
+		return v.Len() == 0 || fn(v.String())
+	}
+}
+
+func equal(v reflect.Value, param string) bool {
+	v = indirect(v)
+	if !v.IsValid() {
+		return false
+	}
+	if v.Kind() == reflect.String {
+		return v.String() == param
+	}
+	if s, ok := size(v); ok {
+		n, err := strconv.ParseFloat(param, 64)
+		return err == nil && s == n
+	}
+	if v.Kind() == reflect.Bool {
+		b, err := strconv.ParseBool(param)
+		return err == nil && v.Bool() == b
+	}
+	return fmt.Sprint(v.Interface()) == param
+}
@@ -0,0 +1,301 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gvalidator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Tag is the struct tag of rules, such as `validate:"required,min=3"`.
+const Tag = "validate"
+
+// RuleFunc checks value v with the param of rule, such as 3 of min=3, it
+// returns true if v is valid.
+type RuleFunc func(v reflect.Value, param string) bool
+
+var (
+	rules   = map[string]RuleFunc{}
+	params  = map[string]func(param string) error{}
+	rulesMu sync.RWMutex
+	// checked caches the result of checkType by the struct type.
+	checked sync.Map
+)
+
+// RegisterRule adds or replaces the rule named name, its message can be
+// added by RegisterMessage.
+func RegisterRule(name string, fn RuleFunc) {
+	registerRule(name, fn, nil)
+}
+
+// registerRule adds the rule with checkParam, which checks the param in tags
+// before validating.
+func registerRule(name string, fn RuleFunc, checkParam func(param string) error) {
+	rulesMu.Lock()
+	rules[name] = fn
+	if checkParam != nil {
+		params[name] = checkParam
+	} else {
+		delete(params, name)
+	}
+	rulesMu.Unlock()
+	// the unknown rule errors cached may be stale.
+	checked.Range(func(k, _ interface{}) bool {
+		checked.Delete(k)
+		return true
+	})
+}
+
+func rule(name string) RuleFunc {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return rules[name]
+}
+
+// checkRule returns an error if rule name is unknown or param is invalid.
+func checkRule(name, param string) error {
+	rulesMu.RLock()
+	fn, checkParam := rules[name], params[name]
+	rulesMu.RUnlock()
+	if fn == nil {
+		return fmt.Errorf("unknown rule %s", name)
+	}
+	if checkParam != nil {
+		return checkParam(param)
+	}
+	return nil
+}
+
+// FieldError is a failed rule of a field.
+type FieldError struct {
+	// Field is the path of the struct field, such as Address.City.
+	Field string `json:"field"`
+	// Name is the name of the field in request, such as the json or form tag,
+	// it's same as Field if there is no such tag.
+	Name string `json:"name"`
+	// Rule is the failed rule, such as required, or `type` if the value of
+	// request can not be converted to the type of field.
+	Rule  string      `json:"rule"`
+	Param string      `json:"param,omitempty"`
+	Value interface{} `json:"-"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Translate(nil, "")
+}
+
+// Translate returns the message of the error in lang, the message is the
+// key `validator.<rule>` of i18n, and the name of field is the key
+// `validator.field.<Name>`, the builtin English message is used if i18n is
+// nil or the key does not exist. {field} and {param} in the message are
+// replaced.
+func (e *FieldError) Translate(i18n gcore.I18n, lang string) string {
+	msg := Message(e.Rule)
+	name := e.Name
+	if i18n != nil {
+		msg = i18n.Tr(lang, "validator."+e.Rule, msg)
+		name = i18n.Tr(lang, "validator.field."+e.Name, name)
+	}
+	return strings.NewReplacer("{field}", name, "{param}", e.Param).Replace(msg)
+}
+
+// Errors is the list of FieldError returned by Struct.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Translate returns the messages of errors in lang, keyed by Name, only the
+// first error of a field is kept.
+func (e Errors) Translate(i18n gcore.I18n, lang string) map[string]string {
+	msgs := map[string]string{}
+	for _, v := range e {
+		if _, ok := msgs[v.Name]; !ok {
+			msgs[v.Name] = v.Translate(i18n, lang)
+		}
+	}
+	return msgs
+}
+
+// NameFunc returns the name of field in FieldError.Name, default uses the
+// json tag.
+type NameFunc func(field reflect.StructField) string
+
+// JSONName returns the name in json tag, or the field name.
+func JSONName(field reflect.StructField) string {
+	return TagName(field, "json")
+}
+
+// TagName returns the name in tag, or the field name if there is not.
+func TagName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// Struct validates the fields of struct obj with the rules in validate tag,
+// the nested structs are validated too, err is Errors if any rule fails.
+// The Name of errors is from json tag, use StructWithName to change it.
+// The tags of a type are checked once, an unknown rule or an invalid param
+// is returned as an error other than Errors.
+func Struct(obj interface{}) (err error) {
+	return StructWithName(obj, JSONName)
+}
+
+// StructWithName is same as Struct, but the Name of errors is returned by
+// nameFn.
+func StructWithName(obj interface{}, nameFn NameFunc) (err error) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return fmt.Errorf("validator: nil %s", v.Type())
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("validator: %s is not a struct", v.Type())
+	}
+	if err = checkType(v.Type()); err != nil {
+		return
+	}
+	var errs Errors
+	validateStruct(v, "", "", nameFn, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkType checks the validate tags of the fields of struct t and the nested
+// structs, the result is cached.
+func checkType(t reflect.Type) error {
+	if v, ok := checked.Load(t); ok {
+		if v == nil {
+			return nil
+		}
+		return v.(error)
+	}
+	err := checkStruct(t, "", map[reflect.Type]bool{})
+	checked.Store(t, err)
+	return err
+}
+
+func checkStruct(t reflect.Type, fieldPrefix string, visited map[reflect.Type]bool) error {
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		field := fieldPrefix + sf.Name
+		if sf.Anonymous {
+			field = fieldPrefix
+		}
+		if tag := sf.Tag.Get(Tag); tag != "" && tag != "-" {
+			for _, r := range strings.Split(tag, ",") {
+				ruleName, param := parseRule(r)
+				if ruleName == "" || ruleName == "omitempty" {
+					continue
+				}
+				if err := checkRule(ruleName, param); err != nil {
+					return fmt.Errorf("validator: field %s: %s", fieldPrefix+sf.Name, err)
+				}
+			}
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft.PkgPath() != "time" {
+			prefix := field + "."
+			if sf.Anonymous {
+				prefix = field
+			}
+			if err := checkStruct(ft, prefix, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseRule splits rule r in tag into the name and the param.
+func parseRule(r string) (name, param string) {
+	r = strings.TrimSpace(r)
+	if i := strings.Index(r, "="); i > 0 {
+		return r[:i], r[i+1:]
+	}
+	return r, ""
+}
+
+func validateStruct(v reflect.Value, fieldPrefix, namePrefix string, nameFn NameFunc, errs *Errors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			// unexported
+			continue
+		}
+		fv := v.Field(i)
+		field, name := fieldPrefix+sf.Name, namePrefix+nameFn(sf)
+		if sf.Anonymous {
+			field, name = fieldPrefix, namePrefix
+		}
+		if tag := sf.Tag.Get(Tag); tag != "" && tag != "-" {
+			validateField(fv, field, name, tag, errs)
+		}
+		// nested structs
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type().PkgPath() != "time" {
+			if sf.Anonymous {
+				validateStruct(fv, field, name, nameFn, errs)
+			} else {
+				validateStruct(fv, field+".", name+".", nameFn, errs)
+			}
+		}
+	}
+}
+
+func validateField(v reflect.Value, field, name, tag string, errs *Errors) {
+	for _, r := range strings.Split(tag, ",") {
+		ruleName, param := parseRule(r)
+		if ruleName == "" {
+			continue
+		}
+		if ruleName == "omitempty" {
+			if isZero(v) {
+				return
+			}
+			continue
+		}
+		// the rules are known, they are checked by checkType.
+		if fn := rule(ruleName); fn != nil && !fn(v, param) {
+			var value interface{}
+			if iv := indirect(v); iv.IsValid() && iv.CanInterface() {
+				value = iv.Interface()
+			}
+			*errs = append(*errs, &FieldError{Field: field, Name: name, Rule: ruleName, Param: param, Value: value})
+			// one error for one field is enough.
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gvalidator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"omitempty,len=5,numeric"`
+}
+
+type Base struct {
+	ID int `json:"id" validate:"gt=0"`
+}
+
+type user struct {
+	Base
+	Name     string    `json:"name" validate:"required,min=3,max=8"`
+	Email    string    `json:"email" validate:"email"`
+	Role     string    `json:"role" validate:"oneof=admin user"`
+	Age      *int      `json:"age" validate:"omitempty,gte=18"`
+	Tags     []string  `json:"tags" validate:"max=2"`
+	Site     string    `json:"site" validate:"omitempty,url"`
+	Created  time.Time `json:"created" validate:"required"`
+	Address  address   `json:"address"`
+	Backup   *address  `json:"backup"`
+	internal string
+}
+
+func TestStruct(t *testing.T) {
+	assert := assert.New(t)
+	age := 20
+	u := user{
+		Base:    Base{ID: 1},
+		Name:    "alice",
+		Email:   "a@b.com",
+		Role:    "admin",
+		Age:     &age,
+		Tags:    []string{"a"},
+		Created: time.Now(),
+		Address: address{City: "x"},
+	}
+	assert.Nil(Struct(&u))
+	assert.Nil(Struct(u))
+
+	age = 10
+	u = user{Name: "张三", Email: "bad", Role: "guest", Age: &age, Tags: []string{"a", "b", "c"}, Site: "foo",
+		Address: address{Zip: "12"}, Backup: &address{City: "y", Zip: "1234a"}}
+	err := Struct(&u)
+	errs, ok := err.(Errors)
+	assert.True(ok)
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Field+":"+e.Name+":"+e.Rule)
+	}
+	assert.Equal([]string{
+		"ID:id:gt",
+		"Name:name:min",
+		"Email:email:email",
+		"Role:role:oneof",
+		"Age:age:gte",
+		"Tags:tags:max",
+		"Site:site:url",
+		"Created:created:required",
+		"Address.City:address.city:required",
+		"Address.Zip:address.zip:len",
+		"Backup.Zip:backup.zip:numeric",
+	}, got)
+	assert.Equal(10, errs[4].Value)
+	assert.Equal("name must be at least 3", errs[1].Error())
+	assert.Equal("role must be one of [admin user]", errs[3].Error())
+}
+
+func TestStruct_Invalid(t *testing.T) {
+	assert := assert.New(t)
+	var u *user
+	assert.NotNil(Struct(u))
+	assert.NotNil(Struct(1))
+	err := Struct(struct {
+		A string `validate:"foo"`
+	}{})
+	assert.Equal("validator: field A: unknown rule foo", err.Error())
+	type nested struct {
+		B int `validate:"min=x"`
+	}
+	// the nested structs are checked even if they are nil.
+	err = Struct(&struct {
+		N *nested
+	}{})
+	assert.Equal(`validator: field N.B: invalid param "x", a number expected`, err.Error())
+	_, ok := err.(Errors)
+	assert.False(ok)
+}
+
+func TestRegisterRule(t *testing.T) {
+	assert := assert.New(t)
+	RegisterRule("even", func(v reflect.Value, _ string) bool {
+		return v.Int()%2 == 0
+	})
+	RegisterMessage("even", "{field} must be even")
+	err := StructWithName(struct {
+		N int `form:"num" validate:"even"`
+	}{N: 3}, func(f reflect.StructField) string {
+		return TagName(f, "form")
+	})
+	assert.Equal("num must be even", err.Error())
+}
+
+type i18n struct {
+	gcore.I18n
+	data map[string]string
+}
+
+func (i *i18n) Tr(lang, key string, defaultMessage ...string) string {
+	if v, ok := i.data[lang+"."+key]; ok {
+		return v
+	}
+	if len(defaultMessage) > 0 {
+		return defaultMessage[0]
+	}
+	return key
+}
+
+func TestErrors_Translate(t *testing.T) {
+	assert := assert.New(t)
+	err := Struct(struct {
+		Name string `json:"name" validate:"required"`
+		Age  int    `json:"age" validate:"min=18"`
+	}{Age: 1})
+	tr := &i18n{data: map[string]string{
+		"zh-CN.validator.required":   "{field}不能为空",
+		"zh-CN.validator.field.name": "名称",
+	}}
+	assert.Equal(map[string]string{
+		"name": "名称不能为空",
+		"age":  "age must be at least 18",
+	}, err.(Errors).Translate(tr, "zh-CN"))
+	assert.Equal("name is required; age must be at least 18", err.Error())
+}